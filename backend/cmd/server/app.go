@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"backend/internal/broker"
+	"backend/internal/config"
+	"backend/internal/connector"
+	"backend/internal/handler"
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
+)
+
+// app is the wired HTTP server: its router, the handlers main drains on shutdown and the
+// background workers main runs
+type app struct {
+	router    *gin.Engine
+	health    *handler.HealthHandler
+	websocket *handler.WebsocketHandler
+	workers   []func(context.Context)
+}
+
+// newApp builds repositories, services, handlers and routes on top of the given connections
+func newApp(cfg *config.Config, db *sqlx.DB, redisClient *redis.Client, rabbit *broker.Manager) *app {
+	// Initialize repositories
+	userRepo := repository.NewUserRepository(db)
+	conversationRepo := repository.NewConversationRepository(db)
+	messageRepo := repository.NewMessageRepository(db)
+	ticketRepo := repository.NewTicketRepository(db)
+	eventRepo := repository.NewEventRepository(db)
+	channelRepo := repository.NewChannelRepository(db)
+	assignmentRepo := repository.NewAssignmentRepository(db)
+	slaRepo := repository.NewSLARepository(db)
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
+	outboxRepo := repository.NewOutboxRepository(db)
+	searchRepo := repository.NewSearchRepository(db)
+	timelineRepo := repository.NewTimelineRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	roleRepo := repository.NewRoleRepository(db)
+	tenantRepo := repository.NewTenantRepository(db)
+	inviteRepo := repository.NewInviteRepository(db)
+	store := repository.NewStore(db)
+
+	// Initialize services
+	tokenService := service.NewTokenService(redisClient, cfg.JWTSecret, cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+	roleService := service.NewRoleService(roleRepo, userRepo, eventRepo, redisClient)
+	tenantService := service.NewTenantService(tenantRepo, userRepo, inviteRepo, eventRepo, store, tokenService, roleService)
+	authService := service.NewAuthService(userRepo, tokenService, tenantService)
+	rateLimitService := service.NewRateLimitService(redisClient)
+	quotaService := service.NewQuotaService(redisClient, tenantService, map[string]int64{
+		"free":       int64(cfg.QuotaMessagesFree),
+		"pro":        int64(cfg.QuotaMessagesPro),
+		"enterprise": int64(cfg.QuotaMessagesEnterprise),
+	})
+	presenceService := service.NewPresenceService(redisClient, userRepo, assignmentRepo, rabbit)
+	assignmentService := service.NewAssignmentService(assignmentRepo, conversationRepo, userRepo, eventRepo, store, presenceService, roleService)
+	slaService := service.NewSLAService(slaRepo, eventRepo, store)
+	conversationService := service.NewConversationService(conversationRepo, messageRepo, ticketRepo, idempotencyRepo, store, assignmentService, slaService, quotaService, redisClient, cfg.MessageEditWindow)
+	ticketService := service.NewTicketService(ticketRepo, conversationRepo, store, slaService)
+	userService := service.NewUserService(userRepo, store, tokenService, roleService)
+	channelService := service.NewChannelService(channelRepo, eventRepo, store, redisClient)
+	searchService := service.NewSearchService(searchRepo)
+	timelineService := service.NewTimelineService(timelineRepo, conversationRepo, ticketRepo)
+	auditService := service.NewAuditService(auditRepo)
+
+	// Outbound delivery of agent replies; the "mock" channel slug keeps messages in memory
+	connectorRegistry := connector.NewRegistry(10 * time.Second)
+	connectorRegistry.Register("mock", connector.NewMockConnector())
+	deliveryService := service.NewDeliveryService(messageRepo, conversationRepo, channelRepo, connectorRegistry, store)
+
+	// Background workers are started by main and run until shutdown
+	var workers []func(context.Context)
+	runWorker := func(run func(context.Context)) {
+		workers = append(workers, run)
+	}
+	runWorker(func(ctx context.Context) { deliveryService.Start(ctx, rabbit) })
+
+	// Initialize handlers
+	authHandler := handler.NewAuthHandler(authService)
+	conversationHandler := handler.NewConversationHandler(conversationService)
+	ticketHandler := handler.NewTicketHandler(ticketService)
+	webhookHandler := handler.NewWebhookHandler(conversationService, channelService, tenantService)
+	userHandler := handler.NewUserHandler(userService)
+	channelHandler := handler.NewChannelHandler(channelService)
+	assignmentHandler := handler.NewAssignmentHandler(assignmentService)
+	presenceHandler := handler.NewPresenceHandler(presenceService)
+	slaHandler := handler.NewSLAHandler(slaService)
+	searchHandler := handler.NewSearchHandler(searchService)
+	timelineHandler := handler.NewTimelineHandler(timelineService)
+	auditHandler := handler.NewAuditHandler(auditService)
+	roleHandler := handler.NewRoleHandler(roleService)
+	tenantHandler := handler.NewTenantHandler(tenantService, quotaService, cfg.TenantSignupEnabled)
+
+	messageHandler := handler.NewMessageHandler(conversationService, roleService)
+	healthHandler := handler.NewHealthHandler(db, redisClient, rabbit)
+
+	// WebSocket handler (for realtime)
+	websocketHandler := handler.NewWebsocketHandler(rabbit, cfg.JWTSecret, tokenService, presenceService)
+
+	// Presence sweeper: drops connections of instances that stopped heartbeating
+	runWorker(presenceService.Run)
+
+	// Role cache invalidations published by other instances
+	runWorker(roleService.Run)
+
+	// Background SLA checker (publishes sla.warning / sla.breached)
+	runWorker(func(ctx context.Context) { slaService.Run(ctx, cfg.SLACheckInterval) })
+
+	// Outbox relay: publishes committed events to RabbitMQ
+	outboxRelay := service.NewOutboxRelay(outboxRepo, rabbit, cfg.OutboxPollInterval)
+	runWorker(outboxRelay.Run)
+
+	// Retention job: permanently purges soft-deleted rows older than DELETED_RETENTION_DAYS
+	// and idempotency keys older than IDEMPOTENCY_KEY_TTL_HOURS
+	retentionService := service.NewRetentionService(store, cfg.DeletedRetention, cfg.IdempotencyKeyTTL)
+	runWorker(func(ctx context.Context) { retentionService.Run(ctx, cfg.RetentionCheckInterval) })
+
+	// Initialize Gin router; requests are logged as structured lines by RequestLogger
+	router := gin.New()
+	router.Use(gin.Recovery(), middleware.RequestID(), middleware.RequestLogger(), middleware.Metrics())
+
+	// CORS middleware
+	router.Use(middleware.CORS())
+
+	// API v1 routes
+	v1 := router.Group("/api/v1")
+	{
+		// Public routes, limited per client IP
+		authLimit := middleware.RateLimit(rateLimitService, "auth", model.RateLimit{Requests: cfg.RateLimitAuthRequests, Window: cfg.RateLimitWindow}, middleware.ByIP)
+		v1.POST("/auth/login", authLimit, authHandler.Login)
+		v1.POST("/auth/register", authLimit, authHandler.Register)
+		v1.POST("/auth/refresh", authLimit, authHandler.Refresh)
+		v1.POST("/auth/signup", authLimit, tenantHandler.Signup)
+
+		// Signed inbound webhook per channel: limited per client IP before the signature is
+		// checked, and per verified channel after it
+		webhookIPLimit := middleware.RateLimit(rateLimitService, "webhook_ip", model.RateLimit{Requests: cfg.RateLimitWebhookIPRequests, Window: cfg.RateLimitWindow}, middleware.ByIP)
+		webhookLimit := middleware.RateLimit(rateLimitService, "webhook", model.RateLimit{Requests: cfg.RateLimitWebhookRequests, Window: cfg.RateLimitWindow}, middleware.ByChannel)
+		v1.POST("/channel/webhook/:slug", webhookIPLimit, webhookHandler.VerifyWebhook, webhookLimit, webhookHandler.HandleChannelWebhook)
+
+		// Platform (operator) API, authenticated with PLATFORM_API_KEY
+		platform := v1.Group("/platform")
+		platform.Use(middleware.PlatformKey(cfg.PlatformAPIKey))
+		{
+			platform.GET("/tenants", tenantHandler.List)
+			platform.POST("/tenants", tenantHandler.Create)
+			platform.GET("/tenants/:id", tenantHandler.GetByID)
+			platform.PUT("/tenants/:id/plan", tenantHandler.SetPlan)
+			platform.POST("/tenants/:id/suspend", tenantHandler.Suspend)
+			platform.POST("/tenants/:id/reactivate", tenantHandler.Reactivate)
+		}
+
+		// Protected routes
+		protected := v1.Group("")
+		protected.Use(
+			middleware.AuthMiddleware(cfg.JWTSecret, tokenService),
+			middleware.RateLimit(rateLimitService, "api", model.RateLimit{Requests: cfg.RateLimitUserRequests, Window: cfg.RateLimitWindow}, middleware.ByUser),
+			middleware.RateLimit(rateLimitService, "api", model.RateLimit{Requests: cfg.RateLimitTenantRequests, Window: cfg.RateLimitWindow}, middleware.ByTenant),
+		)
+		{
+			protected.POST("/auth/logout", authHandler.Logout)
+
+			// Every other route requires a named permission of the caller's role
+			can := func(permission string) gin.HandlerFunc {
+				return middleware.RequirePermission(roleService, permission)
+			}
+
+			// Channels
+			protected.GET("/channels", can(model.PermChannelRead), channelHandler.List)
+			protected.GET("/channels/:id", can(model.PermChannelRead), channelHandler.GetByID)
+			protected.POST("/channels", can(model.PermChannelManage), channelHandler.Create)
+			protected.PUT("/channels/:id", can(model.PermChannelManage), channelHandler.Update)
+			protected.DELETE("/channels/:id", can(model.PermChannelManage), channelHandler.Delete)
+			protected.POST("/channels/:id/rotate-secret", can(model.PermChannelManage), channelHandler.RotateSecret)
+
+			// Webhook simulator, scoped to the caller's tenant
+			protected.POST("/channel/webhook", can(model.PermConversationCreate), webhookHandler.HandleWebhook)
+
+			// Additional conversation CRUD for frontend
+			protected.POST("/conversations", can(model.PermConversationCreate), conversationHandler.Create)
+			protected.PUT("/conversations/:id", can(model.PermConversationUpdate), conversationHandler.Update)
+			protected.DELETE("/conversations/:id", can(model.PermConversationDelete), conversationHandler.Delete)
+			protected.POST("/conversations/:id/restore", can(model.PermDeletedRestore), conversationHandler.Restore)
+			// Conversations
+			protected.GET("/conversations", can(model.PermConversationRead), conversationHandler.List)
+			protected.GET("/conversations/:id", can(model.PermConversationRead), conversationHandler.GetByID)
+			protected.GET("/conversations/:id/messages", can(model.PermConversationRead), conversationHandler.ListMessages)
+			protected.POST("/conversations/:id/messages", can(model.PermConversationReply), conversationHandler.SendMessage)
+			protected.POST("/conversations/:id/assign", can(model.PermConversationAssign), conversationHandler.Assign)
+			protected.POST("/conversations/:id/close", can(model.PermConversationClose), conversationHandler.Close)
+			protected.POST("/conversations/:id/read", can(model.PermConversationRead), conversationHandler.MarkRead)
+			protected.GET("/conversations/:id/reads", can(model.PermConversationRead), conversationHandler.ListReads)
+			protected.GET("/conversations/:id/timeline", can(model.PermConversationRead), timelineHandler.Conversation)
+			// Tickets per conversation and selection
+			protected.GET("/conversations/:id/tickets", can(model.PermTicketRead), conversationHandler.ListTickets)
+			protected.PUT("/conversations/:id/selected-ticket", can(model.PermConversationUpdate), conversationHandler.SetSelectedTicket)
+
+			// Messages
+			protected.PUT("/messages/:id", can(model.PermMessageEdit), messageHandler.Update)
+			protected.GET("/messages/:id/edits", can(model.PermConversationRead), messageHandler.ListEdits)
+			protected.DELETE("/messages/:id", can(model.PermMessageDelete), messageHandler.Delete)
+			protected.POST("/messages/:id/restore", can(model.PermDeletedRestore), messageHandler.Restore)
+
+			// Full-text search across messages, tickets and customers
+			protected.GET("/search", can(model.PermConversationRead), searchHandler.Search)
+
+			// Tickets
+			protected.GET("/tickets", can(model.PermTicketRead), ticketHandler.List)
+			protected.GET("/tickets/:id", can(model.PermTicketRead), ticketHandler.GetByID)
+			protected.GET("/tickets/:id/timeline", can(model.PermTicketRead), timelineHandler.Ticket)
+			protected.POST("/conversations/:id/escalate", can(model.PermTicketCreate), ticketHandler.Escalate)
+			// Extra ticket CRUD for frontend
+			protected.POST("/tickets", can(model.PermTicketCreate), ticketHandler.Create)
+			protected.PUT("/tickets/:id", can(model.PermTicketUpdate), ticketHandler.Update)
+			protected.DELETE("/tickets/:id", can(model.PermTicketDelete), ticketHandler.Delete)
+			protected.POST("/tickets/:id/restore", can(model.PermDeletedRestore), ticketHandler.Restore)
+			protected.PUT("/tickets/:id/status", can(model.PermTicketStatusUpdate), ticketHandler.UpdateStatus)
+
+			// Users
+			protected.GET("/users", can(model.PermUserRead), userHandler.List)
+			protected.POST("/users", can(model.PermUserManage), userHandler.Create)
+			protected.PUT("/users/:id", can(model.PermUserManage), userHandler.Update)
+			protected.DELETE("/users/:id", can(model.PermUserManage), userHandler.Delete)
+			protected.GET("/users/:id/channels", can(model.PermUserRead), assignmentHandler.GetAgentChannels)
+			protected.PUT("/users/:id/channels", can(model.PermAssignmentManage), assignmentHandler.SetAgentChannels)
+
+			// Current tenant and invites
+			protected.GET("/tenant", tenantHandler.GetCurrent)
+			protected.GET("/tenant/usage", tenantHandler.Usage)
+			protected.PUT("/tenant", can(model.PermTenantManage), tenantHandler.UpdateCurrent)
+			protected.GET("/invites", can(model.PermUserManage), tenantHandler.ListInvites)
+			protected.POST("/invites", can(model.PermUserManage), tenantHandler.CreateInvite)
+			protected.DELETE("/invites/:id", can(model.PermUserManage), tenantHandler.RevokeInvite)
+
+			// Roles and permissions
+			protected.GET("/permissions", can(model.PermRoleManage), roleHandler.ListPermissions)
+			protected.GET("/roles", can(model.PermRoleManage), roleHandler.List)
+			protected.POST("/roles", can(model.PermRoleManage), roleHandler.Create)
+			protected.PUT("/roles/:id", can(model.PermRoleManage), roleHandler.Update)
+			protected.DELETE("/roles/:id", can(model.PermRoleManage), roleHandler.Delete)
+
+			// Auto-assignment settings
+			protected.GET("/assignment/settings", can(model.PermAssignmentManage), assignmentHandler.GetSettings)
+			protected.PUT("/assignment/settings", can(model.PermAssignmentManage), assignmentHandler.UpdateSettings)
+
+			// Agent presence; every member may see the team and set their own status
+			protected.GET("/agents/presence", presenceHandler.List)
+			protected.PUT("/agents/me/presence", presenceHandler.UpdateMe)
+
+			// Audit log of every change in the tenant, with CSV / NDJSON export
+			protected.GET("/audit-log", can(model.PermAuditRead), auditHandler.List)
+
+			// SLA policies
+			protected.GET("/sla-policies", can(model.PermSLAManage), slaHandler.List)
+			protected.POST("/sla-policies", can(model.PermSLAManage), slaHandler.Create)
+			protected.PUT("/sla-policies/:id", can(model.PermSLAManage), slaHandler.Update)
+			protected.DELETE("/sla-policies/:id", can(model.PermSLAManage), slaHandler.Delete)
+		}
+	}
+
+	// Health checks: liveness never touches dependencies, readiness probes them
+	router.GET("/health", healthHandler.Live)
+	router.GET("/health/live", healthHandler.Live)
+	router.GET("/health/ready", healthHandler.Ready)
+
+	// WebSocket endpoint (upgrades outside /api path)
+	router.GET("/ws", websocketHandler.Handle)
+
+	return &app{router: router, health: healthHandler, websocket: websocketHandler, workers: workers}
+}
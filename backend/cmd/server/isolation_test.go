@@ -0,0 +1,475 @@
+//go:build integration
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/internal/broker"
+	"backend/internal/config"
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
+)
+
+// The isolation suite runs the real router against Postgres. It needs TEST_DATABASE_URL
+// pointing at a database with migrations/postgres_init.sql applied; Redis and RabbitMQ are
+// optional, rate limits and revocation checks fail open without Redis.
+//
+//	TEST_DATABASE_URL=postgres://... go test -tags integration ./cmd/server/
+
+const isolationSecret = "isolation-test-secret"
+
+// tenantFixture is the data seeded for one tenant
+type tenantFixture struct {
+	tenantID      string
+	admin         *model.User
+	agent         *model.User
+	channel       *model.Channel
+	secret        string
+	customer      *model.Customer
+	conversation  *model.Conversation
+	message       *model.Message
+	ticket        *model.Ticket
+	deletedConv   string
+	deletedMsg    string
+	deletedTicket string
+	token         string
+}
+
+type isolationEnv struct {
+	db     *sqlx.DB
+	router *gin.Engine
+	marker string
+	a, b   *tenantFixture
+}
+
+func newIsolationEnv(t *testing.T) *isolationEnv {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sqlx.Connect("pgx", dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	gin.SetMode(gin.TestMode)
+	cfg := config.Load()
+	cfg.JWTSecret = isolationSecret
+
+	redisAddr := os.Getenv("TEST_REDIS_ADDR")
+	if redisAddr == "" {
+		redisAddr = "127.0.0.1:1"
+	}
+	redisClient := redis.NewClient(&redis.Options{Addr: redisAddr, DialTimeout: 100 * time.Millisecond, MaxRetries: -1})
+	t.Cleanup(func() { redisClient.Close() })
+
+	// The broker is never started: nothing is published, the outbox keeps the events
+	rabbit := broker.NewManager(cfg.RabbitURL(), "conversation.events", "ticket.events")
+
+	env := &isolationEnv{
+		db:     db,
+		router: newApp(cfg, db, redisClient, rabbit).router,
+		marker: "isolation" + strings.ReplaceAll(uuid.New().String(), "-", "")[:12],
+	}
+	env.a = env.seed(t, "A")
+	env.b = env.seed(t, "B")
+	return env
+}
+
+// seed creates a tenant with an admin, an agent, a channel, a customer, a conversation with a
+// message and a ticket, plus one soft-deleted conversation, message and ticket
+func (e *isolationEnv) seed(t *testing.T, name string) *tenantFixture {
+	t.Helper()
+	ctx := context.Background()
+	suffix := strings.ToLower(name) + "-" + uuid.New().String()[:8]
+
+	tenant := &model.Tenant{Name: "Isolation " + name, Plan: "free"}
+	if err := repository.NewTenantRepository(e.db).Create(ctx, tenant); err != nil {
+		t.Fatalf("seed tenant: %v", err)
+	}
+	f := &tenantFixture{tenantID: tenant.ID}
+	t.Cleanup(func() { e.purge(tenant.ID) })
+
+	users := repository.NewUserRepository(e.db)
+	f.admin = &model.User{TenantID: tenant.ID, Email: "admin-" + suffix + "@example.test", Password: "x", Name: "Admin " + name, Role: "admin"}
+	f.agent = &model.User{TenantID: tenant.ID, Email: "agent-" + suffix + "@example.test", Password: "x", Name: "Agent " + name, Role: "agent"}
+	for _, u := range []*model.User{f.admin, f.agent} {
+		if err := users.Create(ctx, u); err != nil {
+			t.Fatalf("seed user: %v", err)
+		}
+	}
+
+	f.secret = "secret-" + suffix
+	f.channel = &model.Channel{TenantID: tenant.ID, Name: "Channel " + name, Slug: "isolation-" + suffix, WebhookSecret: f.secret}
+	if err := repository.NewChannelRepository(e.db).Create(ctx, tenant.ID, f.channel); err != nil {
+		t.Fatalf("seed channel: %v", err)
+	}
+
+	f.customer = &model.Customer{TenantID: tenant.ID, ExternalID: "cust-" + suffix, Name: "Customer " + name, Channel: f.channel.Slug}
+	if err := repository.NewCustomerRepository(e.db).Create(ctx, f.customer); err != nil {
+		t.Fatalf("seed customer: %v", err)
+	}
+
+	convs := repository.NewConversationRepository(e.db)
+	msgs := repository.NewMessageRepository(e.db)
+	tickets := repository.NewTicketRepository(e.db)
+
+	newConv := func() *model.Conversation {
+		conv := &model.Conversation{TenantID: tenant.ID, CustomerID: f.customer.ID, Channel: f.channel.Slug}
+		if err := convs.Create(ctx, conv); err != nil {
+			t.Fatalf("seed conversation: %v", err)
+		}
+		return conv
+	}
+	newMessage := func(convID string) *model.Message {
+		msg := &model.Message{ConversationID: convID, SenderType: "agent", SenderID: f.admin.ID, SenderName: f.admin.Name, Message: e.marker + " from " + name}
+		if err := msgs.Create(ctx, tenant.ID, msg); err != nil {
+			t.Fatalf("seed message: %v", err)
+		}
+		return msg
+	}
+	newTicket := func(convID string) *model.Ticket {
+		code := "ISO-" + uuid.New().String()[:8]
+		ticket := &model.Ticket{
+			TenantID:       tenant.ID,
+			ConversationID: sql.NullString{String: convID, Valid: true},
+			Code:           &code,
+			Title:          e.marker + " ticket " + name,
+			Description:    "seeded",
+			Priority:       "medium",
+			CreatedByID:    sql.NullString{String: f.admin.ID, Valid: true},
+		}
+		if err := tickets.Create(ctx, ticket); err != nil {
+			t.Fatalf("seed ticket: %v", err)
+		}
+		if err := tickets.AddConversationMapping(ctx, tenant.ID, ticket.ID, convID); err != nil {
+			t.Fatalf("seed ticket mapping: %v", err)
+		}
+		return ticket
+	}
+
+	f.conversation = newConv()
+	f.message = newMessage(f.conversation.ID)
+	f.ticket = newTicket(f.conversation.ID)
+
+	f.deletedConv = newConv().ID
+	f.deletedMsg = newMessage(f.conversation.ID).ID
+	f.deletedTicket = newTicket(f.conversation.ID).ID
+	if err := convs.Delete(ctx, f.deletedConv, tenant.ID, f.admin.ID); err != nil {
+		t.Fatalf("seed deleted conversation: %v", err)
+	}
+	if err := msgs.Delete(ctx, f.deletedMsg, tenant.ID, f.admin.ID); err != nil {
+		t.Fatalf("seed deleted message: %v", err)
+	}
+	if err := tickets.Delete(ctx, f.deletedTicket, tenant.ID, f.admin.ID); err != nil {
+		t.Fatalf("seed deleted ticket: %v", err)
+	}
+
+	f.token = issueToken(t, f.admin)
+	return f
+}
+
+// purge removes everything the suite wrote for a tenant
+func (e *isolationEnv) purge(tenantID string) {
+	for _, table := range []string{"events", "outbox", "idempotency_keys", "sla_policies", "roles", "assignment_settings", "conversations", "tickets", "customers", "channels", "users"} {
+		e.db.Exec(`DELETE FROM `+table+` WHERE tenant_id = $1`, tenantID)
+	}
+	e.db.Exec(`DELETE FROM tenants WHERE id = $1`, tenantID)
+}
+
+func issueToken(t *testing.T, user *model.User) string {
+	t.Helper()
+	now := time.Now()
+	claims := &middleware.Claims{
+		UserID:   user.ID,
+		TenantID: user.TenantID,
+		Email:    user.Email,
+		Role:     user.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(isolationSecret))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return token
+}
+
+func (e *isolationEnv) do(t *testing.T, token, method, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("encode body: %v", err)
+		}
+		reader = bytes.NewReader(raw)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, "/api/v1"+path, reader)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	e.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestTenantIsolation uses tenant A's admin token, which holds every permission, against
+// tenant B's ids and checks that nothing of B can be read or changed
+func TestTenantIsolation(t *testing.T) {
+	env := newIsolationEnv(t)
+	a, b := env.a, env.b
+
+	// Positive controls: both tokens work on their own tenant
+	for _, f := range []*tenantFixture{a, b} {
+		if rec := env.do(t, f.token, http.MethodGet, "/conversations/"+f.conversation.ID, nil); rec.Code != http.StatusOK {
+			t.Fatalf("own conversation: status %d, body %s", rec.Code, rec.Body)
+		}
+	}
+
+	// Ids of another tenant, and references to them, are answered like unknown ids
+	t.Run("cross-tenant requests are not found", func(t *testing.T) {
+		cases := []struct {
+			name   string
+			method string
+			path   string
+			body   interface{}
+			want   int
+		}{
+			{"get conversation", http.MethodGet, "/conversations/" + b.conversation.ID, nil, http.StatusNotFound},
+			{"list conversation messages", http.MethodGet, "/conversations/" + b.conversation.ID + "/messages", nil, http.StatusNotFound},
+			{"list conversation tickets", http.MethodGet, "/conversations/" + b.conversation.ID + "/tickets", nil, http.StatusNotFound},
+			{"conversation timeline", http.MethodGet, "/conversations/" + b.conversation.ID + "/timeline", nil, http.StatusNotFound},
+			{"conversation reads", http.MethodGet, "/conversations/" + b.conversation.ID + "/reads", nil, http.StatusNotFound},
+			{"mark conversation read", http.MethodPost, "/conversations/" + b.conversation.ID + "/read", map[string]string{"message_id": b.message.ID}, http.StatusNotFound},
+			{"mark own conversation read with foreign message", http.MethodPost, "/conversations/" + a.conversation.ID + "/read", map[string]string{"message_id": b.message.ID}, http.StatusNotFound},
+			{"send message", http.MethodPost, "/conversations/" + b.conversation.ID + "/messages", map[string]string{"message": "intrusion"}, http.StatusNotFound},
+			{"update conversation status", http.MethodPut, "/conversations/" + b.conversation.ID, map[string]string{"status": "pending"}, http.StatusNotFound},
+			{"assign conversation", http.MethodPost, "/conversations/" + b.conversation.ID + "/assign", nil, http.StatusNotFound},
+			{"assign conversation to own agent", http.MethodPut, "/conversations/" + b.conversation.ID, map[string]string{"assigned_agent_id": a.agent.ID}, http.StatusNotFound},
+			{"assign own conversation to foreign agent", http.MethodPut, "/conversations/" + a.conversation.ID, map[string]string{"assigned_agent_id": b.agent.ID}, http.StatusNotFound},
+			{"close conversation", http.MethodPost, "/conversations/" + b.conversation.ID + "/close", nil, http.StatusNotFound},
+			{"select ticket on conversation", http.MethodPut, "/conversations/" + b.conversation.ID + "/selected-ticket", map[string]string{"ticket_id": b.ticket.ID}, http.StatusNotFound},
+			{"select foreign ticket on own conversation", http.MethodPut, "/conversations/" + a.conversation.ID + "/selected-ticket", map[string]string{"ticket_id": b.ticket.ID}, http.StatusNotFound},
+			{"create conversation for foreign customer", http.MethodPost, "/conversations", map[string]string{"customer_id": b.customer.ID, "channel": "mock"}, http.StatusNotFound},
+			{"escalate conversation", http.MethodPost, "/conversations/" + b.conversation.ID + "/escalate", map[string]string{"priority": "high"}, http.StatusNotFound},
+			{"attach foreign ticket by code", http.MethodPost, "/conversations/" + a.conversation.ID + "/escalate", map[string]string{"ticket_code": *b.ticket.Code}, http.StatusNotFound},
+			{"delete conversation", http.MethodDelete, "/conversations/" + b.conversation.ID, nil, http.StatusNotFound},
+			{"restore conversation", http.MethodPost, "/conversations/" + b.deletedConv + "/restore", nil, http.StatusNotFound},
+
+			{"edit message", http.MethodPut, "/messages/" + b.message.ID, map[string]string{"message": "intrusion"}, http.StatusNotFound},
+			{"list message edits", http.MethodGet, "/messages/" + b.message.ID + "/edits", nil, http.StatusNotFound},
+			{"delete message", http.MethodDelete, "/messages/" + b.message.ID, nil, http.StatusNotFound},
+			{"restore message", http.MethodPost, "/messages/" + b.deletedMsg + "/restore", nil, http.StatusNotFound},
+
+			{"get ticket", http.MethodGet, "/tickets/" + b.ticket.ID, nil, http.StatusNotFound},
+			{"ticket timeline", http.MethodGet, "/tickets/" + b.ticket.ID + "/timeline", nil, http.StatusNotFound},
+			{"update ticket", http.MethodPut, "/tickets/" + b.ticket.ID, map[string]string{"title": "intrusion"}, http.StatusNotFound},
+			{"assign own ticket to foreign agent", http.MethodPut, "/tickets/" + a.ticket.ID, map[string]interface{}{"assigned_agent_id": map[string]interface{}{"String": b.agent.ID, "Valid": true}}, http.StatusNotFound},
+			{"update ticket status", http.MethodPut, "/tickets/" + b.ticket.ID + "/status", map[string]string{"status": "closed"}, http.StatusNotFound},
+			{"create ticket on foreign conversation", http.MethodPost, "/tickets", map[string]string{"title": "intrusion", "description": "x", "priority": "low", "conversation_id": b.conversation.ID}, http.StatusNotFound},
+			{"create ticket for foreign agent", http.MethodPost, "/tickets", map[string]string{"title": "intrusion", "description": "x", "priority": "low", "assigned_agent_id": b.agent.ID}, http.StatusNotFound},
+			{"delete ticket", http.MethodDelete, "/tickets/" + b.ticket.ID, nil, http.StatusNotFound},
+			{"restore ticket", http.MethodPost, "/tickets/" + b.deletedTicket + "/restore", nil, http.StatusNotFound},
+
+			{"get channel", http.MethodGet, "/channels/" + b.channel.ID, nil, http.StatusNotFound},
+			{"update channel", http.MethodPut, "/channels/" + b.channel.ID, map[string]string{"name": "intrusion", "slug": b.channel.Slug}, http.StatusNotFound},
+			{"rotate channel secret", http.MethodPost, "/channels/" + b.channel.ID + "/rotate-secret", nil, http.StatusNotFound},
+			{"delete channel", http.MethodDelete, "/channels/" + b.channel.ID, nil, http.StatusNotFound},
+
+			{"update user", http.MethodPut, "/users/" + b.agent.ID, map[string]string{"name": "intrusion", "role": "admin"}, http.StatusNotFound},
+			{"delete user", http.MethodDelete, "/users/" + b.agent.ID, nil, http.StatusNotFound},
+			{"get agent channels", http.MethodGet, "/users/" + b.agent.ID + "/channels", nil, http.StatusNotFound},
+			{"set agent channels", http.MethodPut, "/users/" + b.agent.ID + "/channels", map[string][]string{"channels": {"intrusion"}}, http.StatusNotFound},
+		}
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				rec := env.do(t, a.token, tc.method, tc.path, tc.body)
+				if rec.Code != tc.want {
+					t.Errorf("%s %s: status %d, want %d; body %s", tc.method, tc.path, rec.Code, tc.want, rec.Body)
+				}
+			})
+		}
+	})
+
+	t.Run("lists only contain the caller's tenant", func(t *testing.T) {
+		foreign := []string{b.conversation.ID, b.message.ID, b.ticket.ID, b.channel.ID, b.admin.ID, b.agent.ID, b.customer.ID, b.customer.Name}
+		paths := []string{
+			"/conversations",
+			"/tickets",
+			"/channels",
+			"/users",
+			"/audit-log",
+			"/search?q=" + env.marker,
+		}
+		for _, path := range paths {
+			t.Run(path, func(t *testing.T) {
+				rec := env.do(t, a.token, http.MethodGet, path, nil)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("GET %s: status %d, body %s", path, rec.Code, rec.Body)
+				}
+				body := rec.Body.String()
+				for _, id := range foreign {
+					if strings.Contains(body, id) {
+						t.Errorf("GET %s leaks %q of tenant B", path, id)
+					}
+				}
+			})
+		}
+
+		// Sanity check that the lists are not empty for the caller's own data
+		if rec := env.do(t, a.token, http.MethodGet, "/conversations", nil); !strings.Contains(rec.Body.String(), a.conversation.ID) {
+			t.Errorf("GET /conversations does not list tenant A's conversation: %s", rec.Body)
+		}
+	})
+
+	t.Run("tenant B data is unchanged", func(t *testing.T) {
+		env.assertUnchanged(t)
+	})
+}
+
+// assertUnchanged reads tenant B's rows back and compares them with the seed
+func (e *isolationEnv) assertUnchanged(t *testing.T) {
+	ctx := context.Background()
+	a, b := e.a, e.b
+
+	convs := repository.NewConversationRepository(e.db)
+	conv, err := convs.GetByID(ctx, b.conversation.ID, b.tenantID)
+	if err != nil {
+		t.Fatalf("conversation of B is gone: %v", err)
+	}
+	if conv.Status != "open" || conv.AssignedAgentID.Valid || conv.SelectedTicketID.Valid {
+		t.Errorf("conversation of B changed: status %q, agent %v, selected ticket %v", conv.Status, conv.AssignedAgentID, conv.SelectedTicketID)
+	}
+	if _, err := convs.GetDeleted(ctx, b.deletedConv, b.tenantID); err != nil {
+		t.Errorf("deleted conversation of B was restored: %v", err)
+	}
+	own, err := convs.GetByID(ctx, a.conversation.ID, a.tenantID)
+	if err != nil {
+		t.Fatalf("conversation of A: %v", err)
+	}
+	if own.AssignedAgentID.String == b.agent.ID || own.SelectedTicketID.String == b.ticket.ID {
+		t.Errorf("conversation of A was linked to tenant B: agent %v, selected ticket %v", own.AssignedAgentID, own.SelectedTicketID)
+	}
+
+	msgs := repository.NewMessageRepository(e.db)
+	msg, err := msgs.GetByID(ctx, b.message.ID, b.tenantID)
+	if err != nil {
+		t.Fatalf("message of B is gone: %v", err)
+	}
+	if msg.Message != b.message.Message || msg.EditedAt.Valid {
+		t.Errorf("message of B changed: %q, edited at %v", msg.Message, msg.EditedAt)
+	}
+	if edits, err := msgs.ListEdits(ctx, b.message.ID, b.tenantID); err != nil || len(edits) != 0 {
+		t.Errorf("message of B has edits: %v, %v", edits, err)
+	}
+	if _, err := msgs.GetDeleted(ctx, b.deletedMsg, b.tenantID); err != nil {
+		t.Errorf("deleted message of B was restored: %v", err)
+	}
+	page, _, err := msgs.ListPage(ctx, b.conversation.ID, b.tenantID, model.MessagePageQuery{Limit: 10})
+	if err != nil || len(page) != 1 {
+		t.Errorf("conversation of B has %d messages, want 1 (%v)", len(page), err)
+	}
+
+	tickets := repository.NewTicketRepository(e.db)
+	ticket, err := tickets.GetByID(ctx, b.ticket.ID, b.tenantID)
+	if err != nil {
+		t.Fatalf("ticket of B is gone: %v", err)
+	}
+	if ticket.Title != b.ticket.Title || ticket.Status != "open" || ticket.AssignedAgentID.Valid {
+		t.Errorf("ticket of B changed: title %q, status %q, agent %v", ticket.Title, ticket.Status, ticket.AssignedAgentID)
+	}
+	if _, err := tickets.GetDeleted(ctx, b.deletedTicket, b.tenantID); err != nil {
+		t.Errorf("deleted ticket of B was restored: %v", err)
+	}
+	if linked, err := tickets.ListByConversationID(ctx, b.conversation.ID, a.tenantID); err != nil || len(linked) != 0 {
+		t.Errorf("tenant A has %d tickets on B's conversation (%v)", len(linked), err)
+	}
+	ownTicket, err := tickets.GetByID(ctx, a.ticket.ID, a.tenantID)
+	if err != nil {
+		t.Fatalf("ticket of A: %v", err)
+	}
+	if ownTicket.AssignedAgentID.String == b.agent.ID {
+		t.Errorf("ticket of A was assigned to tenant B's agent")
+	}
+	var foreignLinks int
+	if err := e.db.Get(&foreignLinks, `SELECT COUNT(*) FROM tickets WHERE tenant_id = $1 AND (conversation_id = $2 OR assigned_agent_id = $3)`, a.tenantID, b.conversation.ID, b.agent.ID); err != nil || foreignLinks != 0 {
+		t.Errorf("tenant A has %d tickets referencing tenant B (%v)", foreignLinks, err)
+	}
+
+	channels := repository.NewChannelRepository(e.db)
+	ch, err := channels.GetBySlugWithSecret(ctx, b.channel.Slug)
+	if err != nil {
+		t.Fatalf("channel of B is gone: %v", err)
+	}
+	if ch.Name != b.channel.Name || ch.WebhookSecret != b.secret {
+		t.Errorf("channel of B changed: name %q, secret rotated %v", ch.Name, ch.WebhookSecret != b.secret)
+	}
+
+	users := repository.NewUserRepository(e.db)
+	agent, err := users.GetByID(ctx, b.agent.ID, b.tenantID)
+	if err != nil {
+		t.Fatalf("agent of B is gone: %v", err)
+	}
+	if agent.Name != b.agent.Name || agent.Role != b.agent.Role {
+		t.Errorf("agent of B changed: name %q, role %q", agent.Name, agent.Role)
+	}
+	skills, err := repository.NewAssignmentRepository(e.db).GetAgentChannels(ctx, b.agent.ID, b.tenantID)
+	if err != nil || len(skills) != 0 {
+		t.Errorf("agent of B has channels %v (%v)", skills, err)
+	}
+}
+
+// TestRepositoriesRequireTenant checks that the scoped repository methods refuse to run
+// without a tenant and do not match rows of another tenant
+func TestRepositoriesRequireTenant(t *testing.T) {
+	env := newIsolationEnv(t)
+	ctx := context.Background()
+	b := env.b
+
+	if _, err := repository.NewConversationRepository(env.db).GetByID(ctx, b.conversation.ID, ""); !errors.Is(err, repository.ErrTenantRequired) {
+		t.Errorf("conversation without tenant: got %v, want ErrTenantRequired", err)
+	}
+	if err := repository.NewTicketRepository(env.db).UpdateStatus(ctx, b.ticket.ID, "", "closed"); !errors.Is(err, repository.ErrTenantRequired) {
+		t.Errorf("ticket status without tenant: got %v, want ErrTenantRequired", err)
+	}
+	if err := repository.NewTicketRepository(env.db).UpdateStatus(ctx, b.ticket.ID, env.a.tenantID, "closed"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("ticket status from tenant A: got %v, want sql.ErrNoRows", err)
+	}
+	if err := repository.NewConversationRepository(env.db).UpdateStatus(ctx, b.conversation.ID, env.a.tenantID, "closed"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("conversation status from tenant A: got %v, want sql.ErrNoRows", err)
+	}
+	if err := repository.NewMessageRepository(env.db).Create(ctx, env.a.tenantID, &model.Message{ConversationID: b.conversation.ID, SenderType: "agent", SenderID: env.a.admin.ID, SenderName: "A", Message: "intrusion"}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("message into tenant B's conversation: got %v, want sql.ErrNoRows", err)
+	}
+	if err := repository.NewUserRepository(env.db).Delete(ctx, b.agent.ID, env.a.tenantID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("delete tenant B's user from A: got %v, want sql.ErrNoRows", err)
+	}
+
+	env.assertUnchanged(t)
+}
@@ -1,16 +1,21 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"backend/internal/broker"
 	"backend/internal/config"
-	"backend/internal/handler"
-	"backend/internal/middleware"
-	"backend/internal/repository"
-	"backend/internal/service"
+	"backend/internal/logging"
+	"backend/internal/metrics"
 
-	"github.com/gin-gonic/gin"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
 )
@@ -18,6 +23,11 @@ import (
 func main() {
 	// Load configuration
 	cfg := config.Load()
+	logging.Setup(cfg.LogFormat, cfg.LogLevel)
+
+	// SIGINT/SIGTERM start a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Determine DSN
 	dsn := os.Getenv("DATABASE_URL")
@@ -48,136 +58,116 @@ func main() {
 		}
 	}
 	if dsn == "" {
-		log.Fatal("DATABASE_URL is not set and could not be constructed from env")
+		slog.Error("DATABASE_URL is not set and could not be constructed from env")
+		os.Exit(1)
 	}
 
 	db, err := sqlx.Connect("pgx", dsn)
 	if err != nil {
-		log.Fatalf("Failed to connect to database (%s): %v", dsn, err)
+		slog.Error("failed to connect to database", logging.Err(err))
+		os.Exit(1)
 	}
 	defer db.Close()
+	metrics.RegisterDB(db.DB, "postgres")
 
 	// Initialize Redis
 	redisClient := config.NewRedisClient(cfg)
 	defer redisClient.Close()
 
-	// Initialize RabbitMQ
-	rabbitConn, rabbitCh := config.NewRabbitMQ(cfg)
-	if rabbitConn != nil {
-		defer rabbitConn.Close()
-		defer rabbitCh.Close()
+	// RabbitMQ connection, re-established in the background whenever the broker goes away
+	rabbit := broker.NewManager(cfg.RabbitURL(), "conversation.events", "ticket.events")
+	rabbit.Start()
+	defer rabbit.Close()
+
+	a := newApp(cfg, db, redisClient, rabbit)
+
+	// Background workers run until shutdown cancels workerCtx
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	for _, run := range a.workers {
+		workers.Add(1)
+		go func(run func(context.Context)) {
+			defer workers.Done()
+			run(workerCtx)
+		}(run)
 	}
 
-	// Initialize repositories
-	userRepo := repository.NewUserRepository(db)
-	conversationRepo := repository.NewConversationRepository(db)
-	messageRepo := repository.NewMessageRepository(db)
-	ticketRepo := repository.NewTicketRepository(db)
-	customerRepo := repository.NewCustomerRepository(db)
-	eventRepo := repository.NewEventRepository(db)
-	channelRepo := repository.NewChannelRepository(db)
-
-	// Initialize services
-	authService := service.NewAuthService(userRepo, cfg.JWTSecret)
-	conversationService := service.NewConversationService(conversationRepo, messageRepo, customerRepo, eventRepo, ticketRepo, redisClient, rabbitCh)
-	ticketService := service.NewTicketService(ticketRepo, conversationRepo, eventRepo, rabbitCh)
-	userService := service.NewUserService(userRepo)
-	channelService := service.NewChannelService(channelRepo)
-
-	// Initialize handlers
-	authHandler := handler.NewAuthHandler(authService)
-	conversationHandler := handler.NewConversationHandler(conversationService)
-	ticketHandler := handler.NewTicketHandler(ticketService)
-	webhookHandler := handler.NewWebhookHandler(conversationService)
-	userHandler := handler.NewUserHandler(userService)
-	channelHandler := handler.NewChannelHandler(channelService)
-
-	messageHandler := handler.NewMessageHandler(conversationService)
-
-	// WebSocket handler (for realtime)
-	websocketHandler := handler.NewWebsocketHandler(rabbitConn, cfg.JWTSecret)
-
-	// Initialize Gin router
-	router := gin.Default()
-
-	// CORS middleware
-	router.Use(middleware.CORS())
-
-	// API v1 routes
-	v1 := router.Group("/api/v1")
-	{
-		// Public routes
-		v1.POST("/auth/login", authHandler.Login)
-		v1.POST("/auth/register", authHandler.Register)
-
-		// Webhook (simulated channel)
-		v1.POST("/channel/webhook", webhookHandler.HandleWebhook)
-
-		// Protected routes
-		protected := v1.Group("")
-		protected.Use(middleware.AuthMiddleware(cfg.JWTSecret))
-		{
-			// Channels
-			protected.GET("/channels", channelHandler.List)
-			protected.GET("/channels/:id", channelHandler.GetByID)
-			protected.POST("/channels", channelHandler.Create)
-			protected.PUT("/channels/:id", channelHandler.Update)
-			protected.DELETE("/channels/:id", channelHandler.Delete)
-
-			// Additional conversation CRUD for frontend
-			protected.POST("/conversations", conversationHandler.Create)
-			protected.PUT("/conversations/:id", conversationHandler.Update)
-			protected.DELETE("/conversations/:id", conversationHandler.Delete)
-			// Conversations
-			protected.GET("/conversations", conversationHandler.List)
-			protected.GET("/conversations/:id", conversationHandler.GetByID)
-			protected.POST("/conversations/:id/messages", conversationHandler.SendMessage)
-			protected.POST("/conversations/:id/assign", conversationHandler.Assign)
-			protected.POST("/conversations/:id/close", conversationHandler.Close)
-			// Tickets per conversation and selection
-			protected.GET("/conversations/:id/tickets", conversationHandler.ListTickets)
-			protected.PUT("/conversations/:id/selected-ticket", conversationHandler.SetSelectedTicket)
-
-			// Messages
-			protected.DELETE("/messages/:id", messageHandler.Delete)
-
-			// Tickets
-			protected.GET("/tickets", ticketHandler.List)
-			protected.GET("/tickets/:id", ticketHandler.GetByID)
-			protected.POST("/conversations/:id/escalate", ticketHandler.Escalate)
-			// Extra ticket CRUD for frontend
-			protected.POST("/tickets", ticketHandler.Create)
-			protected.PUT("/tickets/:id", ticketHandler.Update)
-			protected.DELETE("/tickets/:id", ticketHandler.Delete)
-
-			// Admin only routes
-			admin := protected.Group("")
-			admin.Use(middleware.AdminOnly())
-			{
-				admin.PUT("/tickets/:id/status", ticketHandler.UpdateStatus)
-				admin.GET("/users", userHandler.List)
-				admin.POST("/users", userHandler.Create)
-				admin.PUT("/users/:id", userHandler.Update)
-				admin.DELETE("/users/:id", userHandler.Delete)
-			}
+	// Start server
+	port := cfg.ServerPort
+	if port == "" {
+		port = "8080"
+	}
+	srv := &http.Server{
+		Addr:              ":" + port,
+		Handler:           a.router,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	serverErr := make(chan error, 2)
+	go func() {
+		slog.Info("server starting", "port", port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
 		}
+	}()
+
+	// Prometheus metrics on a separate port that is not exposed with the API
+	var metricsSrv *http.Server
+	if cfg.MetricsPort != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		metricsSrv = &http.Server{
+			Addr:              ":" + cfg.MetricsPort,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			slog.Info("metrics server starting", "port", cfg.MetricsPort)
+			if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- err
+			}
+		}()
 	}
 
-	// Health check
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "healthy"})
-	})
+	select {
+	case err := <-serverErr:
+		slog.Error("failed to start server", logging.Err(err))
+		os.Exit(1)
+	case <-ctx.Done():
+	}
+	stop()
+	slog.Info("shutting down", "drain", cfg.ShutdownDrainDelay, "timeout", cfg.ShutdownTimeout)
 
-	// WebSocket endpoint (upgrades outside /api path)
-	router.GET("/ws", websocketHandler.Handle)
+	// Fail readiness and keep serving while load balancers notice and stop routing here
+	a.health.Drain()
+	time.Sleep(cfg.ShutdownDrainDelay)
 
-	// Start server
-	port := cfg.ServerPort
-	if port == "" {
-		port = "8080"
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// Stop accepting connections and wait for in-flight requests
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("http server shutdown incomplete", logging.Err(err))
 	}
-	log.Printf("Server starting on port %s", port)
-	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+
+	// Upgraded websocket connections are not tracked by the server
+	a.websocket.Shutdown(shutdownCtx)
+	if metricsSrv != nil {
+		if err := metricsSrv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("metrics server shutdown incomplete", logging.Err(err))
+		}
+	}
+
+	// Stop the RabbitMQ consumer, SLA checker and outbox relay
+	stopWorkers()
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		slog.Info("shutdown complete")
+	case <-shutdownCtx.Done():
+		slog.Warn("shutdown timed out waiting for background workers")
 	}
 }
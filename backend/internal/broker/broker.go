@@ -0,0 +1,234 @@
+// Package broker keeps a RabbitMQ connection alive across broker restarts
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"backend/internal/logging"
+	"backend/internal/metrics"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var (
+	ErrUnavailable = errors.New("rabbitmq unavailable")
+	ErrNacked      = errors.New("rabbitmq did not confirm the message")
+)
+
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// Manager owns the RabbitMQ connection. It reconnects with exponential backoff, re-declares
+// the topic exchanges on every connection, publishes on a channel in confirm mode and
+// restarts consumers registered with Consume after a reconnect.
+type Manager struct {
+	url       string
+	exchanges []string
+
+	mu    sync.RWMutex
+	conn  *amqp.Connection
+	pub   *amqp.Channel
+	ready chan struct{} // closed while connected, replaced on disconnect
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewManager(url string, exchanges ...string) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		url:       url,
+		exchanges: exchanges,
+		ready:     make(chan struct{}),
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+}
+
+// Start connects in the background; callers do not wait for the first connection
+func (m *Manager) Start() {
+	go m.run()
+}
+
+// Close stops reconnecting and closes the connection
+func (m *Manager) Close() {
+	m.cancel()
+	<-m.done
+}
+
+// Connected reports whether a connection and the publish channel are open
+func (m *Manager) Connected() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.pub != nil && !m.pub.IsClosed()
+}
+
+func (m *Manager) run() {
+	defer close(m.done)
+	ctx := m.ctx
+	delay := minReconnectDelay
+
+	for {
+		conn, pub, err := m.connect()
+		if err != nil {
+			slog.WarnContext(ctx, "rabbitmq: connect failed", "retry_in", delay.String(), logging.Err(err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, maxReconnectDelay)
+			continue
+		}
+		delay = minReconnectDelay
+
+		connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+		pubClosed := pub.NotifyClose(make(chan *amqp.Error, 1))
+		m.setConnected(conn, pub)
+		slog.InfoContext(ctx, "connected to RabbitMQ")
+
+		select {
+		case <-ctx.Done():
+			m.setDisconnected()
+			conn.Close()
+			return
+		case err := <-connClosed:
+			slog.WarnContext(ctx, "rabbitmq: connection lost", logging.Err(err))
+		case err := <-pubClosed:
+			slog.WarnContext(ctx, "rabbitmq: publish channel closed", logging.Err(err))
+		}
+		m.setDisconnected()
+		conn.Close()
+		metrics.RabbitReconnects.Inc()
+	}
+}
+
+// connect dials, declares the exchanges and opens the publish channel in confirm mode
+func (m *Manager) connect() (*amqp.Connection, *amqp.Channel, error) {
+	conn, err := amqp.Dial(m.url)
+	if err != nil {
+		return nil, nil, err
+	}
+	pub, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	for _, exchange := range m.exchanges {
+		if err := pub.ExchangeDeclare(exchange, "topic", true, false, false, false, nil); err != nil {
+			conn.Close()
+			return nil, nil, fmt.Errorf("declare exchange %s: %w", exchange, err)
+		}
+	}
+	if err := pub.Confirm(false); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, pub, nil
+}
+
+func (m *Manager) setConnected(conn *amqp.Connection, pub *amqp.Channel) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.conn = conn
+	m.pub = pub
+	close(m.ready)
+	metrics.RabbitConnected.Set(1)
+}
+
+func (m *Manager) setDisconnected() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.conn == nil {
+		return
+	}
+	m.conn = nil
+	m.pub = nil
+	m.ready = make(chan struct{})
+	metrics.RabbitConnected.Set(0)
+}
+
+// Publish sends msg and waits for the broker to confirm it. It returns ErrUnavailable
+// without sending while disconnected, so callers can keep the message for later.
+func (m *Manager) Publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error {
+	m.mu.RLock()
+	pub := m.pub
+	m.mu.RUnlock()
+	if pub == nil || pub.IsClosed() {
+		return ErrUnavailable
+	}
+
+	confirm, err := pub.PublishWithDeferredConfirmWithContext(ctx, exchange, routingKey, false, false, msg)
+	if err != nil {
+		return err
+	}
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return ErrNacked
+	}
+	return nil
+}
+
+// Consume calls fn with a new channel whenever a connection is available, until ctx is
+// cancelled. fn should consume until ctx is done or its deliveries stop; it is called
+// again after the broker comes back.
+func (m *Manager) Consume(ctx context.Context, name string, fn func(ctx context.Context, ch *amqp.Channel) error) {
+	for {
+		conn, err := m.connection(ctx)
+		if err != nil {
+			return
+		}
+		ch, err := conn.Channel()
+		if err == nil {
+			err = fn(ctx, ch)
+			ch.Close()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		slog.WarnContext(ctx, "rabbitmq: consumer interrupted, restarting", "consumer", name, logging.Err(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(minReconnectDelay):
+		}
+	}
+}
+
+// connection waits until the manager is connected or ctx is cancelled
+func (m *Manager) connection(ctx context.Context) (*amqp.Connection, error) {
+	for {
+		m.mu.RLock()
+		conn, ready := m.conn, m.ready
+		m.mu.RUnlock()
+		if conn != nil && !conn.IsClosed() {
+			return conn, nil
+		}
+		if conn != nil {
+			// closed but not yet noticed by run; wait for the reconnect
+			ready = nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-m.ctx.Done():
+			return nil, ErrUnavailable
+		case <-ready:
+		case <-time.After(minReconnectDelay):
+		}
+	}
+}
@@ -3,11 +3,11 @@ package config
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"strconv"
 	"time"
 
-	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -25,6 +25,47 @@ type Config struct {
 	RabbitPass string
 	JWTSecret  string
 	ServerPort string
+	LogFormat  string
+	LogLevel   string
+
+	// MetricsPort serves /metrics on its own listener, kept off the public API; empty disables it
+	MetricsPort string
+
+	SLACheckInterval   time.Duration
+	OutboxPollInterval time.Duration
+	AccessTokenTTL     time.Duration
+	RefreshTokenTTL    time.Duration
+	ShutdownTimeout    time.Duration
+
+	// How long readiness reports draining before the server stops accepting connections, so
+	// load balancers take the instance out of rotation first
+	ShutdownDrainDelay time.Duration
+
+	// How long after sending agents may edit or delete their own messages
+	MessageEditWindow time.Duration
+
+	// Soft-deleted conversations, messages and tickets are purged after DeletedRetention
+	DeletedRetention       time.Duration
+	RetentionCheckInterval time.Duration
+
+	// Idempotency keys are purged by the retention job after IdempotencyKeyTTL
+	IdempotencyKeyTTL time.Duration
+
+	PlatformAPIKey      string
+	TenantSignupEnabled bool
+
+	// Token bucket limits, each refilled over RateLimitWindow
+	RateLimitWindow            time.Duration
+	RateLimitUserRequests      int
+	RateLimitTenantRequests    int
+	RateLimitWebhookRequests   int
+	RateLimitWebhookIPRequests int
+	RateLimitAuthRequests      int
+
+	// Monthly message quotas per plan; 0 means unlimited
+	QuotaMessagesFree       int
+	QuotaMessagesPro        int
+	QuotaMessagesEnterprise int
 }
 
 func Load() *Config {
@@ -42,6 +83,39 @@ func Load() *Config {
 		RabbitPass: getEnv("RABBITMQ_PASSWORD", "guest"),
 		JWTSecret:  getEnv("JWT_SECRET", "your-secret-key"),
 		ServerPort: getEnv("SERVER_PORT", "8080"),
+		LogFormat:  getEnv("LOG_FORMAT", "json"),
+		LogLevel:   getEnv("LOG_LEVEL", "info"),
+
+		MetricsPort: getEnv("METRICS_PORT", "9090"),
+
+		SLACheckInterval:   time.Duration(getEnvInt("SLA_CHECK_INTERVAL_SECONDS", 60)) * time.Second,
+		OutboxPollInterval: time.Duration(getEnvInt("OUTBOX_POLL_INTERVAL_MS", 250)) * time.Millisecond,
+		AccessTokenTTL:     time.Duration(getEnvInt("JWT_ACCESS_TTL_MINUTES", 15)) * time.Minute,
+		RefreshTokenTTL:    time.Duration(getEnvInt("JWT_REFRESH_TTL_HOURS", 720)) * time.Hour,
+		ShutdownTimeout:    time.Duration(getEnvInt("SHUTDOWN_TIMEOUT_SECONDS", 20)) * time.Second,
+
+		ShutdownDrainDelay: time.Duration(getEnvNonNegInt("SHUTDOWN_DRAIN_SECONDS", 5)) * time.Second,
+
+		MessageEditWindow: time.Duration(getEnvInt("MESSAGE_EDIT_WINDOW_MINUTES", 15)) * time.Minute,
+
+		DeletedRetention:       time.Duration(getEnvInt("DELETED_RETENTION_DAYS", 30)) * 24 * time.Hour,
+		RetentionCheckInterval: time.Duration(getEnvInt("RETENTION_CHECK_INTERVAL_MINUTES", 60)) * time.Minute,
+
+		IdempotencyKeyTTL: time.Duration(getEnvInt("IDEMPOTENCY_KEY_TTL_HOURS", 24)) * time.Hour,
+
+		PlatformAPIKey:      getEnv("PLATFORM_API_KEY", ""),
+		TenantSignupEnabled: getEnv("TENANT_SIGNUP_ENABLED", "false") == "true",
+
+		RateLimitWindow:            time.Duration(getEnvInt("RATE_LIMIT_WINDOW_SECONDS", 60)) * time.Second,
+		RateLimitUserRequests:      getEnvInt("RATE_LIMIT_REQUESTS", 100),
+		RateLimitTenantRequests:    getEnvInt("RATE_LIMIT_TENANT_REQUESTS", 1000),
+		RateLimitWebhookRequests:   getEnvInt("RATE_LIMIT_WEBHOOK_REQUESTS", 600),
+		RateLimitWebhookIPRequests: getEnvInt("RATE_LIMIT_WEBHOOK_IP_REQUESTS", 1200),
+		RateLimitAuthRequests:      getEnvInt("RATE_LIMIT_AUTH_REQUESTS", 20),
+
+		QuotaMessagesFree:       getEnvNonNegInt("QUOTA_MESSAGES_FREE", 10000),
+		QuotaMessagesPro:        getEnvNonNegInt("QUOTA_MESSAGES_PRO", 250000),
+		QuotaMessagesEnterprise: getEnvNonNegInt("QUOTA_MESSAGES_ENTERPRISE", 0),
 	}
 }
 
@@ -61,47 +135,42 @@ func NewRedisClient(cfg *Config) *redis.Client {
 	defer cancel()
 
 	if err := client.Ping(ctx).Err(); err != nil {
-		log.Printf("Warning: Failed to connect to Redis: %v", err)
+		slog.Warn("failed to connect to Redis", "error", err)
 	} else {
-		log.Println("Connected to Redis")
+		slog.Info("connected to Redis")
 	}
 
 	return client
 }
 
-func NewRabbitMQ(cfg *Config) (*amqp.Connection, *amqp.Channel) {
-	url := fmt.Sprintf("amqp://%s:%s@%s:%s/",
-		cfg.RabbitUser, cfg.RabbitPass, cfg.RabbitHost, cfg.RabbitPort)
-
-	conn, err := amqp.Dial(url)
-	if err != nil {
-		log.Printf("Warning: Failed to connect to RabbitMQ: %v", err)
-		return nil, nil
-	}
+// RabbitURL is the AMQP URL of the broker
+func (c *Config) RabbitURL() string {
+	return fmt.Sprintf("amqp://%s:%s@%s:%s/", c.RabbitUser, c.RabbitPass, c.RabbitHost, c.RabbitPort)
+}
 
-	ch, err := conn.Channel()
-	if err != nil {
-		log.Printf("Warning: Failed to open RabbitMQ channel: %v", err)
-		conn.Close()
-		return nil, nil
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
 	}
+	return defaultValue
+}
 
-	// Declare exchanges and queues
-	exchanges := []string{"conversation.events", "ticket.events"}
-	for _, exchange := range exchanges {
-		err = ch.ExchangeDeclare(exchange, "topic", true, false, false, false, nil)
-		if err != nil {
-			log.Printf("Warning: Failed to declare exchange %s: %v", exchange, err)
+// getEnvInt reads a positive integer; anything else falls back to defaultValue
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			return n
 		}
 	}
-
-	log.Println("Connected to RabbitMQ")
-	return conn, ch
+	return defaultValue
 }
 
-func getEnv(key, defaultValue string) string {
+// getEnvNonNegInt is getEnvInt for settings where 0 has a meaning (unlimited, disabled)
+func getEnvNonNegInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
-		return value
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			return n
+		}
 	}
 	return defaultValue
 }
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetEnvInt(t *testing.T) {
+	cases := []struct {
+		value string
+		want  int
+	}{
+		{"", 7},
+		{"12", 12},
+		{"0", 7},
+		{"-3", 7},
+		{"abc", 7},
+	}
+	for _, tc := range cases {
+		t.Setenv("TEST_ENV_INT", tc.value)
+		if got := getEnvInt("TEST_ENV_INT", 7); got != tc.want {
+			t.Errorf("getEnvInt(%q) = %d, want %d", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestGetEnvNonNegInt(t *testing.T) {
+	cases := []struct {
+		value string
+		want  int
+	}{
+		{"", 7},
+		{"12", 12},
+		{"0", 0},
+		{"-3", 7},
+		{"abc", 7},
+	}
+	for _, tc := range cases {
+		t.Setenv("TEST_ENV_INT", tc.value)
+		if got := getEnvNonNegInt("TEST_ENV_INT", 7); got != tc.want {
+			t.Errorf("getEnvNonNegInt(%q) = %d, want %d", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestLoadAcceptsZeroWhereItMeansUnlimited(t *testing.T) {
+	t.Setenv("QUOTA_MESSAGES_FREE", "0")
+	t.Setenv("QUOTA_MESSAGES_PRO", "0")
+	t.Setenv("SHUTDOWN_DRAIN_SECONDS", "0")
+	t.Setenv("RATE_LIMIT_REQUESTS", "0")
+
+	cfg := Load()
+	if cfg.QuotaMessagesFree != 0 || cfg.QuotaMessagesPro != 0 {
+		t.Errorf("quotas = %d/%d, want 0/0 (unlimited)", cfg.QuotaMessagesFree, cfg.QuotaMessagesPro)
+	}
+	if cfg.ShutdownDrainDelay != 0 {
+		t.Errorf("ShutdownDrainDelay = %v, want 0", cfg.ShutdownDrainDelay)
+	}
+	if cfg.RateLimitUserRequests != 100 {
+		t.Errorf("RateLimitUserRequests = %d, want the default 100", cfg.RateLimitUserRequests)
+	}
+	if cfg.ShutdownTimeout != 20*time.Second {
+		t.Errorf("ShutdownTimeout = %v, want the default 20s", cfg.ShutdownTimeout)
+	}
+}
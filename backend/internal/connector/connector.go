@@ -0,0 +1,69 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"backend/internal/model"
+)
+
+// ErrPermanent marks a delivery failure that must not be retried (e.g. a 4xx from the provider)
+var ErrPermanent = errors.New("permanent delivery failure")
+
+// OutboundMessage is an agent reply to be delivered back to the customer's channel
+type OutboundMessage struct {
+	TenantID           string        `json:"tenant_id"`
+	Channel            model.Channel `json:"-"`
+	CustomerExternalID string        `json:"customer_external_id"`
+	Message            model.Message `json:"message"`
+}
+
+// Result reports how far a delivery got; Delivered is set when the provider confirmed receipt
+type Result struct {
+	ExternalID string
+	Delivered  bool
+}
+
+// Connector delivers outbound messages to one kind of channel provider
+type Connector interface {
+	Send(ctx context.Context, msg OutboundMessage) (*Result, error)
+}
+
+// Registry resolves the connector for a channel: explicit registrations by slug win,
+// otherwise channels with an outbound URL use the generic HTTP webhook connector. All HTTP
+// connectors share one client, so deliveries reuse pooled connections.
+type Registry struct {
+	mu         sync.RWMutex
+	connectors map[string]Connector
+	client     *http.Client
+}
+
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{
+		connectors: make(map[string]Connector),
+		client:     NewHTTPClient(timeout),
+	}
+}
+
+func (r *Registry) Register(slug string, c Connector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.connectors[slug] = c
+}
+
+// Resolve returns nil when the channel has no way to deliver outbound messages
+func (r *Registry) Resolve(ch *model.Channel) Connector {
+	r.mu.RLock()
+	c, ok := r.connectors[ch.Slug]
+	r.mu.RUnlock()
+	if ok {
+		return c
+	}
+	if ch.OutboundURL != "" {
+		return NewHTTPConnector(ch.OutboundURL, r.client)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrUnsafeURL rejects outbound URLs that are not https or that point into a private network
+var ErrUnsafeURL = errors.New("outbound url must be https and resolve to a public address")
+
+// sharedAddressSpace is the carrier-grade NAT range, which netip does not classify as private
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// HTTPConnector POSTs the outbound message as JSON to a provider webhook URL.
+// A 2xx response counts as sent; a JSON body with "status":"delivered" counts as delivered.
+// Connections to loopback, link-local and private addresses are refused at dial time, so a
+// host that resolves differently after ValidateURL still cannot reach internal services.
+type HTTPConnector struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPConnector posts to url through client, which should come from NewHTTPClient so
+// connections keep the private address checks
+func NewHTTPConnector(url string, client *http.Client) *HTTPConnector {
+	return &HTTPConnector{url: url, client: client}
+}
+
+// NewHTTPClient returns a client that refuses private addresses and non-https redirects.
+// It is meant to be shared: its transport pools connections across every outbound URL.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: refusePrivate}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Scheme != "https" {
+				return ErrUnsafeURL
+			}
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			return nil
+		},
+	}
+}
+
+// ValidateURL checks that raw is an https URL whose host resolves only to public addresses
+func ValidateURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" || u.User != nil {
+		return ErrUnsafeURL
+	}
+	if ip, err := netip.ParseAddr(u.Hostname()); err == nil {
+		if !publicAddr(ip) {
+			return ErrUnsafeURL
+		}
+		return nil
+	}
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil || len(ips) == 0 {
+		return fmt.Errorf("%w: cannot resolve %s", ErrUnsafeURL, u.Hostname())
+	}
+	for _, ip := range ips {
+		if !publicAddr(ip) {
+			return ErrUnsafeURL
+		}
+	}
+	return nil
+}
+
+// refusePrivate is a net.Dialer Control hook run with the resolved address of each connection
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil || !publicAddr(ap.Addr()) {
+		return ErrUnsafeURL
+	}
+	return nil
+}
+
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+func (c *HTTPConnector) Send(ctx context.Context, msg OutboundMessage) (*Result, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"tenant_id":            msg.TenantID,
+		"channel":              msg.Channel.Slug,
+		"customer_external_id": msg.CustomerExternalID,
+		"conversation_id":      msg.Message.ConversationID,
+		"message_id":           msg.Message.ID,
+		"sender_name":          msg.Message.SenderName,
+		"message":              msg.Message.Message,
+		"created_at":           msg.Message.CreatedAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPermanent, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPermanent, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if errors.Is(err, ErrUnsafeURL) {
+		return nil, fmt.Errorf("%w: %v", ErrPermanent, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return nil, fmt.Errorf("%w: provider responded %d", ErrPermanent, resp.StatusCode)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("provider responded %d", resp.StatusCode)
+	}
+
+	var out struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+
+	return &Result{ExternalID: out.ID, Delivered: out.Status == "delivered"}, nil
+}
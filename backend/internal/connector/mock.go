@@ -0,0 +1,44 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// MockConnector keeps outbound messages in memory instead of calling a provider.
+// It is registered for the "mock" channel slug for local testing.
+type MockConnector struct {
+	mu       sync.Mutex
+	sent     []OutboundMessage
+	failures int
+}
+
+func NewMockConnector() *MockConnector {
+	return &MockConnector{}
+}
+
+// FailNext makes the next n sends fail with a retryable error
+func (c *MockConnector) FailNext(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures = n
+}
+
+// Sent returns a copy of the messages delivered so far
+func (c *MockConnector) Sent() []OutboundMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]OutboundMessage(nil), c.sent...)
+}
+
+func (c *MockConnector) Send(ctx context.Context, msg OutboundMessage) (*Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failures > 0 {
+		c.failures--
+		return nil, errors.New("mock connector: simulated failure")
+	}
+	c.sent = append(c.sent, msg)
+	return &Result{ExternalID: msg.Message.ID, Delivered: true}, nil
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"net/http"
+
+	"backend/internal/model"
+	"backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AssignmentHandler struct {
+	assignService *service.AssignmentService
+}
+
+func NewAssignmentHandler(assignService *service.AssignmentService) *AssignmentHandler {
+	return &AssignmentHandler{assignService: assignService}
+}
+
+func (h *AssignmentHandler) GetSettings(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	settings, err := h.assignService.GetSettings(c.Request.Context(), tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: settings})
+}
+
+func (h *AssignmentHandler) UpdateSettings(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	userID := c.GetString("user_id")
+
+	var req model.UpdateAssignmentSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: "Invalid request: " + err.Error()})
+		return
+	}
+
+	settings, err := h.assignService.UpdateSettings(c.Request.Context(), tenantID, userID, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: settings})
+}
+
+func (h *AssignmentHandler) GetAgentChannels(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	id := c.Param("id")
+
+	channels, err := h.assignService.GetAgentChannels(c.Request.Context(), id, tenantID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: channels})
+}
+
+func (h *AssignmentHandler) SetAgentChannels(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	id := c.Param("id")
+
+	var req model.UpdateAgentChannelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: "Invalid request: " + err.Error()})
+		return
+	}
+
+	err := h.assignService.SetAgentChannels(c.Request.Context(), id, tenantID, req.Channels)
+	if err != nil {
+		c.JSON(notFoundStatus(err, http.StatusBadRequest), model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Message: "Agent channels updated"})
+}
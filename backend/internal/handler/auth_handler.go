@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"backend/internal/model"
@@ -29,7 +30,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	resp, err := h.authService.Login(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, model.APIResponse{
+		c.JSON(authErrorStatus(err), model.APIResponse{
 			Success: false,
 			Message: err.Error(),
 		})
@@ -54,7 +55,11 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 	user, err := h.authService.Register(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, model.APIResponse{
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrTenantSuspended) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, model.APIResponse{
 			Success: false,
 			Message: err.Error(),
 		})
@@ -66,3 +71,57 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		Data:    user,
 	})
 }
+
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req model.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Success: false,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.authService.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		c.JSON(authErrorStatus(err), model.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Data:    resp,
+	})
+}
+
+// Logout revokes the bearer token and the optional refresh token in the body
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req model.LogoutRequest
+	// the body is optional
+	_ = c.ShouldBindJSON(&req)
+
+	expiresAt := c.GetTime("token_expires_at")
+	if err := h.authService.Logout(c.Request.Context(), c.GetString("jti"), expiresAt, req.RefreshToken); err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Message: "Logged out",
+	})
+}
+
+// authErrorStatus maps login and refresh errors; suspended tenants get 403
+func authErrorStatus(err error) int {
+	if errors.Is(err, service.ErrTenantSuspended) || errors.Is(err, service.ErrTenantNotFound) {
+		return http.StatusForbidden
+	}
+	return http.StatusUnauthorized
+}
@@ -1,9 +1,11 @@
 package handler
 
 import (
+	"backend/internal/logging"
 	"backend/internal/model"
 	"backend/internal/service"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strings"
 
@@ -19,10 +21,10 @@ func NewChannelHandler(s *service.ChannelService) *ChannelHandler {
 }
 
 func (h *ChannelHandler) List(c *gin.Context) {
-	chs, err := h.service.List(c.Request.Context())
+	chs, err := h.service.List(c.Request.Context(), c.GetString("tenant_id"))
 	if err != nil {
 		if strings.Contains(strings.ToLower(err.Error()), "does not exist") || strings.Contains(strings.ToLower(err.Error()), "no such table") {
-			log.Printf("DB schema missing when listing channels: %v", err)
+			slog.ErrorContext(c.Request.Context(), "DB schema missing when listing channels", logging.Err(err))
 			c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: []model.Channel{}, Message: "database schema not ready or no data"})
 			return
 		}
@@ -34,7 +36,7 @@ func (h *ChannelHandler) List(c *gin.Context) {
 
 func (h *ChannelHandler) GetByID(c *gin.Context) {
 	id := c.Param("id")
-	ch, err := h.service.GetByID(c.Request.Context(), id)
+	ch, err := h.service.GetByID(c.Request.Context(), id, c.GetString("tenant_id"))
 	if err != nil {
 		c.JSON(http.StatusNotFound, model.APIResponse{Success: false, Message: "not found"})
 		return
@@ -48,12 +50,9 @@ func (h *ChannelHandler) Create(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: err.Error()})
 		return
 	}
-	// set tenant id from context or default
-	if req.TenantID == "" {
-		req.TenantID = "tenant_001"
-	}
-	if err := h.service.Create(c.Request.Context(), &req); err != nil {
-		c.JSON(http.StatusInternalServerError, model.APIResponse{Success: false, Message: err.Error()})
+	// channels always belong to the caller's tenant
+	if err := h.service.Create(c.Request.Context(), &req, c.GetString("tenant_id"), c.GetString("user_id")); err != nil {
+		c.JSON(channelErrorStatus(err), model.APIResponse{Success: false, Message: err.Error()})
 		return
 	}
 	c.JSON(http.StatusCreated, model.APIResponse{Success: true, Data: req})
@@ -67,18 +66,40 @@ func (h *ChannelHandler) Update(c *gin.Context) {
 		return
 	}
 	req.ID = id
-	if err := h.service.Update(c.Request.Context(), &req); err != nil {
-		c.JSON(http.StatusInternalServerError, model.APIResponse{Success: false, Message: err.Error()})
+	if err := h.service.Update(c.Request.Context(), &req, c.GetString("tenant_id"), c.GetString("user_id")); err != nil {
+		c.JSON(channelErrorStatus(err), model.APIResponse{Success: false, Message: err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: req})
 }
 
+func (h *ChannelHandler) RotateSecret(c *gin.Context) {
+	id := c.Param("id")
+	userID := c.GetString("user_id")
+	ch, err := h.service.RotateSecret(c.Request.Context(), id, c.GetString("tenant_id"), userID)
+	if err != nil {
+		c.JSON(channelErrorStatus(err), model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: ch})
+}
+
 func (h *ChannelHandler) Delete(c *gin.Context) {
 	id := c.Param("id")
-	if err := h.service.Delete(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, model.APIResponse{Success: false, Message: err.Error()})
+	if err := h.service.Delete(c.Request.Context(), id, c.GetString("tenant_id"), c.GetString("user_id")); err != nil {
+		c.JSON(channelErrorStatus(err), model.APIResponse{Success: false, Message: err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, model.APIResponse{Success: true})
 }
+
+// channelErrorStatus maps channel service errors: channels of other tenants are not found
+func channelErrorStatus(err error) int {
+	if errors.Is(err, service.ErrChannelNotFound) {
+		return http.StatusNotFound
+	}
+	if errors.Is(err, service.ErrInvalidOutboundURL) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
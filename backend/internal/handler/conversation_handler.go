@@ -1,11 +1,13 @@
 package handler
 
 import (
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"backend/internal/logging"
 	"backend/internal/model"
 	"backend/internal/service"
 
@@ -24,33 +26,35 @@ func (h *ConversationHandler) List(c *gin.Context) {
 	tenantID := c.GetString("tenant_id")
 	// Manually parse query params to avoid binding errors when SPA sends empty strings
 	var filter model.ConversationFilter
-	// simple string filters
+	// simple string filters; status, sla_status and channel accept comma separated sets
 	filter.Status = c.Query("status")
 	filter.AssignedAgentID = c.Query("assigned_agent_id")
-
-	// pagination with defaults
-	pageStr := c.Query("page")
-	perPageStr := c.Query("per_page")
-	page := 1
-	perPage := 20
-	if pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
-			page = p
-		}
+	filter.SLAStatus = c.Query("sla_status")
+	filter.Channel = c.Query("channel")
+	filter.CustomerID = c.Query("customer_id")
+	filter.Query = c.Query("q")
+	filter.Unassigned = c.Query("unassigned") == "true"
+	filter.ViewerID = c.GetString("user_id")
+
+	hasTicket, err := parseBoolQuery(c, "has_ticket")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: err.Error()})
+		return
 	}
-	if perPageStr != "" {
-		if pp, err := strconv.Atoi(perPageStr); err == nil && pp > 0 {
-			perPage = pp
-		}
+	filter.HasTicket = hasTicket
+
+	// pagination with defaults, sort, cursor and date ranges
+	filter.PaginationParams, filter.ListParams, err = parseListParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: err.Error()})
+		return
 	}
-	filter.Page = page
-	filter.PerPage = perPage
 
 	conversations, meta, err := h.convService.List(c.Request.Context(), tenantID, filter)
 	if err != nil {
 		// If DB schema isn't ready (missing tables) return an empty result for easier debugging in dev
 		if strings.Contains(strings.ToLower(err.Error()), "does not exist") || strings.Contains(strings.ToLower(err.Error()), "no such table") {
-			log.Printf("DB schema missing when listing conversations: %v", err)
+			slog.ErrorContext(c.Request.Context(), "DB schema missing when listing conversations", logging.Err(err))
 			empty := []model.Conversation{}
 			meta := &model.PaginationMeta{Page: filter.Page, PerPage: filter.PerPage, Total: 0, TotalPages: 0}
 			c.JSON(http.StatusOK, model.APIResponse{
@@ -62,7 +66,7 @@ func (h *ConversationHandler) List(c *gin.Context) {
 			return
 		}
 
-		c.JSON(http.StatusInternalServerError, model.APIResponse{
+		c.JSON(listErrorStatus(err), model.APIResponse{
 			Success: false,
 			Message: err.Error(),
 		})
@@ -80,7 +84,7 @@ func (h *ConversationHandler) GetByID(c *gin.Context) {
 	tenantID := c.GetString("tenant_id")
 	id := c.Param("id")
 
-	conv, messages, err := h.convService.GetByID(c.Request.Context(), id, tenantID)
+	conv, messages, messagesMeta, err := h.convService.GetByID(c.Request.Context(), id, tenantID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, model.APIResponse{
 			Success: false,
@@ -92,12 +96,81 @@ func (h *ConversationHandler) GetByID(c *gin.Context) {
 	c.JSON(http.StatusOK, model.APIResponse{
 		Success: true,
 		Data: gin.H{
-			"conversation": conv,
-			"messages":     messages,
+			"conversation":  conv,
+			"messages":      messages,
+			"messages_meta": messagesMeta,
 		},
 	})
 }
 
+// ListMessages handles GET /conversations/:id/messages?before=&after=&limit=
+func (h *ConversationHandler) ListMessages(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	id := c.Param("id")
+
+	q := model.MessagePageQuery{
+		Before: c.Query("before"),
+		After:  c.Query("after"),
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: "invalid limit"})
+			return
+		}
+		q.Limit = limit
+	}
+
+	messages, meta, err := h.convService.ListMessages(c.Request.Context(), id, tenantID, q)
+	if err != nil {
+		c.JSON(notFoundStatus(err, http.StatusBadRequest), model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Data:    messages,
+		Meta:    meta,
+	})
+}
+
+// MarkRead handles POST /conversations/:id/read with an optional {"message_id": "..."}
+func (h *ConversationHandler) MarkRead(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	userID := c.GetString("user_id")
+	id := c.Param("id")
+
+	var req model.MarkReadRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: "Invalid request: " + err.Error()})
+			return
+		}
+	}
+
+	read, err := h.convService.MarkRead(c.Request.Context(), id, tenantID, userID, req.MessageID)
+	if err != nil {
+		c.JSON(notFoundStatus(err, http.StatusInternalServerError), model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: read})
+}
+
+// ListReads handles GET /conversations/:id/reads
+func (h *ConversationHandler) ListReads(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	id := c.Param("id")
+
+	reads, err := h.convService.ListReads(c.Request.Context(), id, tenantID)
+	if err != nil {
+		c.JSON(notFoundStatus(err, http.StatusInternalServerError), model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: reads})
+}
+
 func (h *ConversationHandler) SendMessage(c *gin.Context) {
 	tenantID := c.GetString("tenant_id")
 	userID := c.GetString("user_id")
@@ -113,14 +186,25 @@ func (h *ConversationHandler) SendMessage(c *gin.Context) {
 		return
 	}
 
-	msg, err := h.convService.SendMessage(c.Request.Context(), conversationID, tenantID, userID, userName, req.Message)
+	msg, replayed, err := h.convService.SendMessage(c.Request.Context(), conversationID, tenantID, userID, userName, req.Message, c.GetHeader("Idempotency-Key"))
+	if errors.Is(err, service.ErrIdempotencyInProgress) {
+		c.JSON(http.StatusConflict, model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrQuotaExceeded) {
+		c.JSON(http.StatusTooManyRequests, model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, model.APIResponse{
+		c.JSON(notFoundStatus(err, http.StatusBadRequest), model.APIResponse{
 			Success: false,
 			Message: err.Error(),
 		})
 		return
 	}
+	if replayed {
+		c.Header("Idempotent-Replayed", "true")
+	}
 
 	c.JSON(http.StatusCreated, model.APIResponse{
 		Success: true,
@@ -133,9 +217,9 @@ func (h *ConversationHandler) Assign(c *gin.Context) {
 	userID := c.GetString("user_id")
 	conversationID := c.Param("id")
 
-	err := h.convService.Assign(c.Request.Context(), conversationID, tenantID, userID)
+	err := h.convService.Assign(c.Request.Context(), conversationID, tenantID, userID, userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, model.APIResponse{
+		c.JSON(notFoundStatus(err, http.StatusBadRequest), model.APIResponse{
 			Success: false,
 			Message: err.Error(),
 		})
@@ -155,7 +239,7 @@ func (h *ConversationHandler) Close(c *gin.Context) {
 
 	err := h.convService.Close(c.Request.Context(), conversationID, tenantID, userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, model.APIResponse{
+		c.JSON(notFoundStatus(err, http.StatusBadRequest), model.APIResponse{
 			Success: false,
 			Message: err.Error(),
 		})
@@ -170,6 +254,7 @@ func (h *ConversationHandler) Close(c *gin.Context) {
 
 func (h *ConversationHandler) Create(c *gin.Context) {
 	tenantID := c.GetString("tenant_id")
+	userID := c.GetString("user_id")
 
 	var req model.Conversation
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -177,9 +262,9 @@ func (h *ConversationHandler) Create(c *gin.Context) {
 		return
 	}
 
-	conv, err := h.convService.Create(c.Request.Context(), tenantID, &req)
+	conv, err := h.convService.Create(c.Request.Context(), tenantID, userID, &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: err.Error()})
+		c.JSON(notFoundStatus(err, http.StatusBadRequest), model.APIResponse{Success: false, Message: err.Error()})
 		return
 	}
 
@@ -188,6 +273,7 @@ func (h *ConversationHandler) Create(c *gin.Context) {
 
 func (h *ConversationHandler) Update(c *gin.Context) {
 	tenantID := c.GetString("tenant_id")
+	userID := c.GetString("user_id")
 	id := c.Param("id")
 
 	var payload struct {
@@ -201,24 +287,23 @@ func (h *ConversationHandler) Update(c *gin.Context) {
 
 	// allow status update or assign
 	if payload.AssignedAgent != "" {
-		err := h.convService.Assign(c.Request.Context(), id, tenantID, payload.AssignedAgent)
+		err := h.convService.Assign(c.Request.Context(), id, tenantID, payload.AssignedAgent, userID)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: err.Error()})
+			c.JSON(notFoundStatus(err, http.StatusBadRequest), model.APIResponse{Success: false, Message: err.Error()})
 			return
 		}
 	}
 	if payload.Status != "" {
-		userID := c.GetString("user_id")
 		if payload.Status == "closed" {
 			err := h.convService.Close(c.Request.Context(), id, tenantID, userID)
 			if err != nil {
-				c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: err.Error()})
+				c.JSON(notFoundStatus(err, http.StatusBadRequest), model.APIResponse{Success: false, Message: err.Error()})
 				return
 			}
 		} else {
-			err := h.convService.UpdateStatus(c.Request.Context(), id, payload.Status)
+			err := h.convService.UpdateStatus(c.Request.Context(), id, tenantID, userID, payload.Status)
 			if err != nil {
-				c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: err.Error()})
+				c.JSON(notFoundStatus(err, http.StatusBadRequest), model.APIResponse{Success: false, Message: err.Error()})
 				return
 			}
 		}
@@ -229,24 +314,40 @@ func (h *ConversationHandler) Update(c *gin.Context) {
 
 func (h *ConversationHandler) Delete(c *gin.Context) {
 	tenantID := c.GetString("tenant_id")
+	userID := c.GetString("user_id")
 	id := c.Param("id")
 
-	err := h.convService.Delete(c.Request.Context(), id, tenantID)
+	err := h.convService.Delete(c.Request.Context(), id, tenantID, userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: err.Error()})
+		c.JSON(notFoundStatus(err, http.StatusBadRequest), model.APIResponse{Success: false, Message: err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, model.APIResponse{Success: true, Message: "Conversation deleted"})
 }
 
+// Restore undoes a soft delete; it is limited to admins by the route permission
+func (h *ConversationHandler) Restore(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	userID := c.GetString("user_id")
+	id := c.Param("id")
+
+	conv, err := h.convService.Restore(c.Request.Context(), id, tenantID, userID)
+	if err != nil {
+		c.JSON(notFoundStatus(err, http.StatusInternalServerError), model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: conv})
+}
+
 func (h *ConversationHandler) ListTickets(c *gin.Context) {
 	tenantID := c.GetString("tenant_id")
 	id := c.Param("id")
 
 	tickets, err := h.convService.ListTicketsForConversation(c.Request.Context(), id, tenantID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: err.Error()})
+		c.JSON(notFoundStatus(err, http.StatusBadRequest), model.APIResponse{Success: false, Message: err.Error()})
 		return
 	}
 
@@ -268,7 +369,7 @@ func (h *ConversationHandler) SetSelectedTicket(c *gin.Context) {
 
 	err := h.convService.SetSelectedTicket(c.Request.Context(), id, tenantID, userID, body.TicketID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: err.Error()})
+		c.JSON(notFoundStatus(err, http.StatusBadRequest), model.APIResponse{Success: false, Message: err.Error()})
 		return
 	}
 
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"backend/internal/service"
+)
+
+// notFoundErrors are the service errors for ids that do not exist in the caller's tenant
+var notFoundErrors = []error{
+	service.ErrConversationNotFound,
+	service.ErrMessageNotFound,
+	service.ErrTicketNotFound,
+	service.ErrChannelNotFound,
+	service.ErrUserNotFound,
+	service.ErrAgentNotFound,
+	service.ErrCustomerNotFound,
+}
+
+// notFoundStatus answers 404 for ids that do not exist in the caller's tenant, ids of other
+// tenants included, and fallback for every other error
+func notFoundStatus(err error, fallback int) int {
+	for _, target := range notFoundErrors {
+		if errors.Is(err, target) {
+			return http.StatusNotFound
+		}
+	}
+	return fallback
+}
+
+// grantStatus answers 403 when a user, invite or role would grant permissions the caller's own
+// role lacks, and otherwise behaves like notFoundStatus
+func grantStatus(err error, fallback int) int {
+	if errors.Is(err, service.ErrRoleEscalation) {
+		return http.StatusForbidden
+	}
+	return notFoundStatus(err, fallback)
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"backend/internal/broker"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
+)
+
+// healthProbeTimeout bounds each dependency probe of the readiness check
+const healthProbeTimeout = 2 * time.Second
+
+type HealthHandler struct {
+	db       *sqlx.DB
+	redis    *redis.Client
+	rabbit   *broker.Manager
+	draining atomic.Bool
+}
+
+func NewHealthHandler(db *sqlx.DB, redis *redis.Client, rabbit *broker.Manager) *HealthHandler {
+	return &HealthHandler{db: db, redis: redis, rabbit: rabbit}
+}
+
+// Drain makes the readiness check fail so load balancers stop routing new traffic
+func (h *HealthHandler) Drain() {
+	h.draining.Store(true)
+}
+
+// Live handles GET /health/live; it only reports that the process is serving requests
+func (h *HealthHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "alive"})
+}
+
+// Ready handles GET /health/ready by probing Postgres, Redis and the RabbitMQ connection;
+// a lost broker connection only degrades the service
+func (h *HealthHandler) Ready(c *gin.Context) {
+	if h.draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting_down"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), healthProbeTimeout)
+	defer cancel()
+
+	checks := gin.H{}
+	ready := true
+	probe := func(name string, err error) {
+		if err != nil {
+			checks[name] = err.Error()
+			ready = false
+			return
+		}
+		checks[name] = "ok"
+	}
+	probe("postgres", h.db.PingContext(ctx))
+	probe("redis", h.redis.Ping(ctx).Err())
+
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": checks})
+		return
+	}
+
+	// Events are buffered in the outbox while RabbitMQ is down, so the API keeps serving
+	status := "ready"
+	checks["rabbitmq"] = "ok"
+	if !h.rabbit.Connected() {
+		status = "degraded"
+		checks["rabbitmq"] = broker.ErrUnavailable.Error()
+	}
+	c.JSON(http.StatusOK, gin.H{"status": status, "checks": checks})
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/internal/model"
+	"backend/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseListParams reads the pagination, sort, cursor and date range query params shared by
+// list endpoints. Passing cursor (even empty, for the first page) switches to keyset mode.
+func parseListParams(c *gin.Context) (model.PaginationParams, model.ListParams, error) {
+	page := model.PaginationParams{Page: 1, PerPage: 20}
+	if p, err := strconv.Atoi(c.Query("page")); err == nil && p > 0 {
+		page.Page = p
+	}
+	if pp, err := strconv.Atoi(c.Query("per_page")); err == nil && pp > 0 {
+		page.PerPage = pp
+	}
+	if page.PerPage > 100 {
+		page.PerPage = 100
+	}
+
+	params := model.ListParams{Sort: c.Query("sort")}
+	params.Cursor, params.CursorMode = c.GetQuery("cursor")
+
+	var err error
+	if params.CreatedFrom, err = parseDateQuery(c, "created_from", false); err != nil {
+		return page, params, err
+	}
+	if params.CreatedTo, err = parseDateQuery(c, "created_to", true); err != nil {
+		return page, params, err
+	}
+	if params.UpdatedFrom, err = parseDateQuery(c, "updated_from", false); err != nil {
+		return page, params, err
+	}
+	if params.UpdatedTo, err = parseDateQuery(c, "updated_to", true); err != nil {
+		return page, params, err
+	}
+	return page, params, nil
+}
+
+// parseDateQuery accepts RFC3339 or YYYY-MM-DD; a date-only upper bound covers the whole day
+func parseDateQuery(c *gin.Context, key string, endOfDay bool) (*time.Time, error) {
+	v := c.Query(key)
+	if v == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return nil, errors.New("invalid " + key + ": use RFC3339 or YYYY-MM-DD")
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return &t, nil
+}
+
+// parseBoolQuery returns nil when the param is absent
+func parseBoolQuery(c *gin.Context, key string) (*bool, error) {
+	v := c.Query(key)
+	if v == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, errors.New("invalid " + key + ": use true or false")
+	}
+	return &b, nil
+}
+
+// listErrorStatus maps invalid sort/cursor errors to 400
+func listErrorStatus(err error) int {
+	if errors.Is(err, repository.ErrInvalidCursor) || errors.Is(err, repository.ErrInvalidSort) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
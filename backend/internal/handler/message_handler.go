@@ -1,8 +1,10 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
+	"backend/internal/middleware"
 	"backend/internal/model"
 	"backend/internal/service"
 
@@ -11,11 +13,44 @@ import (
 
 type MessageHandler struct {
 	convService *service.ConversationService
-	// we access message repo via conversation service's msgRepo is unexported; instead add a small delete wrapper in conversation service
+	perms       middleware.PermissionChecker
 }
 
-func NewMessageHandler(convService *service.ConversationService) *MessageHandler {
-	return &MessageHandler{convService: convService}
+func NewMessageHandler(convService *service.ConversationService, perms middleware.PermissionChecker) *MessageHandler {
+	return &MessageHandler{convService: convService, perms: perms}
+}
+
+func (h *MessageHandler) Update(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	userID := c.GetString("user_id")
+	id := c.Param("id")
+
+	var req model.EditMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	msg, err := h.convService.EditMessage(c.Request.Context(), id, tenantID, userID, req.Message, h.canModerate(c))
+	if err != nil {
+		c.JSON(messageErrorStatus(err), model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: msg})
+}
+
+func (h *MessageHandler) ListEdits(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	id := c.Param("id")
+
+	edits, err := h.convService.ListMessageEdits(c.Request.Context(), id, tenantID)
+	if err != nil {
+		c.JSON(messageErrorStatus(err), model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: edits})
 }
 
 func (h *MessageHandler) Delete(c *gin.Context) {
@@ -23,11 +58,46 @@ func (h *MessageHandler) Delete(c *gin.Context) {
 	userID := c.GetString("user_id")
 	id := c.Param("id")
 
-	err := h.convService.DeleteMessage(c.Request.Context(), id, tenantID, userID)
+	err := h.convService.DeleteMessage(c.Request.Context(), id, tenantID, userID, h.canModerate(c))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: err.Error()})
+		c.JSON(messageErrorStatus(err), model.APIResponse{Success: false, Message: err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, model.APIResponse{Success: true, Message: "Message deleted"})
 }
+
+// Restore undoes a soft delete; it is limited to admins by the route permission
+func (h *MessageHandler) Restore(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	userID := c.GetString("user_id")
+	id := c.Param("id")
+
+	msg, err := h.convService.RestoreMessage(c.Request.Context(), id, tenantID, userID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrMessageNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: msg})
+}
+
+// canModerate reports whether the caller may change any message of the tenant, not only their own
+func (h *MessageHandler) canModerate(c *gin.Context) bool {
+	return h.perms.HasPermission(c.Request.Context(), c.GetString("tenant_id"), c.GetString("role"), model.PermMessageModerate)
+}
+
+func messageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrMessageNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrMessageNotOwned), errors.Is(err, service.ErrMessageEditExpired):
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
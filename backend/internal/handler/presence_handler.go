@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+
+	"backend/internal/model"
+	"backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PresenceHandler struct {
+	presenceService *service.PresenceService
+}
+
+func NewPresenceHandler(presenceService *service.PresenceService) *PresenceHandler {
+	return &PresenceHandler{presenceService: presenceService}
+}
+
+// List handles GET /agents/presence
+func (h *PresenceHandler) List(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	list, err := h.presenceService.List(c.Request.Context(), tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: list})
+}
+
+// UpdateMe handles PUT /agents/me/presence; agents set their own status and capacity
+func (h *PresenceHandler) UpdateMe(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	userID := c.GetString("user_id")
+
+	var req model.UpdatePresenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: "Invalid request: " + err.Error()})
+		return
+	}
+
+	presence, err := h.presenceService.SetStatus(c.Request.Context(), tenantID, userID, req)
+	if err != nil {
+		c.JSON(notFoundStatus(err, http.StatusInternalServerError), model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: presence})
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"backend/internal/model"
+	"backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoleHandler struct {
+	roleService *service.RoleService
+}
+
+func NewRoleHandler(roleService *service.RoleService) *RoleHandler {
+	return &RoleHandler{roleService: roleService}
+}
+
+func (h *RoleHandler) ListPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: h.roleService.ListPermissions()})
+}
+
+func (h *RoleHandler) List(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	roles, err := h.roleService.ListRoles(c.Request.Context(), tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: roles})
+}
+
+func (h *RoleHandler) Create(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	userID := c.GetString("user_id")
+
+	var req model.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: "Invalid request: " + err.Error()})
+		return
+	}
+
+	role, err := h.roleService.CreateRole(c.Request.Context(), tenantID, userID, c.GetString("role"), req)
+	if err != nil {
+		c.JSON(roleErrorStatus(err), model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.APIResponse{Success: true, Data: role})
+}
+
+func (h *RoleHandler) Update(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	userID := c.GetString("user_id")
+	id := c.Param("id")
+
+	var req model.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: "Invalid request: " + err.Error()})
+		return
+	}
+
+	role, err := h.roleService.UpdateRole(c.Request.Context(), id, tenantID, userID, c.GetString("role"), req)
+	if err != nil {
+		c.JSON(roleErrorStatus(err), model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: role})
+}
+
+func (h *RoleHandler) Delete(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	userID := c.GetString("user_id")
+	id := c.Param("id")
+
+	err := h.roleService.DeleteRole(c.Request.Context(), id, tenantID, userID)
+	if err != nil {
+		c.JSON(roleErrorStatus(err), model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Message: "Role deleted"})
+}
+
+func roleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrRoleNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrRoleInUse):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrRoleEscalation):
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
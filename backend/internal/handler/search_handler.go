@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"backend/internal/model"
+	"backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchHandler struct {
+	searchService *service.SearchService
+}
+
+func NewSearchHandler(searchService *service.SearchService) *SearchHandler {
+	return &SearchHandler{searchService: searchService}
+}
+
+// Search handles GET /search?q=...&type=message,ticket,customer&page=&per_page=
+func (h *SearchHandler) Search(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	filter := model.SearchFilter{Query: c.Query("q")}
+	for _, v := range c.QueryArray("type") {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				filter.Types = append(filter.Types, t)
+			}
+		}
+	}
+
+	filter.Page = 1
+	filter.PerPage = 20
+	if p, err := strconv.Atoi(c.Query("page")); err == nil && p > 0 {
+		filter.Page = p
+	}
+	if pp, err := strconv.Atoi(c.Query("per_page")); err == nil && pp > 0 {
+		filter.PerPage = pp
+	}
+	if filter.PerPage > 100 {
+		filter.PerPage = 100
+	}
+
+	results, meta, err := h.searchService.Search(c.Request.Context(), tenantID, filter)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrSearchQueryRequired) || errors.Is(err, service.ErrInvalidSearchType) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, model.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Data:    results,
+		Meta:    meta,
+	})
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"net/http"
+
+	"backend/internal/model"
+	"backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SLAHandler struct {
+	slaService *service.SLAService
+}
+
+func NewSLAHandler(slaService *service.SLAService) *SLAHandler {
+	return &SLAHandler{slaService: slaService}
+}
+
+func (h *SLAHandler) List(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	policies, err := h.slaService.ListPolicies(c.Request.Context(), tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: policies})
+}
+
+func (h *SLAHandler) Create(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	userID := c.GetString("user_id")
+
+	var req model.SLAPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: "Invalid request: " + err.Error()})
+		return
+	}
+
+	policy, err := h.slaService.CreatePolicy(c.Request.Context(), tenantID, userID, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.APIResponse{Success: true, Data: policy})
+}
+
+func (h *SLAHandler) Update(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	userID := c.GetString("user_id")
+	id := c.Param("id")
+
+	var req model.SLAPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: "Invalid request: " + err.Error()})
+		return
+	}
+
+	policy, err := h.slaService.UpdatePolicy(c.Request.Context(), id, tenantID, userID, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: policy})
+}
+
+func (h *SLAHandler) Delete(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	userID := c.GetString("user_id")
+	id := c.Param("id")
+
+	err := h.slaService.DeletePolicy(c.Request.Context(), id, tenantID, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Message: "SLA policy deleted"})
+}
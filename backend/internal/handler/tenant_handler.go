@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"backend/internal/model"
+	"backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TenantHandler struct {
+	tenantService *service.TenantService
+	quotaService  *service.QuotaService
+	signupEnabled bool
+}
+
+func NewTenantHandler(tenantService *service.TenantService, quotaService *service.QuotaService, signupEnabled bool) *TenantHandler {
+	return &TenantHandler{tenantService: tenantService, quotaService: quotaService, signupEnabled: signupEnabled}
+}
+
+// Signup is self-service onboarding: it creates a tenant and its first admin.
+// Disabled unless TENANT_SIGNUP_ENABLED is set.
+func (h *TenantHandler) Signup(c *gin.Context) {
+	if !h.signupEnabled {
+		c.JSON(http.StatusForbidden, model.APIResponse{Success: false, Message: "Self-service signup is disabled"})
+		return
+	}
+	h.onboard(c)
+}
+
+// Create onboards a tenant through the platform API
+func (h *TenantHandler) Create(c *gin.Context) {
+	h.onboard(c)
+}
+
+func (h *TenantHandler) onboard(c *gin.Context) {
+	var req model.OnboardTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: "Invalid request: " + err.Error()})
+		return
+	}
+
+	res, err := h.tenantService.Onboard(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.APIResponse{Success: true, Data: res})
+}
+
+func (h *TenantHandler) List(c *gin.Context) {
+	tenants, err := h.tenantService.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: tenants})
+}
+
+func (h *TenantHandler) GetByID(c *gin.Context) {
+	h.get(c, c.Param("id"))
+}
+
+// GetCurrent returns the caller's tenant
+func (h *TenantHandler) GetCurrent(c *gin.Context) {
+	h.get(c, c.GetString("tenant_id"))
+}
+
+func (h *TenantHandler) get(c *gin.Context, id string) {
+	tenant, err := h.tenantService.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: tenant})
+}
+
+// UpdateCurrent changes the caller's tenant name and settings
+func (h *TenantHandler) UpdateCurrent(c *gin.Context) {
+	var req model.UpdateTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: "Invalid request: " + err.Error()})
+		return
+	}
+
+	tenant, err := h.tenantService.Update(c.Request.Context(), c.GetString("tenant_id"), c.GetString("user_id"), req)
+	if err != nil {
+		c.JSON(tenantErrorStatus(err), model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: tenant})
+}
+
+// Usage returns the caller's tenant message usage for the current month
+func (h *TenantHandler) Usage(c *gin.Context) {
+	usage, err := h.quotaService.Usage(c.Request.Context(), c.GetString("tenant_id"))
+	if err != nil {
+		c.JSON(tenantErrorStatus(err), model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: usage})
+}
+
+func (h *TenantHandler) SetPlan(c *gin.Context) {
+	var req model.UpdateTenantPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: "Invalid request: " + err.Error()})
+		return
+	}
+
+	tenant, err := h.tenantService.SetPlan(c.Request.Context(), c.Param("id"), req.Plan)
+	if err != nil {
+		c.JSON(tenantErrorStatus(err), model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: tenant})
+}
+
+func (h *TenantHandler) Suspend(c *gin.Context) {
+	tenant, err := h.tenantService.Suspend(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(tenantErrorStatus(err), model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: tenant, Message: "Tenant suspended"})
+}
+
+func (h *TenantHandler) Reactivate(c *gin.Context) {
+	tenant, err := h.tenantService.Reactivate(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(tenantErrorStatus(err), model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: tenant, Message: "Tenant reactivated"})
+}
+
+func (h *TenantHandler) ListInvites(c *gin.Context) {
+	invites, err := h.tenantService.ListInvites(c.Request.Context(), c.GetString("tenant_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: invites})
+}
+
+// CreateInvite returns the invite with its token, which is shown only once
+func (h *TenantHandler) CreateInvite(c *gin.Context) {
+	var req model.CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: "Invalid request: " + err.Error()})
+		return
+	}
+
+	invite, err := h.tenantService.CreateInvite(c.Request.Context(), c.GetString("tenant_id"), c.GetString("user_id"), c.GetString("role"), req)
+	if err != nil {
+		c.JSON(grantStatus(err, http.StatusBadRequest), model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.APIResponse{Success: true, Data: invite})
+}
+
+func (h *TenantHandler) RevokeInvite(c *gin.Context) {
+	err := h.tenantService.RevokeInvite(c.Request.Context(), c.Param("id"), c.GetString("tenant_id"), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Message: "Invite revoked"})
+}
+
+func tenantErrorStatus(err error) int {
+	if errors.Is(err, service.ErrTenantNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -2,10 +2,11 @@ package handler
 
 import (
 	"database/sql"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
 
+	"backend/internal/logging"
 	"backend/internal/model"
 	"backend/internal/service"
 
@@ -22,32 +23,34 @@ func NewTicketHandler(ticketService *service.TicketService) *TicketHandler {
 
 func (h *TicketHandler) List(c *gin.Context) {
 	tenantID := c.GetString("tenant_id")
-	// Bind query params for pagination and filtering
+	// Parse query params manually; status and priority accept comma separated sets
 	var filter model.TicketFilter
-	if err := c.ShouldBindQuery(&filter); err != nil {
+	filter.Status = c.Query("status")
+	filter.Priority = c.Query("priority")
+	filter.SLAStatus = c.Query("sla_status")
+	filter.AssignedAgentID = c.Query("assigned_agent_id")
+	filter.ConversationID = c.Query("conversation_id")
+	filter.Query = c.Query("q")
+	filter.Unassigned = c.Query("unassigned") == "true"
+
+	var err error
+	filter.PaginationParams, filter.ListParams, err = parseListParams(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: "Invalid query parameters: " + err.Error()})
 		return
 	}
 
-	// set defaults if not provided
-	if filter.Page == 0 {
-		filter.Page = 1
-	}
-	if filter.PerPage == 0 {
-		filter.PerPage = 20
-	}
-
 	tickets, meta, err := h.ticketService.List(c.Request.Context(), tenantID, filter)
 	if err != nil {
 		if strings.Contains(strings.ToLower(err.Error()), "does not exist") || strings.Contains(strings.ToLower(err.Error()), "no such table") {
-			log.Printf("DB schema missing when listing tickets: %v", err)
+			slog.ErrorContext(c.Request.Context(), "DB schema missing when listing tickets", logging.Err(err))
 			empty := []model.Ticket{}
 			meta := &model.PaginationMeta{Page: filter.Page, PerPage: filter.PerPage, Total: 0, TotalPages: 0}
 			c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: empty, Meta: meta, Message: "database schema not ready or no data"})
 			return
 		}
 
-		c.JSON(http.StatusInternalServerError, model.APIResponse{
+		c.JSON(listErrorStatus(err), model.APIResponse{
 			Success: false,
 			Message: err.Error(),
 		})
@@ -96,7 +99,7 @@ func (h *TicketHandler) Escalate(c *gin.Context) {
 
 	ticket, err := h.ticketService.Escalate(c.Request.Context(), conversationID, tenantID, userID, req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, model.APIResponse{
+		c.JSON(notFoundStatus(err, http.StatusBadRequest), model.APIResponse{
 			Success: false,
 			Message: err.Error(),
 		})
@@ -126,7 +129,7 @@ func (h *TicketHandler) UpdateStatus(c *gin.Context) {
 
 	err := h.ticketService.UpdateStatus(c.Request.Context(), id, tenantID, userID, req.Status)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, model.APIResponse{
+		c.JSON(notFoundStatus(err, http.StatusBadRequest), model.APIResponse{
 			Success: false,
 			Message: err.Error(),
 		})
@@ -173,7 +176,7 @@ func (h *TicketHandler) Create(c *gin.Context) {
 
 	ticket, err := h.ticketService.Create(c.Request.Context(), tenantID, userID, payload)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: err.Error()})
+		c.JSON(notFoundStatus(err, http.StatusBadRequest), model.APIResponse{Success: false, Message: err.Error()})
 		return
 	}
 
@@ -194,7 +197,7 @@ func (h *TicketHandler) Update(c *gin.Context) {
 	// Ensure Code is nil or string; no sql.NullString here since model.Ticket.Code is *string
 	ticket, err := h.ticketService.Update(c.Request.Context(), id, tenantID, userID, req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: err.Error()})
+		c.JSON(notFoundStatus(err, http.StatusBadRequest), model.APIResponse{Success: false, Message: err.Error()})
 		return
 	}
 
@@ -208,9 +211,24 @@ func (h *TicketHandler) Delete(c *gin.Context) {
 
 	err := h.ticketService.Delete(c.Request.Context(), id, tenantID, userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: err.Error()})
+		c.JSON(notFoundStatus(err, http.StatusBadRequest), model.APIResponse{Success: false, Message: err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, model.APIResponse{Success: true, Message: "Ticket deleted"})
 }
+
+// Restore undoes a soft delete; it is limited to admins by the route permission
+func (h *TicketHandler) Restore(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	userID := c.GetString("user_id")
+	id := c.Param("id")
+
+	ticket, err := h.ticketService.Restore(c.Request.Context(), id, tenantID, userID)
+	if err != nil {
+		c.JSON(notFoundStatus(err, http.StatusInternalServerError), model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{Success: true, Data: ticket})
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"backend/internal/model"
+	"backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TimelineHandler struct {
+	timelineService *service.TimelineService
+}
+
+func NewTimelineHandler(timelineService *service.TimelineService) *TimelineHandler {
+	return &TimelineHandler{timelineService: timelineService}
+}
+
+// Conversation handles GET /conversations/:id/timeline?cursor=&per_page=&sort=&created_from=&created_to=
+func (h *TimelineHandler) Conversation(c *gin.Context) {
+	h.list(c, h.timelineService.ConversationTimeline)
+}
+
+// Ticket handles GET /tickets/:id/timeline with the same query params
+func (h *TimelineHandler) Ticket(c *gin.Context) {
+	h.list(c, h.timelineService.TicketTimeline)
+}
+
+type timelineFunc func(ctx context.Context, id, tenantID string, page model.PaginationParams, params model.ListParams) ([]model.TimelineEntry, *model.CursorMeta, error)
+
+func (h *TimelineHandler) list(c *gin.Context, timeline timelineFunc) {
+	tenantID := c.GetString("tenant_id")
+	id := c.Param("id")
+
+	page, params, err := parseListParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	entries, meta, err := timeline(c.Request.Context(), id, tenantID, page, params)
+	if err != nil {
+		status := notFoundStatus(err, listErrorStatus(err))
+		c.JSON(status, model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Success: true,
+		Data:    entries,
+		Meta:    meta,
+	})
+}
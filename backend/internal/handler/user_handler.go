@@ -37,6 +37,7 @@ func (h *UserHandler) List(c *gin.Context) {
 
 func (h *UserHandler) Create(c *gin.Context) {
 	tenantID := c.GetString("tenant_id")
+	userID := c.GetString("user_id")
 
 	var req model.CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -47,9 +48,9 @@ func (h *UserHandler) Create(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.Create(c.Request.Context(), tenantID, req)
+	user, err := h.userService.Create(c.Request.Context(), tenantID, userID, c.GetString("role"), req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, model.APIResponse{
+		c.JSON(grantStatus(err, http.StatusBadRequest), model.APIResponse{
 			Success: false,
 			Message: err.Error(),
 		})
@@ -64,6 +65,7 @@ func (h *UserHandler) Create(c *gin.Context) {
 
 func (h *UserHandler) Update(c *gin.Context) {
 	tenantID := c.GetString("tenant_id")
+	userID := c.GetString("user_id")
 	id := c.Param("id")
 
 	var req model.UpdateUserRequest
@@ -75,9 +77,9 @@ func (h *UserHandler) Update(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.Update(c.Request.Context(), id, tenantID, req)
+	user, err := h.userService.Update(c.Request.Context(), id, tenantID, userID, c.GetString("role"), req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, model.APIResponse{
+		c.JSON(grantStatus(err, http.StatusBadRequest), model.APIResponse{
 			Success: false,
 			Message: err.Error(),
 		})
@@ -92,11 +94,12 @@ func (h *UserHandler) Update(c *gin.Context) {
 
 func (h *UserHandler) Delete(c *gin.Context) {
 	tenantID := c.GetString("tenant_id")
+	userID := c.GetString("user_id")
 	id := c.Param("id")
 
-	err := h.userService.Delete(c.Request.Context(), id, tenantID)
+	err := h.userService.Delete(c.Request.Context(), id, tenantID, userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, model.APIResponse{
+		c.JSON(notFoundStatus(err, http.StatusBadRequest), model.APIResponse{
 			Success: false,
 			Message: err.Error(),
 		})
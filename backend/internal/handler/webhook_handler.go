@@ -1,23 +1,92 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
+	"backend/internal/metrics"
 	"backend/internal/model"
 	"backend/internal/service"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
+// Context keys set by VerifyWebhook
+const (
+	webhookChannelKey = "webhook_channel"
+	webhookBodyKey    = "webhook_body"
+)
+
+// webhookMaxBodySize bounds the body VerifyWebhook reads before the signature is checked
+const webhookMaxBodySize = 1 << 20
+
 type WebhookHandler struct {
-	convService *service.ConversationService
+	convService    *service.ConversationService
+	channelService *service.ChannelService
+	tenantService  *service.TenantService
+}
+
+func NewWebhookHandler(convService *service.ConversationService, channelService *service.ChannelService, tenantService *service.TenantService) *WebhookHandler {
+	return &WebhookHandler{convService: convService, channelService: channelService, tenantService: tenantService}
+}
+
+// VerifyWebhook authenticates a request on a channel's inbound URL. The request must carry
+// X-Webhook-Timestamp and X-Webhook-Signature headers; on success the channel and the raw body
+// are stored in the context for the per-channel rate limit and HandleChannelWebhook. Bodies over
+// webhookMaxBodySize are rejected with 413 before anything is verified.
+func (h *WebhookHandler) VerifyWebhook(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, webhookMaxBodySize)
+	body, err := c.GetRawData()
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		metrics.WebhookIngested.WithLabelValues("unknown", "rejected").Inc()
+		c.JSON(http.StatusRequestEntityTooLarge, model.APIResponse{Success: false, Message: "Request body too large"})
+		c.Abort()
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: "Invalid request: " + err.Error()})
+		c.Abort()
+		return
+	}
+
+	ch, err := h.channelService.VerifyWebhook(c.Request.Context(), c.Param("slug"),
+		c.GetHeader("X-Webhook-Timestamp"), c.GetHeader("X-Webhook-Signature"), c.ClientIP(), body)
+	if err != nil {
+		// the slug is unverified here, so it is not used as a label
+		metrics.WebhookIngested.WithLabelValues("unknown", "rejected").Inc()
+		c.JSON(http.StatusUnauthorized, model.APIResponse{Success: false, Message: err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.Set("channel_id", ch.ID)
+	c.Set(webhookChannelKey, ch)
+	c.Set(webhookBodyKey, body)
+	c.Next()
 }
 
-func NewWebhookHandler(convService *service.ConversationService) *WebhookHandler {
-	return &WebhookHandler{convService: convService}
+// HandleChannelWebhook receives messages from an external channel provider on the channel's
+// own inbound URL, after VerifyWebhook; tenant and channel are taken from the channel, never
+// from the body.
+func (h *WebhookHandler) HandleChannelWebhook(c *gin.Context) {
+	ch := c.MustGet(webhookChannelKey).(*model.Channel)
+	body := c.MustGet(webhookBodyKey).([]byte)
+
+	var req model.WebhookRequest
+	if err := binding.JSON.BindBody(body, &req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{Success: false, Message: "Invalid request: " + err.Error()})
+		return
+	}
+	req.TenantID = ch.TenantID
+	req.Channel = ch.Slug
+
+	h.process(c, req, ch.Slug)
 }
 
 // HandleWebhook simulates incoming messages from external channels (WhatsApp, Instagram, etc.)
+// for the authenticated user's tenant
 func (h *WebhookHandler) HandleWebhook(c *gin.Context) {
 	var req model.WebhookRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -27,13 +96,40 @@ func (h *WebhookHandler) HandleWebhook(c *gin.Context) {
 		})
 		return
 	}
+	req.TenantID = c.GetString("tenant_id")
 
 	// Set default channel if not provided
 	if req.Channel == "" {
 		req.Channel = "unknown"
 	}
 
-	conv, err := h.convService.HandleWebhook(c.Request.Context(), req)
+	// the channel comes from the client here, so it is not used as a label
+	h.process(c, req, "simulator")
+}
+
+// process ingests a webhook message; channelLabel is the bounded metrics label for its channel
+func (h *WebhookHandler) process(c *gin.Context, req model.WebhookRequest, channelLabel string) {
+	// Suspended tenants don't receive messages
+	if err := h.tenantService.CheckActive(c.Request.Context(), req.TenantID); err != nil {
+		status := http.StatusForbidden
+		if !errors.Is(err, service.ErrTenantSuspended) && !errors.Is(err, service.ErrTenantNotFound) {
+			status = http.StatusInternalServerError
+		}
+		metrics.WebhookIngested.WithLabelValues(channelLabel, "rejected").Inc()
+		c.JSON(status, model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	res, err := h.convService.HandleWebhook(c.Request.Context(), req)
+	metrics.WebhookIngested.WithLabelValues(channelLabel, webhookResult(res, err)).Inc()
+	if errors.Is(err, service.ErrIdempotencyInProgress) {
+		c.JSON(http.StatusConflict, model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrQuotaExceeded) {
+		c.JSON(http.StatusTooManyRequests, model.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.APIResponse{
 			Success: false,
@@ -42,12 +138,33 @@ func (h *WebhookHandler) HandleWebhook(c *gin.Context) {
 		return
 	}
 
+	message := "Message received and processed"
+	if res.Duplicate {
+		message = "Duplicate message ignored"
+	}
+
 	c.JSON(http.StatusOK, model.APIResponse{
 		Success: true,
 		Data: gin.H{
-			"conversation_id": conv.ID,
-			"status":          conv.Status,
+			"conversation_id": res.Conversation.ID,
+			"status":          res.Conversation.Status,
+			"message_id":      res.Message.ID,
+			"duplicate":       res.Duplicate,
 		},
-		Message: "Message received and processed",
+		Message: message,
 	})
 }
+
+// webhookResult is the result label of the webhook ingest metric
+func webhookResult(res *service.WebhookResult, err error) string {
+	switch {
+	case errors.Is(err, service.ErrQuotaExceeded):
+		return "rejected"
+	case errors.Is(err, service.ErrIdempotencyInProgress), err == nil && res.Duplicate:
+		return "duplicate"
+	case err != nil:
+		return "error"
+	default:
+		return "accepted"
+	}
+}
@@ -3,125 +3,232 @@ package handler
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	amqp "github.com/rabbitmq/amqp091-go"
 
+	"backend/internal/broker"
+	"backend/internal/logging"
 	"backend/internal/middleware"
+	"backend/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	// wsRevocationCheckInterval is how often open sockets re-check that their token has not expired or been revoked
+	wsRevocationCheckInterval = time.Minute
+	wsConsumerTag             = "websocket"
+	// wsQueueMaxLength bounds the events waiting for this instance; the oldest are dropped first
+	wsQueueMaxLength = 10000
+	// typing events are dropped by the broker if not consumed within wsTypingTTL
+	wsTypingTTL            = 5 * time.Second
+	wsTypingPublishTimeout = 2 * time.Second
+)
+
 type WebsocketHandler struct {
-	rabbitConn *amqp.Connection
-	jwtSecret  string
-	hub        *wsHub
+	rabbit      *broker.Manager
+	jwtSecret   string
+	revocations middleware.RevocationChecker
+	presence    *service.PresenceService
+	hub         *wsHub
+	queue       string
+	stop        context.CancelFunc
+	stopped     chan struct{}
 }
 
-func NewWebsocketHandler(rabbitConn *amqp.Connection, jwtSecret string) *WebsocketHandler {
+func NewWebsocketHandler(rabbit *broker.Manager, jwtSecret string, revocations middleware.RevocationChecker, presence *service.PresenceService) *WebsocketHandler {
 	h := &WebsocketHandler{
-		rabbitConn: rabbitConn,
-		jwtSecret:  jwtSecret,
-		hub:        newHub(),
-	}
-	// start rabbit consumer broadcaster if connection present
-	if rabbitConn != nil {
-		go h.startRabbitConsumer(context.Background())
+		rabbit:      rabbit,
+		jwtSecret:   jwtSecret,
+		revocations: revocations,
+		presence:    presence,
+		hub:         newHub(),
+		queue:       wsQueueName(),
+		stopped:     make(chan struct{}),
 	}
+	ctx, stop := context.WithCancel(context.Background())
+	h.stop = stop
+	// broadcast events to clients; the consumer is restarted after broker reconnects
+	go func() {
+		defer close(h.stopped)
+		rabbit.Consume(ctx, wsConsumerTag, h.consume)
+	}()
 	return h
 }
 
-// wsHub holds clients and broadcasts messages
-type wsHub struct {
-	mu      sync.Mutex
-	clients map[*websocket.Conn]string // conn -> tenantID
+// Shutdown stops the RabbitMQ consumer and closes every client with a going-away close frame.
+// http.Server.Shutdown does not track upgraded connections, so this has to run separately.
+func (w *WebsocketHandler) Shutdown(ctx context.Context) {
+	w.stop()
+	select {
+	case <-w.stopped:
+	case <-ctx.Done():
+	}
+	w.hub.closeAll(websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"))
 }
 
-func newHub() *wsHub {
-	return &wsHub{clients: make(map[*websocket.Conn]string)}
-}
+// wsExchanges are forwarded to subscribed clients
+var wsExchanges = []string{"conversation.events", "ticket.events"}
 
-func (h *wsHub) add(conn *websocket.Conn, tenant string) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-	h.clients[conn] = tenant
+// wsQueueName names this instance's event queue after the host so it can be told apart in
+// the RabbitMQ management UI
+func wsQueueName() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return "websocket." + host + "." + uuid.NewString()[:8]
 }
 
-func (h *wsHub) remove(conn *websocket.Conn) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-	delete(h.clients, conn)
-	conn.Close()
-}
+// consume reads conversation.events and ticket.events until ctx is cancelled or the channel
+// closes. Every instance declares its own exclusive, auto-delete queue bound to both exchanges,
+// so each instance receives every event (fan-out) and delivers it to its own sockets.
+func (w *WebsocketHandler) consume(ctx context.Context, ch *amqp.Channel) error {
+	q, err := ch.QueueDeclare(w.queue, false, true, true, false, amqp.Table{
+		"x-max-length": int32(wsQueueMaxLength),
+		"x-overflow":   "drop-head",
+	})
+	if err != nil {
+		return fmt.Errorf("declare queue: %w", err)
+	}
 
-func (h *wsHub) broadcast(msg []byte, tenant string) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-	for c, t := range h.clients {
-		if tenant != "" && t != tenant {
-			continue
+	for _, exchange := range wsExchanges {
+		if err := ch.QueueBind(q.Name, "#", exchange, false, nil); err != nil {
+			return fmt.Errorf("bind queue to %s: %w", exchange, err)
+		}
+	}
+
+	msgs, err := ch.Consume(q.Name, wsConsumerTag, true, true, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("consume: %w", err)
+	}
+
+	for {
+		var d amqp.Delivery
+		select {
+		case <-ctx.Done():
+			if err := ch.Cancel(wsConsumerTag, false); err != nil {
+				slog.WarnContext(ctx, "ws: failed to cancel consumer", logging.Err(err))
+			}
+			return nil
+		case msg, ok := <-msgs:
+			if !ok {
+				return errors.New("websocket event channel closed")
+			}
+			d = msg
+		}
+
+		if ev, ok := routeEvent(d.Exchange, d.RoutingKey, d.Body); ok {
+			w.hub.publish(ev)
 		}
-		// write in goroutine to avoid blocking
-		go func(conn *websocket.Conn) {
-			conn.SetWriteDeadline(timeNowAdd())
-			_ = conn.WriteMessage(websocket.TextMessage, msg)
-		}(c)
 	}
 }
 
-// helper to provide a short write deadline duration
-func timeNowAdd() (t time.Time) {
-	return time.Now().Add(10 * time.Second)
+// wsCommand is a message sent by the client:
+// {"type":"subscribe"|"unsubscribe","payload":{"topic":"conversation","id":"..."}},
+// {"type":"typing","payload":{"conversation_id":"...","typing":true}} or {"type":"ping"}
+type wsCommand struct {
+	Type    string `json:"type"`
+	Payload struct {
+		Topic          string `json:"topic"`
+		ID             string `json:"id"`
+		ConversationID string `json:"conversation_id"`
+		Typing         bool   `json:"typing"`
+	} `json:"payload"`
 }
 
-// startRabbitConsumer consumes from conversation.events and broadcasts to clients
-func (w *WebsocketHandler) startRabbitConsumer(ctx context.Context) {
-	ch, err := w.rabbitConn.Channel()
-	if err != nil {
-		log.Printf("ws: failed to open rabbit channel: %v", err)
-		return
+// wsReply answers a command with "subscribed", "unsubscribed", "pong" or "error"
+type wsReply struct {
+	Type    string      `json:"type"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// handleCommand applies one client command and returns the reply; typing has none
+func (w *WebsocketHandler) handleCommand(ctx context.Context, client *wsClient, data []byte) *wsReply {
+	var cmd wsCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return &wsReply{Type: "error", Payload: gin.H{"message": "invalid command"}}
 	}
-	defer ch.Close()
 
-	q, err := ch.QueueDeclare("", false, true, true, false, nil)
-	if err != nil {
-		log.Printf("ws: failed to declare queue: %v", err)
-		return
+	switch cmd.Type {
+	case "ping":
+		return &wsReply{Type: "pong"}
+	case "typing":
+		if _, err := uuid.Parse(cmd.Payload.ConversationID); err != nil {
+			return &wsReply{Type: "error", Payload: gin.H{"message": "typing requires a valid conversation_id"}}
+		}
+		if client.shouldRelayTyping(cmd.Payload.ConversationID, cmd.Payload.Typing) {
+			w.relayTyping(ctx, client, cmd.Payload.ConversationID, cmd.Payload.Typing)
+		}
+		return nil
+	case "subscribe", "unsubscribe":
+	default:
+		return &wsReply{Type: "error", Payload: gin.H{"message": "unknown command " + cmd.Type}}
 	}
 
-	// bind to conversation.events
-	if err := ch.QueueBind(q.Name, "#", "conversation.events", false, nil); err != nil {
-		log.Printf("ws: failed to bind queue: %v", err)
-		return
+	topic, id := cmd.Payload.Topic, cmd.Payload.ID
+	switch topic {
+	case wsTopicConversation, wsTopicTicket:
+		if _, err := uuid.Parse(id); err != nil {
+			return &wsReply{Type: "error", Payload: gin.H{"message": topic + " subscription requires a valid id"}}
+		}
+	case wsTopicTickets, wsTopicAssignments, wsTopicPresence:
+		id = ""
+	default:
+		return &wsReply{Type: "error", Payload: gin.H{"message": "unknown topic " + topic}}
 	}
 
-	msgs, err := ch.Consume(q.Name, "", true, true, false, false, nil)
+	key := subscriptionKey(topic, id)
+	if cmd.Type == "unsubscribe" {
+		client.unsubscribe(key)
+		return &wsReply{Type: "unsubscribed", Payload: gin.H{"topic": topic, "id": id}}
+	}
+	if !client.subscribe(key) {
+		return &wsReply{Type: "error", Payload: gin.H{"message": "too many subscriptions"}}
+	}
+	return &wsReply{Type: "subscribed", Payload: gin.H{"topic": topic, "id": id}}
+}
+
+// relayTyping publishes an ephemeral conversation.typing event so sockets on every instance
+// get it; nothing is stored. Without a broker it only reaches this instance's clients.
+func (w *WebsocketHandler) relayTyping(ctx context.Context, client *wsClient, conversationID string, typing bool) {
+	body, err := json.Marshal(gin.H{
+		"type":            "conversation.typing",
+		"tenant_id":       client.tenantID,
+		"conversation_id": conversationID,
+		"user_id":         client.userID,
+		"typing":          typing,
+	})
 	if err != nil {
-		log.Printf("ws: failed to consume: %v", err)
 		return
 	}
 
-	for d := range msgs {
-		// try to extract tenant_id from message body
-		var payload map[string]interface{}
-		if err := json.Unmarshal(d.Body, &payload); err != nil {
-			// broadcast raw body
-			w.hub.broadcast(d.Body, "")
-			continue
-		}
-		tenant := ""
-		if v, ok := payload["tenant_id"]; ok {
-			if s, ok := v.(string); ok {
-				tenant = s
-			}
-		}
-		w.hub.broadcast(d.Body, tenant)
+	ctx, cancel := context.WithTimeout(ctx, wsTypingPublishTimeout)
+	defer cancel()
+	err = w.rabbit.Publish(ctx, "conversation.events", "conversation.typing", amqp.Publishing{
+		ContentType: "application/json",
+		Expiration:  strconv.Itoa(int(wsTypingTTL.Milliseconds())),
+		Body:        body,
+	})
+	if err == nil {
+		return
+	}
+	if !errors.Is(err, broker.ErrUnavailable) {
+		slog.WarnContext(ctx, "ws: failed to relay typing", logging.Err(err))
+	}
+	if ev, ok := routeEvent("conversation.events", "conversation.typing", body); ok {
+		w.hub.publish(ev)
 	}
 }
 
@@ -153,25 +260,81 @@ func (w *WebsocketHandler) Handle(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "invalid token"})
 		return
 	}
+	if middleware.TokenRevoked(c.Request.Context(), w.revocations, claims) {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "token revoked"})
+		return
+	}
 
 	// upgrade
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		log.Printf("ws: upgrade failed: %v", err)
+		slog.WarnContext(c.Request.Context(), "ws: upgrade failed", logging.Err(err))
 		return
 	}
 
-	tenant := claims.TenantID
-	w.hub.add(conn, tenant)
+	client := newClient(conn, claims.TenantID, claims.UserID)
+	w.hub.add(client)
+	go client.writePump()
+	// the request context ends when Handle returns, the socket outlives it
+	ctx := logging.WithUser(context.Background(), claims.UserID, claims.TenantID)
+	connID := uuid.NewString()
+	w.presence.Connect(ctx, claims.TenantID, claims.UserID, connID)
 
-	// Listen for client close/read messages
+	// Read client commands until the socket closes
+	done := make(chan struct{})
 	go func() {
-		defer w.hub.remove(conn)
+		defer close(done)
+		defer w.hub.remove(client)
+		defer w.presence.Disconnect(ctx, claims.TenantID, claims.UserID, connID)
+		defer func() {
+			for _, conversationID := range client.stopTyping() {
+				w.relayTyping(ctx, client, conversationID, false)
+			}
+		}()
+		conn.SetReadLimit(wsMaxCommandSize)
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		})
 		for {
-			_, _, err := conn.ReadMessage()
+			_, data, err := conn.ReadMessage()
 			if err != nil {
 				return
 			}
+			conn.SetReadDeadline(time.Now().Add(wsPongWait))
+			reply := w.handleCommand(ctx, client, data)
+			if reply == nil {
+				continue
+			}
+			if !client.enqueueJSON(reply) {
+				return
+			}
+		}
+	}()
+
+	// Drop the socket once its token expires or is revoked (logout, user deleted or role
+	// changed) and keep its presence entry alive meanwhile
+	go func() {
+		ticker := time.NewTicker(wsRevocationCheckInterval)
+		defer ticker.Stop()
+		heartbeat := time.NewTicker(service.PresenceHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-heartbeat.C:
+				w.presence.Heartbeat(ctx, claims.TenantID, claims.UserID, connID)
+			case <-ticker.C:
+				if claims.ExpiresAt != nil && time.Now().After(claims.ExpiresAt.Time) {
+					client.close(websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "token expired"))
+					return
+				}
+				if middleware.TokenRevoked(context.Background(), w.revocations, claims) {
+					client.close(websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "token revoked"))
+					return
+				}
+			}
 		}
 	}()
 
@@ -0,0 +1,326 @@
+package handler
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"backend/internal/metrics"
+)
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	// wsPongWait is how long a socket may stay silent; pings every wsPingInterval keep
+	// healthy clients inside it
+	wsPongWait     = 60 * time.Second
+	wsPingInterval = wsPongWait * 9 / 10
+	// wsMaxCommandSize bounds a single client command
+	wsMaxCommandSize = 8 << 10
+	// wsSendQueueSize is how many messages may wait for a client before it is evicted as too slow
+	wsSendQueueSize = 256
+	// wsMaxSubscriptions bounds the topics a single socket can follow
+	wsMaxSubscriptions = 200
+	// wsTypingRepeat throttles repeated typing notices of one client per conversation
+	wsTypingRepeat = 3 * time.Second
+)
+
+// Subscription topics. conversation and ticket take an id; tickets follows every ticket of
+// the tenant, assignments is the caller's own feed of conversations and tickets assigned to them
+// and presence follows agent status changes of the tenant.
+const (
+	wsTopicConversation = "conversation"
+	wsTopicTicket       = "ticket"
+	wsTopicTickets      = "tickets"
+	wsTopicAssignments  = "assignments"
+	wsTopicPresence     = "presence"
+)
+
+// wsClient is one socket with the subscriptions requested over it. Outgoing messages go
+// through send and are written by writePump only, as gorilla allows one writer per connection.
+type wsClient struct {
+	conn     *websocket.Conn
+	tenantID string
+	userID   string
+
+	send      chan []byte
+	done      chan struct{} // closed by close; stops writePump
+	closeOnce sync.Once
+
+	mu     sync.Mutex
+	subs   map[string]bool      // subscription keys, see subscriptionKey
+	typing map[string]time.Time // conversation id -> last relayed typing=true
+}
+
+func newClient(conn *websocket.Conn, tenantID, userID string) *wsClient {
+	return &wsClient{
+		conn:     conn,
+		tenantID: tenantID,
+		userID:   userID,
+		send:     make(chan []byte, wsSendQueueSize),
+		done:     make(chan struct{}),
+		subs:     make(map[string]bool),
+		typing:   make(map[string]time.Time),
+	}
+}
+
+// enqueue queues msg without blocking. A client whose queue is full is evicted, so one slow
+// reader cannot hold up the others; it reconnects and refetches what it missed.
+func (c *wsClient) enqueue(msg []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.send <- msg:
+		return true
+	default:
+		c.closeOnce.Do(func() {
+			close(c.done)
+			metrics.WebsocketEvictions.Inc()
+			// the close frame waits on the very socket that is slow, keep it off the caller
+			go c.closeConn(websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "slow consumer"))
+		})
+		return false
+	}
+}
+
+func (c *wsClient) enqueueJSON(v interface{}) bool {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+	return c.enqueue(msg)
+}
+
+// close sends frame as a close control message when not nil and closes the connection, which
+// ends the read loop and with it the client's registration. Safe to call more than once.
+func (c *wsClient) close(frame []byte) {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.closeConn(frame)
+	})
+}
+
+func (c *wsClient) closeConn(frame []byte) {
+	if frame != nil {
+		// WriteControl may run concurrently with writePump
+		_ = c.conn.WriteControl(websocket.CloseMessage, frame, time.Now().Add(time.Second))
+	}
+	c.conn.Close()
+}
+
+// writePump writes queued messages and keepalive pings until the client is closed or a write fails
+func (c *wsClient) writePump() {
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				c.close(nil)
+				return
+			}
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				c.close(nil)
+				return
+			}
+		}
+	}
+}
+
+// shouldRelayTyping drops typing=true notices repeated within wsTypingRepeat; stopping
+// is only relayed after a start
+func (c *wsClient) shouldRelayTyping(conversationID string, typing bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	last, started := c.typing[conversationID]
+	if !typing {
+		delete(c.typing, conversationID)
+		return started
+	}
+	if started && time.Since(last) < wsTypingRepeat {
+		return false
+	}
+	if !started && len(c.typing) >= wsMaxSubscriptions {
+		return false
+	}
+	c.typing[conversationID] = time.Now()
+	return true
+}
+
+// stopTyping clears and returns the conversations the client is still typing in
+func (c *wsClient) stopTyping() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ids := make([]string, 0, len(c.typing))
+	for id := range c.typing {
+		ids = append(ids, id)
+	}
+	c.typing = make(map[string]time.Time)
+	return ids
+}
+
+// subscribe adds key and reports false when the client already follows too many topics
+func (c *wsClient) subscribe(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.subs[key] && len(c.subs) >= wsMaxSubscriptions {
+		return false
+	}
+	c.subs[key] = true
+	return true
+}
+
+func (c *wsClient) unsubscribe(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.subs, key)
+}
+
+// wants reports whether the client follows one of the event's topics or is one of its agents
+func (c *wsClient) wants(ev *wsEvent) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range ev.topics {
+		if c.subs[key] {
+			return true
+		}
+	}
+	if c.subs[wsTopicAssignments] {
+		for _, agentID := range ev.agents {
+			if agentID == c.userID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func subscriptionKey(topic, id string) string {
+	if id == "" {
+		return topic
+	}
+	return topic + ":" + id
+}
+
+// wsEvent is a broker event with the audience derived from its payload
+type wsEvent struct {
+	tenantID string
+	topics   []string // subscription keys that receive the event
+	agents   []string // users whose assignment feed receives the event
+	sender   string   // user that caused an ephemeral event; not echoed back to them
+	body     []byte
+}
+
+// routeEvent decodes a conversation.events or ticket.events message. The routing key is added
+// as "type" when the payload has none. Events without a tenant are dropped.
+func routeEvent(exchange, routingKey string, body []byte) (*wsEvent, bool) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, false
+	}
+	str := func(key string) string {
+		s, _ := payload[key].(string)
+		return s
+	}
+
+	ev := &wsEvent{tenantID: str("tenant_id")}
+	if ev.tenantID == "" {
+		return nil, false
+	}
+	if id := str("conversation_id"); id != "" {
+		ev.topics = append(ev.topics, subscriptionKey(wsTopicConversation, id))
+	}
+	if id := str("ticket_id"); id != "" {
+		ev.topics = append(ev.topics, subscriptionKey(wsTopicTicket, id))
+	}
+	if exchange == "ticket.events" {
+		ev.topics = append(ev.topics, wsTopicTickets)
+	}
+	if routingKey == "agent.presence" {
+		ev.topics = append(ev.topics, wsTopicPresence)
+	}
+	if routingKey == "conversation.assigned" {
+		ev.agents = append(ev.agents, str("agent_id"))
+	}
+	if id := str("assigned_agent_id"); id != "" {
+		ev.agents = append(ev.agents, id)
+	}
+	if routingKey == "conversation.typing" {
+		ev.sender = str("user_id")
+	}
+
+	if _, ok := payload["type"]; !ok {
+		payload["type"] = routingKey
+		if b, err := json.Marshal(payload); err == nil {
+			body = b
+		}
+	}
+	ev.body = body
+	return ev, true
+}
+
+// wsHub holds the clients connected to this instance and delivers events to the ones that
+// subscribed to them. Every instance consumes all events (see WebsocketHandler.consume), so a
+// client only needs to be registered with the instance its socket is on.
+type wsHub struct {
+	mu      sync.Mutex
+	clients map[*wsClient]struct{}
+}
+
+func newHub() *wsHub {
+	return &wsHub{clients: make(map[*wsClient]struct{})}
+}
+
+func (h *wsHub) add(client *wsClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[client] = struct{}{}
+	metrics.WebsocketClients.Inc()
+}
+
+func (h *wsHub) remove(client *wsClient) {
+	h.mu.Lock()
+	if _, ok := h.clients[client]; ok {
+		delete(h.clients, client)
+		metrics.WebsocketClients.Dec()
+	}
+	h.mu.Unlock()
+	client.close(nil)
+}
+
+// closeAll drops every client and sends it frame as a close control message. The writes happen
+// after the lock is released, so a slow socket does not stall publish and remove.
+func (h *wsHub) closeAll(frame []byte) {
+	h.mu.Lock()
+	clients := make([]*wsClient, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+		delete(h.clients, client)
+		metrics.WebsocketClients.Dec()
+	}
+	h.mu.Unlock()
+
+	for _, client := range clients {
+		client.close(frame)
+	}
+}
+
+// publish queues ev for the clients of its tenant that want it; it never blocks on a socket
+func (h *wsHub) publish(ev *wsEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		if c.tenantID != ev.tenantID || c.userID == ev.sender || !c.wants(ev) {
+			continue
+		}
+		c.enqueue(ev.body)
+	}
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestRouteEvent(t *testing.T) {
+	cases := []struct {
+		name       string
+		exchange   string
+		routingKey string
+		body       string
+		topics     []string
+		agents     []string
+		sender     string
+	}{
+		{
+			name: "message goes to its conversation", exchange: "conversation.events", routingKey: "message.received",
+			body:   `{"tenant_id":"t1","conversation_id":"c1","message_id":"m1"}`,
+			topics: []string{"conversation:c1"},
+		},
+		{
+			name: "assignment also reaches the new agent", exchange: "conversation.events", routingKey: "conversation.assigned",
+			body:   `{"tenant_id":"t1","conversation_id":"c1","agent_id":"u1"}`,
+			topics: []string{"conversation:c1"}, agents: []string{"u1"},
+		},
+		{
+			name: "ticket event reaches the ticket, its conversation, the tickets feed and the assignee", exchange: "ticket.events", routingKey: "ticket.updated",
+			body:   `{"tenant_id":"t1","ticket_id":"k1","conversation_id":"c1","assigned_agent_id":"u2"}`,
+			topics: []string{"conversation:c1", "ticket:k1", "tickets"}, agents: []string{"u2"},
+		},
+		{
+			name: "presence goes to presence subscribers", exchange: "conversation.events", routingKey: "agent.presence",
+			body:   `{"tenant_id":"t1","user_id":"u1","status":"online"}`,
+			topics: []string{"presence"},
+		},
+		{
+			name: "typing is not echoed to its sender", exchange: "conversation.events", routingKey: "conversation.typing",
+			body:   `{"tenant_id":"t1","conversation_id":"c1","user_id":"u1","typing":true}`,
+			topics: []string{"conversation:c1"}, sender: "u1",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ev, ok := routeEvent(tc.exchange, tc.routingKey, []byte(tc.body))
+			if !ok {
+				t.Fatal("event dropped")
+			}
+			if ev.tenantID != "t1" {
+				t.Errorf("tenant = %q, want t1", ev.tenantID)
+			}
+			if !reflect.DeepEqual(ev.topics, tc.topics) {
+				t.Errorf("topics = %v, want %v", ev.topics, tc.topics)
+			}
+			if !reflect.DeepEqual(ev.agents, tc.agents) {
+				t.Errorf("agents = %v, want %v", ev.agents, tc.agents)
+			}
+			if ev.sender != tc.sender {
+				t.Errorf("sender = %q, want %q", ev.sender, tc.sender)
+			}
+		})
+	}
+}
+
+func TestRouteEventDropsInvalidEvents(t *testing.T) {
+	for _, body := range []string{`not json`, `{"conversation_id":"c1"}`, `{"tenant_id":42}`} {
+		if _, ok := routeEvent("conversation.events", "message.received", []byte(body)); ok {
+			t.Errorf("routeEvent(%s) was accepted, want dropped", body)
+		}
+	}
+}
+
+func TestRouteEventAddsType(t *testing.T) {
+	ev, _ := routeEvent("conversation.events", "message.received", []byte(`{"tenant_id":"t1"}`))
+	var payload map[string]string
+	if err := json.Unmarshal(ev.body, &payload); err != nil || payload["type"] != "message.received" {
+		t.Errorf("body = %s, want type message.received", ev.body)
+	}
+
+	ev, _ = routeEvent("conversation.events", "message.received", []byte(`{"tenant_id":"t1","type":"custom"}`))
+	if err := json.Unmarshal(ev.body, &payload); err != nil || payload["type"] != "custom" {
+		t.Errorf("body = %s, want the payload's own type kept", ev.body)
+	}
+}
+
+func TestHubPublishDeliversToSubscribersOfTheTenant(t *testing.T) {
+	hub := newHub()
+	subscriber := newClient(nil, "t1", "u1")
+	subscriber.subscribe("conversation:c1")
+	otherTenant := newClient(nil, "t2", "u2")
+	otherTenant.subscribe("conversation:c1")
+	unsubscribed := newClient(nil, "t1", "u3")
+	assignee := newClient(nil, "t1", "u4")
+	assignee.subscribe(wsTopicAssignments)
+	sender := newClient(nil, "t1", "u5")
+	sender.subscribe("conversation:c1")
+	for _, c := range []*wsClient{subscriber, otherTenant, unsubscribed, assignee, sender} {
+		hub.add(c)
+	}
+
+	ev, _ := routeEvent("conversation.events", "conversation.assigned", []byte(`{"tenant_id":"t1","conversation_id":"c1","agent_id":"u4"}`))
+	ev.sender = "u5"
+	hub.publish(ev)
+
+	want := map[*wsClient]int{subscriber: 1, otherTenant: 0, unsubscribed: 0, assignee: 1, sender: 0}
+	names := map[*wsClient]string{subscriber: "subscriber", otherTenant: "other tenant", unsubscribed: "unsubscribed", assignee: "assignee", sender: "sender"}
+	for c, n := range want {
+		if got := len(c.send); got != n {
+			t.Errorf("%s received %d events, want %d", names[c], got, n)
+		}
+	}
+}
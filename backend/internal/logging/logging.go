@@ -0,0 +1,99 @@
+// Package logging configures the process-wide slog logger and carries request-scoped
+// fields (request id, user, tenant) through context.Context so that every
+// slog.*Context call in handlers, services and repositories is tagged with them.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	userIDKey
+	tenantIDKey
+)
+
+// Setup installs the default slog logger. format is "json" (default) or "text"; level is
+// debug, info (default), warn or error. The standard log package is routed through it too.
+func Setup(format, level string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: parseLevel(level)}
+
+	var h slog.Handler
+	if strings.EqualFold(format, "text") {
+		h = slog.NewTextHandler(os.Stdout, opts)
+	} else {
+		h = slog.NewJSONHandler(os.Stdout, opts)
+	}
+
+	logger := slog.New(contextHandler{h})
+	slog.SetDefault(logger)
+	return logger
+}
+
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// WithRequestID returns a context carrying the request id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request id carried by ctx, if any
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithUser returns a context carrying the authenticated user and tenant
+func WithUser(ctx context.Context, userID, tenantID string) context.Context {
+	ctx = context.WithValue(ctx, userIDKey, userID)
+	return context.WithValue(ctx, tenantIDKey, tenantID)
+}
+
+// Err is the attribute used for errors
+func Err(err error) slog.Attr {
+	return slog.Any("error", err)
+}
+
+// contextHandler adds the request-scoped fields of the record's context
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if id, ok := ctx.Value(requestIDKey).(string); ok && id != "" {
+			r.AddAttrs(slog.String("request_id", id))
+		}
+		if id, ok := ctx.Value(userIDKey).(string); ok && id != "" {
+			r.AddAttrs(slog.String("user_id", id))
+		}
+		if id, ok := ctx.Value(tenantIDKey).(string); ok && id != "" {
+			r.AddAttrs(slog.String("tenant_id", id))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,83 @@
+// Package metrics defines the Prometheus metrics exposed on /metrics
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// HTTPRequestDuration observes API latency by route template, method and status
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// WebhookIngested counts inbound webhook messages by channel and result
+	// (accepted, duplicate, rejected, error)
+	WebhookIngested = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_messages_total",
+		Help: "Inbound webhook messages by channel and result.",
+	}, []string{"channel", "result"})
+
+	// RabbitPublishFailures counts failed outbox publishes by exchange
+	RabbitPublishFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rabbitmq_publish_failures_total",
+		Help: "Failed RabbitMQ publishes by exchange.",
+	}, []string{"exchange"})
+
+	// RabbitConnected is 1 while the RabbitMQ connection is up
+	RabbitConnected = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "rabbitmq_connected",
+		Help: "Whether the RabbitMQ connection is up.",
+	})
+
+	// RabbitReconnects counts lost RabbitMQ connections
+	RabbitReconnects = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "rabbitmq_connection_losses_total",
+		Help: "Lost RabbitMQ connections.",
+	})
+
+	// WebsocketClients is the number of connected websocket clients
+	WebsocketClients = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "websocket_clients",
+		Help: "Connected websocket clients.",
+	})
+
+	// WebsocketEvictions counts clients disconnected because their send queue was full
+	WebsocketEvictions = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "websocket_slow_consumer_evictions_total",
+		Help: "Websocket clients closed for not keeping up with their events.",
+	})
+)
+
+var registry = prometheus.NewRegistry()
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		WebhookIngested,
+		RabbitPublishFailures,
+		RabbitConnected,
+		RabbitReconnects,
+		WebsocketClients,
+		WebsocketEvictions,
+	)
+}
+
+// RegisterDB exposes connection pool stats of db labeled with name
+func RegisterDB(db *sql.DB, name string) {
+	registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the registry in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
@@ -1,9 +1,13 @@
 package middleware
 
 import (
-	"fmt"
+	"context"
+	"crypto/subtle"
 	"net/http"
 	"strings"
+	"time"
+
+	"backend/internal/logging"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -14,15 +18,39 @@ type Claims struct {
 	TenantID string `json:"tenant_id"`
 	Email    string `json:"email"`
 	Role     string `json:"role"`
+	// IssuedAtMs is iat in milliseconds, so revocations are not rounded to the second
+	IssuedAtMs int64 `json:"iat_ms,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// RevocationChecker reports whether an access token has been revoked (logout, user deleted
+// or role changed)
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, jti, userID string, issuedAt time.Time) bool
+}
+
+// TokenRevoked checks the claims of a parsed token against the revocation list
+func TokenRevoked(ctx context.Context, revocations RevocationChecker, claims *Claims) bool {
+	if revocations == nil {
+		return false
+	}
+	var issuedAt time.Time
+	switch {
+	case claims.IssuedAtMs > 0:
+		issuedAt = time.UnixMilli(claims.IssuedAtMs)
+	case claims.IssuedAt != nil:
+		issuedAt = claims.IssuedAt.Time
+	}
+	return revocations.IsRevoked(ctx, claims.ID, claims.UserID, issuedAt)
+}
+
 func CORS() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, Idempotency-Key, X-Platform-Key, X-Request-ID")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Retry-After, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, Idempotent-Replayed, X-Request-ID")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -33,23 +61,9 @@ func CORS() gin.HandlerFunc {
 	}
 }
 
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
+func AuthMiddleware(jwtSecret string, revocations RevocationChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
-		// Enhanced debug: log header length, token length (not value), and proxy source info
-		hdrLen := len(authHeader)
-		xff := c.GetHeader("X-Forwarded-For")
-		remote := c.Request.RemoteAddr
-		if authHeader == "" {
-			fmt.Printf("[AuthMiddleware] Authorization header missing. method=%s path=%s remote=%s xff=%s hdrLen=%d\n", c.Request.Method, c.Request.RequestURI, remote, xff, hdrLen)
-		} else {
-			if strings.HasPrefix(authHeader, "Bearer ") {
-				tokenLen := len(strings.TrimPrefix(authHeader, "Bearer "))
-				fmt.Printf("[AuthMiddleware] Authorization present. method=%s path=%s remote=%s xff=%s hdrLen=%d tokenLen=%d\n", c.Request.Method, c.Request.RequestURI, remote, xff, hdrLen, tokenLen)
-			} else {
-				fmt.Printf("[AuthMiddleware] Authorization present but missing 'Bearer ' prefix. method=%s path=%s remote=%s xff=%s hdrLen=%d\n", c.Request.Method, c.Request.RequestURI, remote, xff, hdrLen)
-			}
-		}
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "Authorization header required"})
 			c.Abort()
@@ -74,21 +88,57 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 			return
 		}
 
+		if TokenRevoked(c.Request.Context(), revocations, claims) {
+			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "Token revoked"})
+			c.Abort()
+			return
+		}
+
 		// Set user info in context
 		c.Set("user_id", claims.UserID)
 		c.Set("tenant_id", claims.TenantID)
 		c.Set("email", claims.Email)
 		c.Set("role", claims.Role)
+		c.Set("jti", claims.ID)
+		if claims.ExpiresAt != nil {
+			c.Set("token_expires_at", claims.ExpiresAt.Time)
+		}
+		c.Request = c.Request.WithContext(logging.WithUser(c.Request.Context(), claims.UserID, claims.TenantID))
 
 		c.Next()
 	}
 }
 
-func AdminOnly() gin.HandlerFunc {
+// PermissionChecker resolves whether a tenant role grants a named permission
+type PermissionChecker interface {
+	HasPermission(ctx context.Context, tenantID, role, permission string) bool
+}
+
+// RequirePermission aborts with 403 unless the caller's role grants permission.
+// It must run after AuthMiddleware.
+func RequirePermission(checker PermissionChecker, permission string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists || role != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "Admin access required"})
+		_, tenantID, role := GetUserFromContext(c)
+		if role == "" || !checker.HasPermission(c.Request.Context(), tenantID, role, permission) {
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "Permission required: " + permission})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// PlatformKey guards the platform (operator) API with a static key sent as X-Platform-Key.
+// An empty key disables the platform API.
+func PlatformKey(key string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key == "" {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Platform API disabled"})
+			c.Abort()
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Platform-Key")), []byte(key)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "Invalid platform key"})
 			c.Abort()
 			return
 		}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/internal/logging"
+	"backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimiter takes a token from the bucket key of a route group
+type RateLimiter interface {
+	Allow(ctx context.Context, group, key string, limit model.RateLimit) (model.RateLimitResult, error)
+}
+
+// RateLimitKey derives the bucket of a request; an empty key skips limiting
+type RateLimitKey func(c *gin.Context) string
+
+// Bucket keys; ByChannel uses the channel a webhook signature was verified for, so it must
+// run after the verification and unsigned requests cannot drain a channel's bucket
+var (
+	ByIP      RateLimitKey = func(c *gin.Context) string { return "ip:" + c.ClientIP() }
+	ByUser    RateLimitKey = func(c *gin.Context) string { return prefixed("user:", c.GetString("user_id")) }
+	ByTenant  RateLimitKey = func(c *gin.Context) string { return prefixed("tenant:", c.GetString("tenant_id")) }
+	ByChannel RateLimitKey = func(c *gin.Context) string { return prefixed("channel:", c.GetString("channel_id")) }
+)
+
+func prefixed(prefix, id string) string {
+	if id == "" {
+		return ""
+	}
+	return prefix + id
+}
+
+// RateLimit enforces limit per bucket within group and sets X-RateLimit-Limit, X-RateLimit-Remaining
+// and X-RateLimit-Reset (seconds until the bucket is full). Exhausted buckets get 429 with
+// Retry-After. Limiter errors are logged and the request is let through.
+func RateLimit(limiter RateLimiter, group string, limit model.RateLimit, key RateLimitKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		k := key(c)
+		if k == "" || limit.Requests <= 0 {
+			c.Next()
+			return
+		}
+
+		res, err := limiter.Allow(c.Request.Context(), group, k, limit)
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "ratelimit: limiter failed", "group", group, "bucket", k, logging.Err(err))
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit.Requests))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter)))
+		if !res.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			c.JSON(http.StatusTooManyRequests, gin.H{"success": false, "message": "Rate limit exceeded"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 )
 
@@ -12,9 +13,12 @@ type User struct {
 	Email     string    `json:"email" db:"email"`
 	Password  string    `json:"-" db:"password"`
 	Name      string    `json:"name" db:"name"`
-	Role      string    `json:"role" db:"role"` // admin, agent
+	Role      string    `json:"role" db:"role"` // built-in (admin, supervisor, agent, viewer) or custom role name
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+
+	// Open conversations auto-assignment gives this agent; 0 uses the tenant's max_open_per_agent
+	MaxConversations int `json:"max_conversations" db:"max_conversations"`
 }
 
 // Customer represents a customer from external channels
@@ -40,6 +44,22 @@ type Conversation struct {
 	CreatedAt       time.Time      `json:"created_at" db:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at" db:"updated_at"`
 
+	// SLA tracking
+	SLAPolicyID        sql.NullString `json:"sla_policy_id" db:"sla_policy_id"`
+	FirstResponseDueAt sql.NullTime   `json:"first_response_due_at" db:"first_response_due_at"`
+	ResolutionDueAt    sql.NullTime   `json:"resolution_due_at" db:"resolution_due_at"`
+	FirstResponseAt    sql.NullTime   `json:"first_response_at" db:"first_response_at"`
+	ResolvedAt         sql.NullTime   `json:"resolved_at" db:"resolved_at"`
+	SLAStatus          string         `json:"sla_status" db:"sla_status"` // none, ok, warning, breached
+
+	// Per-deadline SLA state; sla_status is the worst state either deadline reached
+	FirstResponseSLAStatus string `json:"first_response_sla_status" db:"first_response_sla_status"`
+	ResolutionSLAStatus    string `json:"resolution_sla_status" db:"resolution_sla_status"`
+
+	// Soft deletion; deleted conversations are hidden until restored or purged
+	DeletedAt sql.NullTime   `json:"deleted_at" db:"deleted_at"`
+	DeletedBy sql.NullString `json:"deleted_by" db:"deleted_by"`
+
 	// Joined fields
 	CustomerName       string `json:"customer_name,omitempty" db:"customer_name"`
 	CustomerExternalID string `json:"customer_external_id,omitempty" db:"customer_external_id"`
@@ -47,6 +67,9 @@ type Conversation struct {
 	LastMessage        string `json:"last_message,omitempty" db:"last_message"`
 	HasTicket          bool   `json:"has_ticket" db:"has_ticket"`
 	SelectedTicketID   sql.NullString `json:"ticket_id,omitempty" db:"selected_ticket_id"`
+
+	// Customer messages after the requesting agent's read marker; only set in lists
+	UnreadCount int `json:"unread_count" db:"unread_count"`
 }
 
 // Message represents a message in a conversation
@@ -58,6 +81,48 @@ type Message struct {
 	SenderName     string    `json:"sender_name" db:"sender_name"`
 	Message        string    `json:"message" db:"message"`
 	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+
+	// Provider message id for inbound messages, unique per tenant and channel; both are copied
+	// from the conversation when the message is stored
+	ExternalMessageID string `json:"external_message_id,omitempty" db:"external_message_id"`
+	TenantID          string `json:"tenant_id" db:"tenant_id"`
+	Channel           string `json:"channel" db:"channel"`
+
+	// Outbound delivery (agent messages only)
+	DeliveryStatus   string `json:"delivery_status,omitempty" db:"delivery_status"` // queued, sent, delivered, failed
+	DeliveryAttempts int    `json:"delivery_attempts,omitempty" db:"delivery_attempts"`
+	DeliveryError    string `json:"delivery_error,omitempty" db:"delivery_error"`
+
+	// Set when the text was edited; previous versions are kept as MessageEdit rows
+	EditedAt sql.NullTime `json:"edited_at" db:"edited_at"`
+
+	// Soft deletion; deleted messages are hidden until restored or purged
+	DeletedAt sql.NullTime   `json:"deleted_at" db:"deleted_at"`
+	DeletedBy sql.NullString `json:"deleted_by" db:"deleted_by"`
+}
+
+// ConversationRead is an agent's read marker on a conversation
+type ConversationRead struct {
+	ConversationID    string    `json:"conversation_id" db:"conversation_id"`
+	UserID            string    `json:"user_id" db:"user_id"`
+	UserName          string    `json:"user_name" db:"user_name"`
+	LastReadMessageID string    `json:"last_read_message_id" db:"last_read_message_id"`
+	LastReadAt        time.Time `json:"last_read_at" db:"last_read_at"`
+}
+
+// MarkReadRequest moves the read marker to MessageID, or to the latest message when empty
+type MarkReadRequest struct {
+	MessageID string `json:"message_id"`
+}
+
+// MessageEdit is the text a message had before one edit
+type MessageEdit struct {
+	ID              string    `json:"id" db:"id"`
+	MessageID       string    `json:"message_id" db:"message_id"`
+	PreviousMessage string    `json:"previous_message" db:"previous_message"`
+	EditedBy        string    `json:"edited_by" db:"edited_by"`
+	EditedByName    string    `json:"edited_by_name" db:"edited_by_name"`
+	EditedAt        time.Time `json:"edited_at" db:"edited_at"`
 }
 
 // Ticket represents an escalated ticket
@@ -71,10 +136,26 @@ type Ticket struct {
 	Status          string         `json:"status" db:"status"`     // open, in_progress, resolved, closed
 	Priority        string         `json:"priority" db:"priority"` // low, medium, high, urgent
 	AssignedAgentID sql.NullString `json:"assigned_agent_id" db:"assigned_agent_id"`
-	CreatedByID     string         `json:"created_by_id" db:"created_by_id"`
+	CreatedByID     sql.NullString `json:"created_by_id" db:"created_by_id"` // NULL once the creator is deleted
 	CreatedAt       time.Time      `json:"created_at" db:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at" db:"updated_at"`
 
+	// SLA tracking
+	SLAPolicyID        sql.NullString `json:"sla_policy_id" db:"sla_policy_id"`
+	FirstResponseDueAt sql.NullTime   `json:"first_response_due_at" db:"first_response_due_at"`
+	ResolutionDueAt    sql.NullTime   `json:"resolution_due_at" db:"resolution_due_at"`
+	FirstResponseAt    sql.NullTime   `json:"first_response_at" db:"first_response_at"`
+	ResolvedAt         sql.NullTime   `json:"resolved_at" db:"resolved_at"`
+	SLAStatus          string         `json:"sla_status" db:"sla_status"` // none, ok, warning, breached
+
+	// Per-deadline SLA state; sla_status is the worst state either deadline reached
+	FirstResponseSLAStatus string `json:"first_response_sla_status" db:"first_response_sla_status"`
+	ResolutionSLAStatus    string `json:"resolution_sla_status" db:"resolution_sla_status"`
+
+	// Soft deletion; deleted tickets are hidden until restored or purged
+	DeletedAt sql.NullTime   `json:"deleted_at" db:"deleted_at"`
+	DeletedBy sql.NullString `json:"deleted_by" db:"deleted_by"`
+
 	// Joined fields
 	AssignedAgentName string `json:"assigned_agent_name,omitempty" db:"assigned_agent_name"`
 	CreatedByName     string `json:"created_by_name,omitempty" db:"created_by_name"`
@@ -90,6 +171,67 @@ type Event struct {
 	Data       string    `json:"data" db:"data"`
 	UserID     string    `json:"user_id" db:"user_id"`
 	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+
+	// Snapshots of the entity around a change, JSON; NULL when not recorded
+	BeforeData sql.NullString `json:"-" db:"before_data"`
+	AfterData  sql.NullString `json:"-" db:"after_data"`
+}
+
+// TimelineEntry is one item of a conversation or ticket activity feed: a message or an events row.
+// Type is message.received / message.sent for messages and the event type otherwise.
+type TimelineEntry struct {
+	ID        string          `json:"id" db:"id"`
+	Kind      string          `json:"kind" db:"kind"` // message, event
+	Type      string          `json:"type" db:"type"`
+	ActorType string          `json:"actor_type" db:"actor_type"` // customer, user, system
+	ActorID   string          `json:"actor_id,omitempty" db:"actor_id"`
+	ActorName string          `json:"actor_name,omitempty" db:"actor_name"`
+	Data      json.RawMessage `json:"data" db:"-"`
+	RawData   string          `json:"-" db:"data"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+// AuditEntry is an events row as shown in the tenant audit log, with the actor's name resolved
+type AuditEntry struct {
+	ID         string          `json:"id" db:"id"`
+	EventType  string          `json:"event_type" db:"event_type"`
+	EntityType string          `json:"entity_type" db:"entity_type"`
+	EntityID   string          `json:"entity_id" db:"entity_id"`
+	ActorID    string          `json:"actor_id,omitempty" db:"actor_id"`
+	ActorName  string          `json:"actor_name,omitempty" db:"actor_name"`
+	Data       json.RawMessage `json:"data,omitempty" db:"-"`
+	Before     json.RawMessage `json:"before,omitempty" db:"-"`
+	After      json.RawMessage `json:"after,omitempty" db:"-"`
+	RawData    string          `json:"-" db:"data"`
+	RawBefore  sql.NullString  `json:"-" db:"before_data"`
+	RawAfter   sql.NullString  `json:"-" db:"after_data"`
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+}
+
+// AuditFilter selects audit log entries; EventType and EntityType accept comma separated sets.
+// The time range is ListParams.CreatedFrom / CreatedTo.
+type AuditFilter struct {
+	ActorID    string `form:"actor_id"`
+	EntityType string `form:"entity_type"`
+	EntityID   string `form:"entity_id"`
+	EventType  string `form:"event_type"`
+	ListParams
+	PaginationParams
+}
+
+// OutboxMessage is an event waiting to be published to RabbitMQ by the outbox relay
+type OutboxMessage struct {
+	ID          string       `json:"id" db:"id"`
+	TenantID    string       `json:"tenant_id" db:"tenant_id"`
+	Exchange    string       `json:"exchange" db:"exchange"`
+	RoutingKey  string       `json:"routing_key" db:"routing_key"`
+	Payload     string       `json:"payload" db:"payload"`
+	Status      string       `json:"status" db:"status"` // pending, sent
+	Attempts    int          `json:"attempts" db:"attempts"`
+	LastError   string       `json:"last_error" db:"last_error"`
+	AvailableAt time.Time    `json:"available_at" db:"available_at"`
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+	SentAt      sql.NullTime `json:"sent_at" db:"sent_at"`
 }
 
 // Channel represents an inbound/outbound channel configuration
@@ -99,10 +241,187 @@ type Channel struct {
 	Name        string    `json:"name" db:"name"`
 	Slug        string    `json:"slug" db:"slug"`
 	Description string    `json:"description" db:"description"`
+	OutboundURL string    `json:"outbound_url" db:"outbound_url"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+
+	// WebhookSecret signs inbound webhooks; only returned on create and rotate
+	WebhookSecret string `json:"webhook_secret,omitempty" db:"webhook_secret"`
+	InboundURL    string `json:"inbound_url,omitempty" db:"-"`
+}
+
+// SLAPolicy defines response and resolution targets for tickets (matched by priority)
+// or conversations (matched by channel); an empty MatchValue is the tenant default
+type SLAPolicy struct {
+	ID                   string    `json:"id" db:"id"`
+	TenantID             string    `json:"tenant_id" db:"tenant_id"`
+	Name                 string    `json:"name" db:"name"`
+	TargetType           string    `json:"target_type" db:"target_type"` // ticket, conversation
+	MatchValue           string    `json:"match_value" db:"match_value"`
+	FirstResponseMinutes int       `json:"first_response_minutes" db:"first_response_minutes"`
+	ResolutionMinutes    int       `json:"resolution_minutes" db:"resolution_minutes"`
+	WarningPercent       int       `json:"warning_percent" db:"warning_percent"`
+	CreatedAt            time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// SLAAlert is a conversation or ticket whose SLA state changed during a check
+type SLAAlert struct {
+	ID       string `db:"id"`
+	TenantID string `db:"tenant_id"`
+	Deadline string `db:"deadline"` // first_response, resolution
+}
+
+// SLAState is the overall and per-deadline SLA status of a conversation or ticket
+type SLAState struct {
+	Status        string `db:"sla_status"`
+	FirstResponse string `db:"first_response_sla_status"`
+	Resolution    string `db:"resolution_sla_status"`
+}
+
+// IdempotencyKey maps a client or provider supplied key to the message it produced
+type IdempotencyKey struct {
+	TenantID       string         `json:"tenant_id" db:"tenant_id"`
+	Scope          string         `json:"scope" db:"scope"`
+	Key            string         `json:"key" db:"key"`
+	ConversationID sql.NullString `json:"conversation_id" db:"conversation_id"`
+	MessageID      sql.NullString `json:"message_id" db:"message_id"`
+	CreatedAt      time.Time      `json:"created_at" db:"created_at"`
+}
+
+// AssignmentSettings holds the per-tenant auto-assignment configuration
+type AssignmentSettings struct {
+	TenantID            string         `json:"tenant_id" db:"tenant_id"`
+	Enabled             bool           `json:"enabled" db:"enabled"`
+	Strategy            string         `json:"strategy" db:"strategy"` // round_robin, least_open, channel_match
+	MaxOpenPerAgent     int            `json:"max_open_per_agent" db:"max_open_per_agent"`
+	RequireOnline       bool           `json:"require_online" db:"require_online"` // only assign agents whose presence is online
+	LastAssignedAgentID sql.NullString `json:"last_assigned_agent_id" db:"last_assigned_agent_id"`
+	UpdatedAt           time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// AgentLoad is an assignment candidate together with its current workload
+type AgentLoad struct {
+	ID                string `json:"id" db:"id"`
+	Name              string `json:"name" db:"name"`
+	OpenConversations int    `json:"open_conversations" db:"open_conversations"`
+	MaxConversations  int    `json:"max_conversations" db:"max_conversations"`
+	ChannelMatch      bool   `json:"channel_match" db:"channel_match"`
+}
+
+// Presence statuses. An agent without a live websocket connection is offline whatever
+// status they chose.
+const (
+	PresenceOnline  = "online"
+	PresenceAway    = "away"
+	PresenceOffline = "offline"
+)
+
+// AgentPresence is the availability of a tenant user
+type AgentPresence struct {
+	UserID           string `json:"user_id"`
+	Name             string `json:"name"`
+	Role             string `json:"role"`
+	Status           string `json:"status"`        // effective status
+	ManualStatus     string `json:"manual_status"` // status chosen by the agent
+	Connections      int    `json:"connections"`
+	MaxConversations int    `json:"max_conversations"`
+}
+
+// Tenant is an organization using the helpdesk. Suspended tenants cannot log in or receive webhooks.
+type Tenant struct {
+	ID           string                 `json:"id" db:"id"`
+	Name         string                 `json:"name" db:"name"`
+	Plan         string                 `json:"plan" db:"plan"` // free, pro, enterprise
+	Settings     map[string]interface{} `json:"settings" db:"-"`
+	SettingsData string                 `json:"-" db:"settings"`    // JSON object
+	Status       string                 `json:"status" db:"status"` // active, suspended
+	SuspendedAt  *time.Time             `json:"suspended_at" db:"suspended_at"`
+	CreatedAt    time.Time              `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time              `json:"updated_at" db:"updated_at"`
+}
+
+// Invite lets a new user join a tenant with a given role; only the token hash is stored
+type Invite struct {
+	ID         string     `json:"id" db:"id"`
+	TenantID   string     `json:"tenant_id" db:"tenant_id"`
+	Email      string     `json:"email" db:"email"`
+	Role       string     `json:"role" db:"role"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	InvitedBy  string     `json:"invited_by" db:"invited_by"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at" db:"accepted_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+
+	// Token is only returned when the invite is created
+	Token string `json:"token,omitempty" db:"-"`
+}
+
+// RateLimit is a token bucket refilled at Requests per Window, allowing bursts of up to Requests
+type RateLimit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// RateLimitResult is the outcome of taking a token from a bucket
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration // until a token is available, when not allowed
+	ResetAfter time.Duration // until the bucket is full again
 }
 
+// QuotaUsage is a tenant's message usage for the current calendar month (UTC)
+type QuotaUsage struct {
+	Month    string `json:"month"` // YYYY-MM
+	Plan     string `json:"plan"`
+	Messages int64  `json:"messages"`
+	Limit    int64  `json:"limit"` // 0 means unlimited
+}
+
+// Role is a named set of permissions. Built-in roles are defined in code and have no ID;
+// custom roles are stored per tenant.
+type Role struct {
+	ID             string    `json:"id,omitempty" db:"id"`
+	TenantID       string    `json:"tenant_id,omitempty" db:"tenant_id"`
+	Name           string    `json:"name" db:"name"`
+	Description    string    `json:"description" db:"description"`
+	Permissions    []string  `json:"permissions" db:"-"`
+	PermissionData string    `json:"-" db:"permissions"` // JSON array of permission names
+	BuiltIn        bool      `json:"built_in" db:"-"`
+	CreatedAt      time.Time `json:"created_at,omitempty" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// Permission names checked per route by middleware.RequirePermission
+const (
+	PermConversationRead   = "conversation.read"
+	PermConversationCreate = "conversation.create"
+	PermConversationUpdate = "conversation.update"
+	PermConversationDelete = "conversation.delete"
+	PermConversationReply  = "conversation.reply"
+	PermConversationAssign = "conversation.assign"
+	PermConversationClose  = "conversation.close"
+	PermMessageEdit        = "message.edit"
+	PermMessageDelete      = "message.delete"
+	PermMessageModerate    = "message.moderate"
+	PermTicketRead         = "ticket.read"
+	PermTicketCreate       = "ticket.create"
+	PermTicketUpdate       = "ticket.update"
+	PermTicketDelete       = "ticket.delete"
+	PermTicketStatusUpdate = "ticket.status.update"
+	PermChannelRead        = "channel.read"
+	PermChannelManage      = "channel.manage"
+	PermUserRead           = "user.read"
+	PermUserManage         = "user.manage"
+	PermRoleManage         = "role.manage"
+	PermAssignmentManage   = "assignment.manage"
+	PermSLAManage          = "sla.manage"
+	PermTenantManage       = "tenant.manage"
+	PermAuditRead          = "audit.read"
+	PermDeletedRestore     = "deleted.restore"
+)
+
 // Request/Response DTOs
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
@@ -110,29 +429,74 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // access token lifetime in seconds
+	User         User   `json:"user"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
+// RegisterRequest accepts an invite; tenant, email and role come from the invite
 type RegisterRequest struct {
-	TenantID string `json:"tenant_id" binding:"required"`
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
-	Name     string `json:"name" binding:"required"`
-	Role     string `json:"role" binding:"required,oneof=admin agent"`
+	InviteToken string `json:"invite_token" binding:"required"`
+	Password    string `json:"password" binding:"required,min=6"`
+	Name        string `json:"name" binding:"required"`
 }
 
+// OnboardTenantRequest creates a tenant together with its first admin
+type OnboardTenantRequest struct {
+	TenantName    string `json:"tenant_name" binding:"required"`
+	Plan          string `json:"plan" binding:"omitempty,oneof=free pro enterprise"`
+	AdminEmail    string `json:"admin_email" binding:"required,email"`
+	AdminName     string `json:"admin_name" binding:"required"`
+	AdminPassword string `json:"admin_password" binding:"required,min=6"`
+}
+
+// TenantOnboarding is the result of onboarding: the tenant and its first admin
+type TenantOnboarding struct {
+	Tenant Tenant `json:"tenant"`
+	Admin  User   `json:"admin"`
+}
+
+type UpdateTenantRequest struct {
+	Name     string                 `json:"name"`
+	Settings map[string]interface{} `json:"settings"`
+}
+
+type UpdateTenantPlanRequest struct {
+	Plan string `json:"plan" binding:"required,oneof=free pro enterprise"`
+}
+
+type CreateInviteRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required"`
+}
+
+// WebhookRequest is the inbound message payload. TenantID and Channel are ignored on
+// signed channel webhooks, where both are taken from the channel.
 type WebhookRequest struct {
-	TenantID           string `json:"tenant_id" binding:"required"`
+	TenantID           string `json:"tenant_id"`
 	CustomerExternalID string `json:"customer_external_id" binding:"required"`
-	Channel            string `json:"channel"`
+	Channel            string `json:"channel" binding:"max=50"`
 	Message            string `json:"message" binding:"required"`
+	ExternalMessageID  string `json:"external_message_id" binding:"max=255"`
 }
 
 type SendMessageRequest struct {
 	Message string `json:"message" binding:"required"`
 }
 
+type EditMessageRequest struct {
+	Message string `json:"message" binding:"required"`
+}
+
 type EscalateRequest struct {
 	Title       string `json:"title" binding:"omitempty"`
 	Description string `json:"description" binding:"omitempty"`
@@ -148,13 +512,44 @@ type CreateUserRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
 	Name     string `json:"name" binding:"required"`
-	Role     string `json:"role" binding:"required,oneof=admin agent"`
+	Role     string `json:"role" binding:"required"`
 }
 
 type UpdateUserRequest struct {
 	Email string `json:"email"`
 	Name  string `json:"name"`
-	Role  string `json:"role" binding:"omitempty,oneof=admin agent"`
+	Role  string `json:"role"`
+}
+
+type RoleRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+type SLAPolicyRequest struct {
+	Name                 string `json:"name" binding:"required"`
+	TargetType           string `json:"target_type" binding:"required,oneof=ticket conversation"`
+	MatchValue           string `json:"match_value"`
+	FirstResponseMinutes int    `json:"first_response_minutes" binding:"required,min=1"`
+	ResolutionMinutes    int    `json:"resolution_minutes" binding:"required,min=1"`
+	WarningPercent       int    `json:"warning_percent" binding:"omitempty,min=1,max=99"`
+}
+
+type UpdateAssignmentSettingsRequest struct {
+	Enabled         *bool  `json:"enabled"`
+	Strategy        string `json:"strategy" binding:"omitempty,oneof=round_robin least_open channel_match"`
+	MaxOpenPerAgent *int   `json:"max_open_per_agent" binding:"omitempty,min=0"`
+	RequireOnline   *bool  `json:"require_online"`
+}
+
+type UpdatePresenceRequest struct {
+	Status           string `json:"status" binding:"omitempty,oneof=online away offline"`
+	MaxConversations *int   `json:"max_conversations" binding:"omitempty,min=0"`
+}
+
+type UpdateAgentChannelsRequest struct {
+	Channels []string `json:"channels"`
 }
 
 type PaginationParams struct {
@@ -162,18 +557,63 @@ type PaginationParams struct {
 	PerPage int `form:"per_page" binding:"min=1,max=100"`
 }
 
+// ListParams holds the sort, keyset cursor and date range options shared by list endpoints.
+// Sort is a column name, prefixed with "-" for descending order; date bounds are inclusive.
+type ListParams struct {
+	Sort        string     `form:"sort"`
+	Cursor      string     `form:"cursor"`
+	CursorMode  bool       `form:"-"`
+	CreatedFrom *time.Time `form:"created_from"`
+	CreatedTo   *time.Time `form:"created_to"`
+	UpdatedFrom *time.Time `form:"updated_from"`
+	UpdatedTo   *time.Time `form:"updated_to"`
+}
+
+// Status, SLAStatus and Priority accept comma separated sets
 type ConversationFilter struct {
 	Status          string `form:"status"`
 	AssignedAgentID string `form:"assigned_agent_id"`
+	SLAStatus       string `form:"sla_status"`
+	Channel         string `form:"channel"`
+	CustomerID      string `form:"customer_id"`
+	HasTicket       *bool  `form:"has_ticket"`
+	Unassigned      bool   `form:"unassigned"`
+	Query           string `form:"q"`
+	ViewerID        string `form:"-"` // user whose read markers unread_count is counted from
+	ListParams
 	PaginationParams
 }
 
 type TicketFilter struct {
-	Status   string `form:"status"`
-	Priority string `form:"priority"`
+	Status          string `form:"status"`
+	Priority        string `form:"priority"`
+	SLAStatus       string `form:"sla_status"`
+	AssignedAgentID string `form:"assigned_agent_id"`
+	ConversationID  string `form:"conversation_id"`
+	Unassigned      bool   `form:"unassigned"`
+	Query           string `form:"q"`
+	ListParams
 	PaginationParams
 }
 
+// SearchFilter selects full-text search hits; Types restricts results to message, ticket and/or customer
+type SearchFilter struct {
+	Query string   `form:"q"`
+	Types []string `form:"-"`
+	PaginationParams
+}
+
+// SearchResult is one full-text search hit. Snippet is HTML-escaped with matches wrapped in <mark>.
+type SearchResult struct {
+	Type           string    `json:"type" db:"type"` // message, ticket, customer
+	ID             string    `json:"id" db:"id"`
+	ConversationID string    `json:"conversation_id,omitempty" db:"conversation_id"`
+	Title          string    `json:"title" db:"title"`
+	Snippet        string    `json:"snippet" db:"snippet"`
+	Rank           float64   `json:"rank" db:"rank"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
 type APIResponse struct {
 	Success bool        `json:"success"`
 	Data    interface{} `json:"data,omitempty"`
@@ -187,3 +627,27 @@ type PaginationMeta struct {
 	Total      int `json:"total"`
 	TotalPages int `json:"total_pages"`
 }
+
+// MessagePageQuery selects a window of conversation history; Before and After are message ids
+type MessagePageQuery struct {
+	Before string `form:"before"`
+	After  string `form:"after"`
+	Limit  int    `form:"limit"`
+}
+
+// MessagePageMeta describes a window of conversation history. Pass OldestID as before to load
+// older messages and NewestID as after to catch up on newer ones.
+type MessagePageMeta struct {
+	Limit    int    `json:"limit"`
+	HasOlder bool   `json:"has_older"`
+	HasNewer bool   `json:"has_newer"`
+	OldestID string `json:"oldest_id,omitempty"`
+	NewestID string `json:"newest_id,omitempty"`
+}
+
+// CursorMeta describes a keyset page; pass NextCursor as cursor to fetch the following page
+type CursorMeta struct {
+	PerPage    int    `json:"per_page"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
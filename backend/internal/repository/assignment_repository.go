@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type AssignmentRepository struct {
+	db DBTX
+}
+
+func NewAssignmentRepository(db *sqlx.DB) *AssignmentRepository {
+	return &AssignmentRepository{db: db}
+}
+
+// GetSettings returns the tenant settings, or disabled round-robin defaults when none are stored
+func (r *AssignmentRepository) GetSettings(ctx context.Context, tenantID string) (*model.AssignmentSettings, error) {
+	return r.getSettings(ctx, tenantID, `SELECT * FROM assignment_settings WHERE tenant_id = :tenant_id`)
+}
+
+// UpsertSettings stores settings as the tenant's; settings.TenantID is overwritten
+func (r *AssignmentRepository) UpsertSettings(ctx context.Context, tenantID string, settings *model.AssignmentSettings) error {
+	settings.TenantID = tenantID
+	settings.UpdatedAt = time.Now()
+	query := `INSERT INTO assignment_settings (tenant_id, enabled, strategy, max_open_per_agent, require_online, updated_at)
+			  VALUES (:tenant_id, :enabled, :strategy, :max_open_per_agent, :require_online, :updated_at)
+			  ON CONFLICT (tenant_id) DO UPDATE SET enabled = EXCLUDED.enabled, strategy = EXCLUDED.strategy,
+			  max_open_per_agent = EXCLUDED.max_open_per_agent, require_online = EXCLUDED.require_online, updated_at = EXCLUDED.updated_at`
+	_, err := scoped(r.db, tenantID).exec(ctx, query, map[string]interface{}{
+		"enabled":            settings.Enabled,
+		"strategy":           settings.Strategy,
+		"max_open_per_agent": settings.MaxOpenPerAgent,
+		"require_online":     settings.RequireOnline,
+		"updated_at":         settings.UpdatedAt,
+	})
+	return err
+}
+
+// LockSettings is GetSettings that also locks the tenant's settings row until the transaction
+// ends, so auto-assignments of one tenant run one after the other. Must be called inside a
+// transaction; without a stored row auto-assignment is disabled and nothing needs locking.
+func (r *AssignmentRepository) LockSettings(ctx context.Context, tenantID string) (*model.AssignmentSettings, error) {
+	return r.getSettings(ctx, tenantID, `SELECT * FROM assignment_settings WHERE tenant_id = :tenant_id FOR UPDATE`)
+}
+
+func (r *AssignmentRepository) getSettings(ctx context.Context, tenantID, query string) (*model.AssignmentSettings, error) {
+	var settings model.AssignmentSettings
+	err := scoped(r.db, tenantID).get(ctx, &settings, query, nil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &model.AssignmentSettings{TenantID: tenantID, Strategy: "round_robin"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// SetLastAssigned records the round-robin cursor for a tenant
+func (r *AssignmentRepository) SetLastAssigned(ctx context.Context, tenantID, agentID string) error {
+	query := `INSERT INTO assignment_settings (tenant_id, last_assigned_agent_id, updated_at) VALUES (:tenant_id, :agent_id, :updated_at)
+			  ON CONFLICT (tenant_id) DO UPDATE SET last_assigned_agent_id = EXCLUDED.last_assigned_agent_id, updated_at = EXCLUDED.updated_at`
+	_, err := scoped(r.db, tenantID).exec(ctx, query, map[string]interface{}{"agent_id": agentID, "updated_at": time.Now()})
+	return err
+}
+
+// ListCandidates returns the users of a tenant holding one of roles, ordered by id with their
+// open conversation count and whether they are configured for the given channel
+func (r *AssignmentRepository) ListCandidates(ctx context.Context, tenantID, channel string, roles []string) ([]model.AgentLoad, error) {
+	var agents []model.AgentLoad
+	query := `
+		SELECT u.id, u.name, u.max_conversations,
+			   (SELECT COUNT(*) FROM conversations c WHERE c.assigned_agent_id = u.id AND c.status != 'closed' AND c.deleted_at IS NULL) as open_conversations,
+			   EXISTS(SELECT 1 FROM agent_channels ac WHERE ac.user_id = u.id AND ac.channel = :channel) as channel_match
+		FROM users u
+		WHERE u.tenant_id = :tenant_id AND u.role = ANY(:roles)
+		ORDER BY u.id ASC`
+	err := scoped(r.db, tenantID).selectAll(ctx, &agents, query, map[string]interface{}{"channel": channel, "roles": roles})
+	return agents, err
+}
+
+// agentOfTenant restricts agent_channels statements to users of the scope's tenant
+const agentOfTenant = `EXISTS(SELECT 1 FROM users u WHERE u.id = :user_id AND u.tenant_id = :tenant_id)`
+
+func (r *AssignmentRepository) GetAgentChannels(ctx context.Context, userID, tenantID string) ([]string, error) {
+	channels := []string{}
+	query := `SELECT channel FROM agent_channels WHERE user_id = :user_id AND ` + agentOfTenant + ` ORDER BY channel ASC`
+	err := scoped(r.db, tenantID).selectAll(ctx, &channels, query, map[string]interface{}{"user_id": userID})
+	return channels, err
+}
+
+// SetAgentChannels replaces the channel skills of an agent of the tenant; run it inside a transaction
+func (r *AssignmentRepository) SetAgentChannels(ctx context.Context, userID, tenantID string, channels []string) error {
+	scope := scoped(r.db, tenantID)
+	if _, err := scope.exec(ctx, `DELETE FROM agent_channels WHERE user_id = :user_id AND `+agentOfTenant, map[string]interface{}{"user_id": userID}); err != nil {
+		return err
+	}
+	for _, ch := range channels {
+		query := `INSERT INTO agent_channels (user_id, channel, created_at) SELECT :user_id, :channel, :created_at WHERE ` + agentOfTenant + ` ON CONFLICT DO NOTHING`
+		if _, err := scope.exec(ctx, query, map[string]interface{}{"user_id": userID, "channel": ch, "created_at": time.Now()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+
+	"backend/internal/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// auditSorts are the sort keys accepted by the audit log; the default is newest first
+var auditSorts = map[string]string{
+	"created_at": "e.created_at",
+}
+
+const auditSelect = `
+		SELECT e.id, e.event_type, e.entity_type, e.entity_id,
+			   COALESCE(e.user_id, '') AS actor_id, COALESCE(u.name, '') AS actor_name,
+			   COALESCE(e.data::text, 'null') AS data, e.before_data::text AS before_data, e.after_data::text AS after_data,
+			   e.created_at
+		FROM events e
+		LEFT JOIN users u ON u.id = e.user_id AND u.tenant_id = e.tenant_id`
+
+// AuditRepository reads the tenant audit log from the events table
+type AuditRepository struct {
+	db *sqlx.DB
+}
+
+func NewAuditRepository(db *sqlx.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// List returns the keyset page of audit entries following filter.Cursor and the cursor of the
+// next page, which is empty on the last page
+func (r *AuditRepository) List(ctx context.Context, tenantID string, filter model.AuditFilter) ([]model.AuditEntry, string, error) {
+	entries := []model.AuditEntry{}
+
+	sort, err := resolveSort(filter.Sort, "-created_at", auditSorts)
+	if err != nil {
+		return nil, "", err
+	}
+	query, args := auditWhere(tenantID, filter)
+	query, args, err = sort.after(query, args, "e.id", filter.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query = r.db.Rebind(auditSelect + query + sort.orderBy("e.id") + ` LIMIT ?`)
+	args = append(args, filter.PerPage+1)
+	if err := r.db.SelectContext(ctx, &entries, query, args...); err != nil {
+		return nil, "", err
+	}
+	for i := range entries {
+		decodeAuditEntry(&entries[i])
+	}
+
+	if len(entries) <= filter.PerPage {
+		return entries, "", nil
+	}
+	entries = entries[:filter.PerPage]
+	last := entries[len(entries)-1]
+	return entries, encodeCursor(listCursor{Sort: sort.key, At: last.CreatedAt, ID: last.ID}), nil
+}
+
+// Export streams every entry matching filter to fn in sort order, without paging, and stops at
+// the first error fn returns
+func (r *AuditRepository) Export(ctx context.Context, tenantID string, filter model.AuditFilter, fn func(*model.AuditEntry) error) error {
+	sort, err := resolveSort(filter.Sort, "-created_at", auditSorts)
+	if err != nil {
+		return err
+	}
+	query, args := auditWhere(tenantID, filter)
+	query = r.db.Rebind(auditSelect + query + sort.orderBy("e.id"))
+
+	rows, err := r.db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry model.AuditEntry
+		if err := rows.StructScan(&entry); err != nil {
+			return err
+		}
+		decodeAuditEntry(&entry)
+		if err := fn(&entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// auditWhere builds the WHERE clause shared by List and Export
+func auditWhere(tenantID string, filter model.AuditFilter) (string, []interface{}) {
+	query := ` WHERE e.tenant_id = ?`
+	args := []interface{}{tenantID}
+
+	query, args = addInFilter(query, args, "e.event_type", filter.EventType)
+	query, args = addInFilter(query, args, "e.entity_type", filter.EntityType)
+	if filter.EntityID != "" {
+		query += ` AND e.entity_id = ?`
+		args = append(args, filter.EntityID)
+	}
+	if filter.ActorID != "" {
+		query += ` AND e.user_id = ?`
+		args = append(args, filter.ActorID)
+	}
+	if filter.CreatedFrom != nil {
+		query += ` AND e.created_at >= ?`
+		args = append(args, *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query += ` AND e.created_at <= ?`
+		args = append(args, *filter.CreatedTo)
+	}
+	return query, args
+}
+
+func decodeAuditEntry(entry *model.AuditEntry) {
+	if entry.RawData != "null" {
+		entry.Data = json.RawMessage(entry.RawData)
+	}
+	if entry.RawBefore.Valid {
+		entry.Before = json.RawMessage(entry.RawBefore.String)
+	}
+	if entry.RawAfter.Valid {
+		entry.After = json.RawMessage(entry.RawAfter.String)
+	}
+}
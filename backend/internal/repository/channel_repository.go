@@ -12,14 +12,15 @@ import (
 )
 
 type ChannelRepository struct {
-	db *sqlx.DB
+	db DBTX
 }
 
 func NewChannelRepository(db *sqlx.DB) *ChannelRepository {
 	return &ChannelRepository{db: db}
 }
 
-func (r *ChannelRepository) Create(ctx context.Context, ch *model.Channel) error {
+// Create inserts a channel of the tenant; ch.TenantID is overwritten
+func (r *ChannelRepository) Create(ctx context.Context, tenantID string, ch *model.Channel) error {
 	// Ensure slug defaults from name when missing
 	if ch.Slug == "" && ch.Name != "" {
 		ch.Slug = strings.ToLower(strings.ReplaceAll(ch.Name, " ", "-"))
@@ -29,49 +30,89 @@ func (r *ChannelRepository) Create(ctx context.Context, ch *model.Channel) error
 		ch.Name = ch.Slug
 	}
 
-	query := `INSERT INTO channels (id, tenant_id, name, slug, description, created_at, updated_at) VALUES ($1,$2,$3,$4,$5,$6,$7)`
-	query = r.db.Rebind(query)
+	query := `INSERT INTO channels (id, tenant_id, name, slug, description, outbound_url, webhook_secret, created_at, updated_at)
+			  VALUES (:id, :tenant_id, :name, :slug, :description, :outbound_url, :webhook_secret, :created_at, :updated_at)`
 	if ch.ID == "" {
 		ch.ID = uuid.New().String()
 	}
 	now := time.Now().UTC()
+	ch.TenantID = tenantID
 	ch.CreatedAt = now
 	ch.UpdatedAt = now
-	_, err := r.db.ExecContext(ctx, query, ch.ID, ch.TenantID, ch.Name, ch.Slug, ch.Description, ch.CreatedAt, ch.UpdatedAt)
+	_, err := scoped(r.db, tenantID).exec(ctx, query, map[string]interface{}{
+		"id":             ch.ID,
+		"name":           ch.Name,
+		"slug":           ch.Slug,
+		"description":    ch.Description,
+		"outbound_url":   ch.OutboundURL,
+		"webhook_secret": ch.WebhookSecret,
+		"created_at":     ch.CreatedAt,
+		"updated_at":     ch.UpdatedAt,
+	})
 	return err
 }
 
-func (r *ChannelRepository) GetByID(ctx context.Context, id string) (*model.Channel, error) {
-	query := `SELECT id, tenant_id, name, slug, description, created_at, updated_at FROM channels WHERE id=$1`
-	query = r.db.Rebind(query)
+func (r *ChannelRepository) GetByID(ctx context.Context, id, tenantID string) (*model.Channel, error) {
+	query := `SELECT id, tenant_id, name, slug, COALESCE(description, '') as description, outbound_url, created_at, updated_at FROM channels WHERE id = :id AND tenant_id = :tenant_id`
+	var ch model.Channel
+	if err := scoped(r.db, tenantID).get(ctx, &ch, query, map[string]interface{}{"id": id}); err != nil {
+		return nil, err
+	}
+	return &ch, nil
+}
+
+// GetBySlug looks up the channel a conversation was received on
+func (r *ChannelRepository) GetBySlug(ctx context.Context, slug, tenantID string) (*model.Channel, error) {
+	query := `SELECT id, tenant_id, name, slug, COALESCE(description, '') as description, outbound_url, created_at, updated_at FROM channels WHERE slug = :slug AND tenant_id = :tenant_id`
 	var ch model.Channel
-	if err := r.db.GetContext(ctx, &ch, query, id); err != nil {
+	if err := scoped(r.db, tenantID).get(ctx, &ch, query, map[string]interface{}{"slug": slug}); err != nil {
 		return nil, err
 	}
 	return &ch, nil
 }
 
-func (r *ChannelRepository) List(ctx context.Context) ([]model.Channel, error) {
-	query := `SELECT id, tenant_id, name, slug, description, created_at, updated_at FROM channels ORDER BY created_at DESC`
+// GetBySlugWithSecret resolves the channel of a signed inbound webhook, including its secret.
+// It is unscoped: the channel is what determines the tenant of a webhook.
+func (r *ChannelRepository) GetBySlugWithSecret(ctx context.Context, slug string) (*model.Channel, error) {
+	query := `SELECT id, tenant_id, name, slug, COALESCE(description, '') as description, outbound_url, webhook_secret, created_at, updated_at FROM channels WHERE slug=$1`
 	query = r.db.Rebind(query)
-	var out []model.Channel
-	if err := r.db.SelectContext(ctx, &out, query); err != nil {
+	var ch model.Channel
+	if err := r.db.GetContext(ctx, &ch, query, slug); err != nil {
+		return nil, err
+	}
+	return &ch, nil
+}
+
+func (r *ChannelRepository) UpdateSecret(ctx context.Context, id, tenantID, secret string) error {
+	query := `UPDATE channels SET webhook_secret = :secret, updated_at = :updated_at WHERE id = :id AND tenant_id = :tenant_id`
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{"id": id, "secret": secret, "updated_at": time.Now().UTC()})
+}
+
+func (r *ChannelRepository) List(ctx context.Context, tenantID string) ([]model.Channel, error) {
+	query := `SELECT id, tenant_id, name, slug, COALESCE(description, '') as description, outbound_url, created_at, updated_at FROM channels WHERE tenant_id = :tenant_id ORDER BY created_at DESC`
+	out := []model.Channel{}
+	if err := scoped(r.db, tenantID).selectAll(ctx, &out, query, nil); err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (r *ChannelRepository) Update(ctx context.Context, ch *model.Channel) error {
-	query := `UPDATE channels SET name=$1, slug=$2, description=$3, updated_at=$4 WHERE id=$5`
-	query = r.db.Rebind(query)
+// Update saves name, slug, description and outbound URL of a channel of the tenant
+func (r *ChannelRepository) Update(ctx context.Context, tenantID string, ch *model.Channel) error {
+	query := `UPDATE channels SET name = :name, slug = :slug, description = :description, outbound_url = :outbound_url, updated_at = :updated_at
+			  WHERE id = :id AND tenant_id = :tenant_id`
 	ch.UpdatedAt = time.Now().UTC()
-	_, err := r.db.ExecContext(ctx, query, ch.Name, ch.Slug, ch.Description, ch.UpdatedAt, ch.ID)
-	return err
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{
+		"id":           ch.ID,
+		"name":         ch.Name,
+		"slug":         ch.Slug,
+		"description":  ch.Description,
+		"outbound_url": ch.OutboundURL,
+		"updated_at":   ch.UpdatedAt,
+	})
 }
 
-func (r *ChannelRepository) Delete(ctx context.Context, id string) error {
-	query := `DELETE FROM channels WHERE id=$1`
-	query = r.db.Rebind(query)
-	_, err := r.db.ExecContext(ctx, query, id)
-	return err
+func (r *ChannelRepository) Delete(ctx context.Context, id, tenantID string) error {
+	query := `DELETE FROM channels WHERE id = :id AND tenant_id = :tenant_id`
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{"id": id})
 }
@@ -11,24 +11,35 @@ import (
 )
 
 type ConversationRepository struct {
-	db *sqlx.DB
+	db DBTX
 }
 
 func NewConversationRepository(db *sqlx.DB) *ConversationRepository {
 	return &ConversationRepository{db: db}
 }
 
+// Create inserts a conversation of conv.TenantID with a customer of the same tenant
 func (r *ConversationRepository) Create(ctx context.Context, conv *model.Conversation) error {
 	conv.ID = uuid.New().String()
 	conv.Status = "open"
+	conv.SLAStatus = "none"
+	conv.FirstResponseSLAStatus = "none"
+	conv.ResolutionSLAStatus = "none"
 	conv.CreatedAt = time.Now()
 	conv.UpdatedAt = time.Now()
 
 	query := `INSERT INTO conversations (id, tenant_id, customer_id, status, channel, created_at, updated_at)
-			  VALUES (:id, :tenant_id, :customer_id, :status, :channel, :created_at, :updated_at)`
+			  SELECT :id, :tenant_id, :customer_id, :status, :channel, :created_at, :updated_at
+			  WHERE EXISTS(SELECT 1 FROM customers cu WHERE cu.id = :customer_id AND cu.tenant_id = :tenant_id)`
 
-	_, err := r.db.NamedExecContext(ctx, query, conv)
-	return err
+	return scoped(r.db, conv.TenantID).execOne(ctx, query, map[string]interface{}{
+		"id":          conv.ID,
+		"customer_id": conv.CustomerID,
+		"status":      conv.Status,
+		"channel":     conv.Channel,
+		"created_at":  conv.CreatedAt,
+		"updated_at":  conv.UpdatedAt,
+	})
 }
 
 func (r *ConversationRepository) GetByID(ctx context.Context, id, tenantID string) (*model.Conversation, error) {
@@ -38,40 +49,59 @@ func (r *ConversationRepository) GetByID(ctx context.Context, id, tenantID strin
 			   cu.name as customer_name, 
 			   cu.external_id as customer_external_id,
 			   COALESCE(u.name, '') as assigned_agent_name,
-               COALESCE((SELECT message FROM messages WHERE conversation_id = c.id ORDER BY created_at DESC LIMIT 1), '') as last_message,
-               EXISTS(SELECT 1 FROM conversation_tickets ct WHERE ct.conversation_id = c.id) as has_ticket,
+               COALESCE((SELECT message FROM messages WHERE conversation_id = c.id AND deleted_at IS NULL ORDER BY created_at DESC LIMIT 1), '') as last_message,
+               EXISTS(SELECT 1 FROM conversation_tickets ct JOIN tickets t ON t.id = ct.ticket_id WHERE ct.conversation_id = c.id AND t.deleted_at IS NULL) as has_ticket,
 			   c.selected_ticket_id as selected_ticket_id
 		FROM conversations c
-		LEFT JOIN customers cu ON c.customer_id = cu.id
-		LEFT JOIN users u ON c.assigned_agent_id = u.id
-		WHERE c.id = ? AND c.tenant_id = ?`
+		LEFT JOIN customers cu ON c.customer_id = cu.id AND cu.tenant_id = c.tenant_id
+		LEFT JOIN users u ON c.assigned_agent_id = u.id AND u.tenant_id = c.tenant_id
+		WHERE c.id = :id AND c.tenant_id = :tenant_id AND c.deleted_at IS NULL`
 
-	query = r.db.Rebind(query)
-	err := r.db.GetContext(ctx, &conv, query, id, tenantID)
+	err := scoped(r.db, tenantID).get(ctx, &conv, query, map[string]interface{}{"id": id})
 	if err != nil {
 		return nil, err
 	}
 	return &conv, nil
 }
 
-func (r *ConversationRepository) SetSelectedTicket(ctx context.Context, id, ticketID string) error {
-	query := `UPDATE conversations SET selected_ticket_id = ?, updated_at = ? WHERE id = ?`
-	query = r.db.Rebind(query)
-	_, err := r.db.ExecContext(ctx, query, ticketID, time.Now(), id)
-	return err
+// SetSelectedTicket selects a ticket of the same tenant on the conversation
+func (r *ConversationRepository) SetSelectedTicket(ctx context.Context, id, tenantID, ticketID string) error {
+	query := `UPDATE conversations SET selected_ticket_id = :ticket_id, updated_at = :updated_at
+			  WHERE id = :id AND tenant_id = :tenant_id AND deleted_at IS NULL
+			    AND EXISTS(SELECT 1 FROM tickets t WHERE t.id = :ticket_id AND t.tenant_id = :tenant_id AND t.deleted_at IS NULL)`
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{"id": id, "ticket_id": ticketID, "updated_at": time.Now()})
 }
 
 func (r *ConversationRepository) GetByCustomerAndTenant(ctx context.Context, customerID, tenantID string) (*model.Conversation, error) {
 	var conv model.Conversation
-	query := `SELECT * FROM conversations WHERE customer_id = ? AND tenant_id = ? AND status != 'closed' ORDER BY created_at DESC LIMIT 1`
-	query = r.db.Rebind(query)
-	err := r.db.GetContext(ctx, &conv, query, customerID, tenantID)
+	query := `SELECT * FROM conversations WHERE customer_id = :customer_id AND tenant_id = :tenant_id AND status != 'closed' AND deleted_at IS NULL ORDER BY created_at DESC LIMIT 1`
+	err := scoped(r.db, tenantID).get(ctx, &conv, query, map[string]interface{}{"customer_id": customerID})
 	if err != nil {
 		return nil, err
 	}
 	return &conv, nil
 }
 
+// conversationSorts are the sort keys accepted by the conversation list
+var conversationSorts = map[string]string{
+	"updated_at":      "c.updated_at",
+	"created_at":      "c.created_at",
+	"last_message_at": "COALESCE(c.last_message_at, c.created_at)",
+}
+
+const conversationListSelect = `
+		SELECT c.*, 
+			   cu.name as customer_name, 
+			   cu.external_id as customer_external_id,
+			   COALESCE(u.name, '') as assigned_agent_name,
+               COALESCE((SELECT message FROM messages WHERE conversation_id = c.id AND deleted_at IS NULL ORDER BY created_at DESC LIMIT 1), '') as last_message,
+               EXISTS(SELECT 1 FROM conversation_tickets ct JOIN tickets t ON t.id = ct.ticket_id WHERE ct.conversation_id = c.id AND t.deleted_at IS NULL) as has_ticket,
+               (SELECT COUNT(*) FROM messages m WHERE m.conversation_id = c.id AND m.sender_type = 'customer' AND m.deleted_at IS NULL
+                  AND m.created_at > COALESCE((SELECT cr.last_read_at FROM conversation_reads cr
+                      WHERE cr.conversation_id = c.id AND cr.user_id = ?), '-infinity')) as unread_count
+		`
+
+// List returns one offset page of conversations and the total count
 func (r *ConversationRepository) List(ctx context.Context, tenantID string, filter model.ConversationFilter) ([]model.Conversation, int, error) {
 	var conversations []model.Conversation
 	var total int
@@ -85,42 +115,23 @@ func (r *ConversationRepository) List(ctx context.Context, tenantID string, filt
 	}
 	offset := (filter.Page - 1) * filter.PerPage
 
-	// Build query
-	baseQuery := `
-		FROM conversations c
-		LEFT JOIN customers cu ON c.customer_id = cu.id
-		LEFT JOIN users u ON c.assigned_agent_id = u.id
-		WHERE c.tenant_id = ?`
-
-	args := []interface{}{tenantID}
-
-	if filter.Status != "" {
-		baseQuery += ` AND c.status = ?`
-		args = append(args, filter.Status)
-	}
-
-	if filter.AssignedAgentID != "" {
-		baseQuery += ` AND c.assigned_agent_id = ?`
-		args = append(args, filter.AssignedAgentID)
+	sort, err := resolveSort(filter.Sort, "-updated_at", conversationSorts)
+	if err != nil {
+		return nil, 0, err
 	}
+	baseQuery, args := conversationListWhere(tenantID, filter)
 
 	// Count total
 	countQuery := `SELECT COUNT(*) ` + baseQuery
 	countQuery = r.db.Rebind(countQuery)
-	err := r.db.GetContext(ctx, &total, countQuery, args...)
+	err = r.db.GetContext(ctx, &total, countQuery, args...)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// Get data
-	selectQuery := `
-		SELECT c.*, 
-			   cu.name as customer_name, 
-			   cu.external_id as customer_external_id,
-			   COALESCE(u.name, '') as assigned_agent_name,
-               COALESCE((SELECT message FROM messages WHERE conversation_id = c.id ORDER BY created_at DESC LIMIT 1), '') as last_message,
-               EXISTS(SELECT 1 FROM conversation_tickets ct WHERE ct.conversation_id = c.id) as has_ticket
-		` + baseQuery + ` ORDER BY c.updated_at DESC LIMIT ? OFFSET ?`
+	selectQuery := conversationListSelect + baseQuery + sort.orderBy("c.id") + ` LIMIT ? OFFSET ?`
+	args = append([]interface{}{filter.ViewerID}, args...)
 	args = append(args, filter.PerPage, offset)
 	selectQuery = r.db.Rebind(selectQuery)
 	err = r.db.SelectContext(ctx, &conversations, selectQuery, args...)
@@ -128,38 +139,193 @@ func (r *ConversationRepository) List(ctx context.Context, tenantID string, filt
 	return conversations, total, err
 }
 
-func (r *ConversationRepository) UpdateStatus(ctx context.Context, id, status string) error {
-	query := `UPDATE conversations SET status = ?, updated_at = ? WHERE id = ?`
-	query = r.db.Rebind(query)
-	_, err := r.db.ExecContext(ctx, query, status, time.Now(), id)
-	return err
+// ListAfter returns the keyset page following filter.Cursor (the first page when empty) and
+// the cursor of the next page, which is empty on the last page
+func (r *ConversationRepository) ListAfter(ctx context.Context, tenantID string, filter model.ConversationFilter) ([]model.Conversation, string, error) {
+	conversations := []model.Conversation{}
+
+	sort, err := resolveSort(filter.Sort, "-updated_at", conversationSorts)
+	if err != nil {
+		return nil, "", err
+	}
+	baseQuery, args := conversationListWhere(tenantID, filter)
+	baseQuery, args, err = sort.after(baseQuery, args, "c.id", filter.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	selectQuery := conversationListSelect + baseQuery + sort.orderBy("c.id") + ` LIMIT ?`
+	args = append([]interface{}{filter.ViewerID}, args...)
+	args = append(args, filter.PerPage+1)
+	selectQuery = r.db.Rebind(selectQuery)
+	if err := r.db.SelectContext(ctx, &conversations, selectQuery, args...); err != nil {
+		return nil, "", err
+	}
+
+	if len(conversations) <= filter.PerPage {
+		return conversations, "", nil
+	}
+	conversations = conversations[:filter.PerPage]
+	last := conversations[len(conversations)-1]
+	next := listCursor{Sort: sort.key, ID: last.ID}
+	switch sort.key {
+	case "created_at":
+		next.At = last.CreatedAt
+	case "last_message_at":
+		next.At = last.CreatedAt
+		if last.LastMessageAt.Valid {
+			next.At = last.LastMessageAt.Time
+		}
+	default:
+		next.At = last.UpdatedAt
+	}
+	return conversations, encodeCursor(next), nil
 }
 
-func (r *ConversationRepository) Assign(ctx context.Context, id, agentID string) error {
-	query := `UPDATE conversations SET assigned_agent_id = ?, status = 'assigned', updated_at = ? WHERE id = ?`
-	query = r.db.Rebind(query)
-	_, err := r.db.ExecContext(ctx, query, agentID, time.Now(), id)
-	return err
+// conversationListWhere builds the FROM/WHERE clause shared by List and ListAfter
+func conversationListWhere(tenantID string, filter model.ConversationFilter) (string, []interface{}) {
+	baseQuery := `
+		FROM conversations c
+		LEFT JOIN customers cu ON c.customer_id = cu.id AND cu.tenant_id = c.tenant_id
+		LEFT JOIN users u ON c.assigned_agent_id = u.id AND u.tenant_id = c.tenant_id
+		WHERE c.tenant_id = ? AND c.deleted_at IS NULL`
+
+	args := []interface{}{tenantID}
+
+	baseQuery, args = addInFilter(baseQuery, args, "c.status", filter.Status)
+	baseQuery, args = addInFilter(baseQuery, args, "c.sla_status", filter.SLAStatus)
+	baseQuery, args = addInFilter(baseQuery, args, "c.channel", filter.Channel)
+
+	if filter.Unassigned {
+		baseQuery += ` AND c.assigned_agent_id IS NULL`
+	} else if filter.AssignedAgentID != "" {
+		baseQuery += ` AND c.assigned_agent_id = ?`
+		args = append(args, filter.AssignedAgentID)
+	}
+
+	if filter.CustomerID != "" {
+		baseQuery += ` AND c.customer_id = ?`
+		args = append(args, filter.CustomerID)
+	}
+
+	if filter.HasTicket != nil {
+		cond := `EXISTS(SELECT 1 FROM conversation_tickets ct JOIN tickets t ON t.id = ct.ticket_id WHERE ct.conversation_id = c.id AND t.deleted_at IS NULL)`
+		if !*filter.HasTicket {
+			cond = `NOT ` + cond
+		}
+		baseQuery += ` AND ` + cond
+	}
+
+	// Free text matches the customer or any message, using the search indexes
+	if filter.Query != "" {
+		baseQuery += ` AND (to_tsvector('simple', cu.name || ' ' || cu.external_id) @@ websearch_to_tsquery('simple', ?)
+			OR EXISTS(SELECT 1 FROM messages m WHERE m.conversation_id = c.id AND m.deleted_at IS NULL AND to_tsvector('simple', m.message) @@ websearch_to_tsquery('simple', ?)))`
+		args = append(args, filter.Query, filter.Query)
+	}
+
+	return addDateFilters(baseQuery, args, "c", filter.ListParams)
+}
+
+func (r *ConversationRepository) UpdateStatus(ctx context.Context, id, tenantID, status string) error {
+	query := `UPDATE conversations SET status = :status, updated_at = :updated_at WHERE id = :id AND tenant_id = :tenant_id AND deleted_at IS NULL`
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{"id": id, "status": status, "updated_at": time.Now()})
+}
+
+// Assign gives the conversation to an agent of the same tenant
+func (r *ConversationRepository) Assign(ctx context.Context, id, tenantID, agentID string) error {
+	query := `UPDATE conversations SET assigned_agent_id = :agent_id, status = 'assigned', updated_at = :updated_at
+			  WHERE id = :id AND tenant_id = :tenant_id AND deleted_at IS NULL
+			    AND EXISTS(SELECT 1 FROM users u WHERE u.id = :agent_id AND u.tenant_id = :tenant_id)`
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{"id": id, "agent_id": agentID, "updated_at": time.Now()})
+}
+
+// AssignUnassigned is Assign for auto-assignment: it returns sql.ErrNoRows instead of taking the
+// conversation away from an agent who claimed it in the meantime
+func (r *ConversationRepository) AssignUnassigned(ctx context.Context, id, tenantID, agentID string) error {
+	query := `UPDATE conversations SET assigned_agent_id = :agent_id, status = 'assigned', updated_at = :updated_at
+			  WHERE id = :id AND tenant_id = :tenant_id AND deleted_at IS NULL AND assigned_agent_id IS NULL
+			    AND EXISTS(SELECT 1 FROM users u WHERE u.id = :agent_id AND u.tenant_id = :tenant_id)`
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{"id": id, "agent_id": agentID, "updated_at": time.Now()})
 }
 
-func (r *ConversationRepository) UpdateLastMessage(ctx context.Context, id string) error {
-	query := `UPDATE conversations SET last_message_at = ?, updated_at = ? WHERE id = ?`
-	query = r.db.Rebind(query)
-	_, err := r.db.ExecContext(ctx, query, time.Now(), time.Now(), id)
-	return err
+func (r *ConversationRepository) UpdateLastMessage(ctx context.Context, id, tenantID string) error {
+	query := `UPDATE conversations SET last_message_at = :now, updated_at = :now WHERE id = :id AND tenant_id = :tenant_id`
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{"id": id, "now": time.Now()})
 }
 
-func (r *ConversationRepository) HasTicket(ctx context.Context, id string) (bool, error) {
+func (r *ConversationRepository) HasTicket(ctx context.Context, id, tenantID string) (bool, error) {
 	var count int
-	query := `SELECT COUNT(*) FROM conversation_tickets WHERE conversation_id = ?`
-	query = r.db.Rebind(query)
-	err := r.db.GetContext(ctx, &count, query, id)
+	query := `SELECT COUNT(*) FROM conversation_tickets ct JOIN tickets t ON t.id = ct.ticket_id
+			  WHERE ct.conversation_id = :id AND t.tenant_id = :tenant_id AND t.deleted_at IS NULL`
+	err := scoped(r.db, tenantID).get(ctx, &count, query, map[string]interface{}{"id": id})
 	return count > 0, err
 }
 
-func (r *ConversationRepository) Delete(ctx context.Context, id, tenantID string) error {
-	query := `DELETE FROM conversations WHERE id = ? AND tenant_id = ?`
-	query = r.db.Rebind(query)
-	_, err := r.db.ExecContext(ctx, query, id, tenantID)
-	return err
+// Delete soft-deletes a conversation; its messages and ticket links are kept for Restore
+func (r *ConversationRepository) Delete(ctx context.Context, id, tenantID, userID string) error {
+	query := `UPDATE conversations SET deleted_at = :deleted_at, deleted_by = :deleted_by WHERE id = :id AND tenant_id = :tenant_id AND deleted_at IS NULL`
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{"id": id, "deleted_at": time.Now(), "deleted_by": userID})
+}
+
+// GetDeleted returns a soft-deleted conversation of the tenant
+func (r *ConversationRepository) GetDeleted(ctx context.Context, id, tenantID string) (*model.Conversation, error) {
+	var conv model.Conversation
+	query := `SELECT * FROM conversations WHERE id = :id AND tenant_id = :tenant_id AND deleted_at IS NOT NULL`
+	err := scoped(r.db, tenantID).get(ctx, &conv, query, map[string]interface{}{"id": id})
+	if err != nil {
+		return nil, err
+	}
+	return &conv, nil
+}
+
+func (r *ConversationRepository) Restore(ctx context.Context, id, tenantID string) error {
+	query := `UPDATE conversations SET deleted_at = NULL, deleted_by = NULL, updated_at = :updated_at WHERE id = :id AND tenant_id = :tenant_id AND deleted_at IS NOT NULL`
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{"id": id, "updated_at": time.Now()})
+}
+
+// PurgeDeleted permanently removes conversations soft-deleted before cutoff, with their messages
+// and read markers. Tickets escalated from them survive with their conversation_id cleared.
+// Retention runs across tenants, so the query is not tenant-scoped.
+func (r *ConversationRepository) PurgeDeleted(ctx context.Context, cutoff time.Time) (int64, error) {
+	detach := `UPDATE tickets SET conversation_id = NULL
+			   WHERE conversation_id IN (SELECT id FROM conversations WHERE deleted_at < ?)`
+	if _, err := r.db.ExecContext(ctx, r.db.Rebind(detach), cutoff); err != nil {
+		return 0, err
+	}
+	res, err := r.db.ExecContext(ctx, r.db.Rebind(`DELETE FROM conversations WHERE deleted_at < ?`), cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// MarkRead upserts the user's read marker; it never moves backwards. advanced reports whether
+// the marker changed.
+func (r *ConversationRepository) MarkRead(ctx context.Context, tenantID string, read *model.ConversationRead) (bool, error) {
+	query := `INSERT INTO conversation_reads (conversation_id, user_id, tenant_id, last_read_message_id, last_read_at, updated_at)
+			  SELECT :conversation_id, :user_id, :tenant_id, :message_id, :read_at, :updated_at
+			  WHERE EXISTS(SELECT 1 FROM conversations c WHERE c.id = :conversation_id AND c.tenant_id = :tenant_id)
+			  ON CONFLICT (conversation_id, user_id) DO UPDATE
+			  SET last_read_message_id = EXCLUDED.last_read_message_id, last_read_at = EXCLUDED.last_read_at, updated_at = EXCLUDED.updated_at
+			  WHERE conversation_reads.last_read_at < EXCLUDED.last_read_at`
+	n, err := scoped(r.db, tenantID).exec(ctx, query, map[string]interface{}{
+		"conversation_id": read.ConversationID,
+		"user_id":         read.UserID,
+		"message_id":      read.LastReadMessageID,
+		"read_at":         read.LastReadAt,
+		"updated_at":      time.Now(),
+	})
+	return n > 0, err
+}
+
+// ListReads returns the read markers of a conversation, most recent first
+func (r *ConversationRepository) ListReads(ctx context.Context, conversationID, tenantID string) ([]model.ConversationRead, error) {
+	reads := []model.ConversationRead{}
+	query := `SELECT cr.conversation_id, cr.user_id, COALESCE(u.name, '') as user_name, cr.last_read_message_id, cr.last_read_at
+			  FROM conversation_reads cr
+			  LEFT JOIN users u ON u.id = cr.user_id AND u.tenant_id = cr.tenant_id
+			  WHERE cr.conversation_id = :conversation_id AND cr.tenant_id = :tenant_id
+			  ORDER BY cr.last_read_at DESC`
+	err := scoped(r.db, tenantID).selectAll(ctx, &reads, query, map[string]interface{}{"conversation_id": conversationID})
+	return reads, err
 }
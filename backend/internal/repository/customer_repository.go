@@ -11,7 +11,7 @@ import (
 )
 
 type CustomerRepository struct {
-	db *sqlx.DB
+	db DBTX
 }
 
 func NewCustomerRepository(db *sqlx.DB) *CustomerRepository {
@@ -26,15 +26,21 @@ func (r *CustomerRepository) Create(ctx context.Context, customer *model.Custome
 	query := `INSERT INTO customers (id, tenant_id, external_id, name, channel, created_at, updated_at)
 			  VALUES (:id, :tenant_id, :external_id, :name, :channel, :created_at, :updated_at)`
 
-	_, err := r.db.NamedExecContext(ctx, query, customer)
+	_, err := scoped(r.db, customer.TenantID).exec(ctx, query, map[string]interface{}{
+		"id":          customer.ID,
+		"external_id": customer.ExternalID,
+		"name":        customer.Name,
+		"channel":     customer.Channel,
+		"created_at":  customer.CreatedAt,
+		"updated_at":  customer.UpdatedAt,
+	})
 	return err
 }
 
 func (r *CustomerRepository) GetByExternalID(ctx context.Context, externalID, tenantID string) (*model.Customer, error) {
 	var customer model.Customer
-	query := `SELECT * FROM customers WHERE external_id = ? AND tenant_id = ?`
-	query = r.db.Rebind(query)
-	err := r.db.GetContext(ctx, &customer, query, externalID, tenantID)
+	query := `SELECT * FROM customers WHERE external_id = :external_id AND tenant_id = :tenant_id`
+	err := scoped(r.db, tenantID).get(ctx, &customer, query, map[string]interface{}{"external_id": externalID})
 	if err != nil {
 		return nil, err
 	}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"time"
 
@@ -12,7 +13,7 @@ import (
 )
 
 type EventRepository struct {
-	db *sqlx.DB
+	db DBTX
 }
 
 func NewEventRepository(db *sqlx.DB) *EventRepository {
@@ -23,8 +24,8 @@ func (r *EventRepository) Create(ctx context.Context, event *model.Event) error
 	event.ID = uuid.New().String()
 	event.CreatedAt = time.Now()
 
-	query := `INSERT INTO events (id, tenant_id, event_type, entity_type, entity_id, data, user_id, created_at)
-			  VALUES (:id, :tenant_id, :event_type, :entity_type, :entity_id, :data, :user_id, :created_at)`
+	query := `INSERT INTO events (id, tenant_id, event_type, entity_type, entity_id, data, user_id, before_data, after_data, created_at)
+			  VALUES (:id, :tenant_id, :event_type, :entity_type, :entity_id, :data, :user_id, :before_data, :after_data, :created_at)`
 
 	_, err := r.db.NamedExecContext(ctx, query, event)
 	return err
@@ -48,10 +49,86 @@ func (r *EventRepository) LogEvent(ctx context.Context, tenantID, eventType, ent
 	return r.Create(ctx, event)
 }
 
-func (r *EventRepository) GetByEntityID(ctx context.Context, entityType, entityID string) ([]model.Event, error) {
+// LogChange records an event like LogEvent together with snapshots of the entity before and
+// after the change for the audit log; pass nil for before on creation and for after on deletion
+func (r *EventRepository) LogChange(ctx context.Context, tenantID, eventType, entityType, entityID, userID string, data, before, after interface{}) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		jsonData = []byte("{}")
+	}
+
+	event := &model.Event{
+		TenantID:   tenantID,
+		EventType:  eventType,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Data:       string(jsonData),
+		UserID:     userID,
+		BeforeData: snapshot(before),
+		AfterData:  snapshot(after),
+	}
+
+	return r.Create(ctx, event)
+}
+
+// snapshot encodes v as JSON; nil, typed nil pointers and unencodable values are NULL
+func snapshot(v interface{}) sql.NullString {
+	if v == nil {
+		return sql.NullString{}
+	}
+	b, err := json.Marshal(v)
+	if err != nil || string(b) == "null" {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: string(b), Valid: true}
+}
+
+// GetByEntityID returns the tenant's events of one entity, newest first
+func (r *EventRepository) GetByEntityID(ctx context.Context, tenantID, entityType, entityID string) ([]model.Event, error) {
 	var events []model.Event
-	query := `SELECT * FROM events WHERE entity_type = ? AND entity_id = ? ORDER BY created_at DESC`
-	query = r.db.Rebind(query)
-	err := r.db.SelectContext(ctx, &events, query, entityType, entityID)
+	query := `SELECT * FROM events WHERE tenant_id = :tenant_id AND entity_type = :entity_type AND entity_id = :entity_id ORDER BY created_at DESC`
+	err := scoped(r.db, tenantID).selectAll(ctx, &events, query, map[string]interface{}{"entity_type": entityType, "entity_id": entityID})
 	return events, err
 }
+
+// purgedEntities selects the conversations, messages and tickets soft-deleted before the cutoff,
+// including the messages of those conversations, as (tenant_id, entity_type, id, conversation_id);
+// it takes the cutoff four times
+const purgedEntities = `SELECT tenant_id, 'message' AS entity_type, id, conversation_id FROM messages WHERE deleted_at < ?
+	UNION ALL SELECT tenant_id, 'ticket', id, NULL FROM tickets WHERE deleted_at < ?
+	UNION ALL SELECT tenant_id, 'conversation', id, NULL FROM conversations WHERE deleted_at < ?
+	UNION ALL SELECT m.tenant_id, 'message', m.id, m.conversation_id FROM messages m JOIN conversations c ON c.id = m.conversation_id WHERE c.deleted_at < ?`
+
+// deletionEvents record who deleted what; they outlive the purge with their snapshots cleared
+const deletionEvents = `('conversation.deleted', 'message.deleted', 'ticket.deleted')`
+
+// PurgeDeleted removes the events of rows that RetentionService is about to purge: events
+// recorded on them and the message.received / message.sent events of their conversation that
+// hold a snapshot of a purged message. Deletion events are kept for the audit trail, with their
+// before/after snapshots cleared. Rows are matched on tenant, entity type and entity id, the
+// prefix of idx_events_entity. Must run in the purge transaction before the rows themselves
+// are deleted; returns the number of events removed.
+func (r *EventRepository) PurgeDeleted(ctx context.Context, cutoff time.Time) (int64, error) {
+	query := `WITH purged AS (` + purgedEntities + `),
+			  targets AS (
+			    SELECT e.id FROM events e
+			    JOIN purged p ON e.tenant_id = p.tenant_id AND e.entity_type = p.entity_type AND e.entity_id = p.id
+			    UNION ALL
+			    SELECT e.id FROM events e
+			    JOIN purged p ON e.tenant_id = p.tenant_id AND e.entity_type = 'conversation' AND e.entity_id = p.conversation_id
+			    WHERE p.entity_type = 'message' AND e.event_type IN ('message.received', 'message.sent') AND e.data->>'id' = p.id
+			  ),
+			  redacted AS (
+			    UPDATE events SET before_data = NULL, after_data = NULL
+			    WHERE id IN (SELECT id FROM targets) AND event_type IN ` + deletionEvents + `
+			    RETURNING id
+			  )
+			  DELETE FROM events
+			  WHERE id IN (SELECT id FROM targets) AND event_type NOT IN ` + deletionEvents
+	query = r.db.Rebind(query)
+	res, err := r.db.ExecContext(ctx, query, cutoff, cutoff, cutoff, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
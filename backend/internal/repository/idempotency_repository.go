@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type IdempotencyRepository struct {
+	db DBTX
+}
+
+func NewIdempotencyRepository(db *sqlx.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// Reserve claims a key before processing. It returns (nil, nil) when the caller now owns the
+// key, or the existing row when the key was already used. Reservations that never completed
+// are reclaimable after a minute so a crashed request does not block retries forever.
+func (r *IdempotencyRepository) Reserve(ctx context.Context, tenantID, scope, key string) (*model.IdempotencyKey, error) {
+	query := `INSERT INTO idempotency_keys (tenant_id, scope, key, created_at) VALUES (:tenant_id, :scope, :key, now())
+			  ON CONFLICT (tenant_id, scope, key) DO UPDATE SET created_at = EXCLUDED.created_at
+			  WHERE idempotency_keys.message_id IS NULL AND idempotency_keys.created_at < now() - interval '1 minute'
+			  RETURNING key`
+	s := scoped(r.db, tenantID)
+	args := map[string]interface{}{"scope": scope, "key": key}
+	var reserved string
+	err := s.get(ctx, &reserved, query, args)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	var existing model.IdempotencyKey
+	query = `SELECT * FROM idempotency_keys WHERE tenant_id = :tenant_id AND scope = :scope AND key = :key`
+	if err := s.get(ctx, &existing, query, args); err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// Complete stores the result of a reserved key
+func (r *IdempotencyRepository) Complete(ctx context.Context, tenantID, scope, key, conversationID, messageID string) error {
+	query := `UPDATE idempotency_keys SET conversation_id = :conversation_id, message_id = :message_id
+			  WHERE tenant_id = :tenant_id AND scope = :scope AND key = :key`
+	_, err := scoped(r.db, tenantID).exec(ctx, query, map[string]interface{}{
+		"scope": scope, "key": key, "conversation_id": conversationID, "message_id": messageID,
+	})
+	return err
+}
+
+// Release drops a reservation whose processing failed so the request can be retried
+func (r *IdempotencyRepository) Release(ctx context.Context, tenantID, scope, key string) error {
+	query := `DELETE FROM idempotency_keys WHERE tenant_id = :tenant_id AND scope = :scope AND key = :key AND message_id IS NULL`
+	_, err := scoped(r.db, tenantID).exec(ctx, query, map[string]interface{}{"scope": scope, "key": key})
+	return err
+}
+
+// PurgeExpired deletes keys created before cutoff; it is a retention job and runs across tenants
+func (r *IdempotencyRepository) PurgeExpired(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, r.db.Rebind(`DELETE FROM idempotency_keys WHERE created_at < ?`), cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type InviteRepository struct {
+	db DBTX
+}
+
+func NewInviteRepository(db *sqlx.DB) *InviteRepository {
+	return &InviteRepository{db: db}
+}
+
+func (r *InviteRepository) Create(ctx context.Context, inv *model.Invite) error {
+	inv.ID = uuid.New().String()
+	inv.CreatedAt = time.Now()
+
+	query := `INSERT INTO invites (id, tenant_id, email, role, token_hash, invited_by, expires_at, created_at)
+			  VALUES (:id, :tenant_id, :email, :role, :token_hash, :invited_by, :expires_at, :created_at)`
+
+	_, err := scoped(r.db, inv.TenantID).exec(ctx, query, map[string]interface{}{
+		"id":         inv.ID,
+		"email":      inv.Email,
+		"role":       inv.Role,
+		"token_hash": inv.TokenHash,
+		"invited_by": inv.InvitedBy,
+		"expires_at": inv.ExpiresAt,
+		"created_at": inv.CreatedAt,
+	})
+	return err
+}
+
+// GetByTokenHash finds the invite being accepted; it is unscoped because the invite determines
+// the tenant
+func (r *InviteRepository) GetByTokenHash(ctx context.Context, hash string) (*model.Invite, error) {
+	var inv model.Invite
+	query := `SELECT * FROM invites WHERE token_hash = ?`
+	query = r.db.Rebind(query)
+	err := r.db.GetContext(ctx, &inv, query, hash)
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// ListPending returns the tenant's invites that are neither accepted nor expired
+func (r *InviteRepository) ListPending(ctx context.Context, tenantID string) ([]model.Invite, error) {
+	invites := []model.Invite{}
+	query := `SELECT * FROM invites WHERE tenant_id = :tenant_id AND accepted_at IS NULL AND expires_at > now() ORDER BY created_at DESC`
+	err := scoped(r.db, tenantID).selectAll(ctx, &invites, query, nil)
+	return invites, err
+}
+
+// DeletePending removes unaccepted invites of the tenant for email, so a new invite replaces them
+func (r *InviteRepository) DeletePending(ctx context.Context, tenantID, email string) error {
+	query := `DELETE FROM invites WHERE tenant_id = :tenant_id AND email = :email AND accepted_at IS NULL`
+	_, err := scoped(r.db, tenantID).exec(ctx, query, map[string]interface{}{"email": email})
+	return err
+}
+
+// Delete revokes an unaccepted invite; it reports whether one was removed
+func (r *InviteRepository) Delete(ctx context.Context, id, tenantID string) (bool, error) {
+	query := `DELETE FROM invites WHERE id = :id AND tenant_id = :tenant_id AND accepted_at IS NULL`
+	n, err := scoped(r.db, tenantID).exec(ctx, query, map[string]interface{}{"id": id})
+	return n > 0, err
+}
+
+// MarkAccepted consumes a pending, unexpired invite; it reports false when the invite was
+// already used or has expired
+func (r *InviteRepository) MarkAccepted(ctx context.Context, id, tenantID string) (bool, error) {
+	query := `UPDATE invites SET accepted_at = now() WHERE id = :id AND tenant_id = :tenant_id AND accepted_at IS NULL AND expires_at > now()`
+	n, err := scoped(r.db, tenantID).exec(ctx, query, map[string]interface{}{"id": id})
+	return n > 0, err
+}
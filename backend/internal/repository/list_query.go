@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"backend/internal/model"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("invalid sort")
+)
+
+// listCursor is the keyset position after the last row of a page: the sort value and id
+type listCursor struct {
+	Sort string    `json:"s"`
+	At   time.Time `json:"t"`
+	ID   string    `json:"id"`
+}
+
+func encodeCursor(c listCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*listCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c listCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// listSort is a resolved sort option; key is the public name, expr the SQL expression
+type listSort struct {
+	key  string
+	expr string
+	desc bool
+}
+
+// resolveSort validates a sort such as "-updated_at" against the allowed columns; empty uses def
+func resolveSort(sort, def string, allowed map[string]string) (listSort, error) {
+	if sort == "" {
+		sort = def
+	}
+	desc := strings.HasPrefix(sort, "-")
+	key := strings.TrimPrefix(sort, "-")
+	expr, ok := allowed[key]
+	if !ok {
+		return listSort{}, ErrInvalidSort
+	}
+	return listSort{key: key, expr: expr, desc: desc}, nil
+}
+
+// orderBy orders by the sort expression with the id column as a unique tiebreaker
+func (s listSort) orderBy(idColumn string) string {
+	dir := " ASC"
+	if s.desc {
+		dir = " DESC"
+	}
+	return ` ORDER BY ` + s.expr + dir + `, ` + idColumn + dir
+}
+
+// after restricts the query to rows following the cursor in sort order
+func (s listSort) after(query string, args []interface{}, idColumn, cursor string) (string, []interface{}, error) {
+	if cursor == "" {
+		return query, args, nil
+	}
+	c, err := decodeCursor(cursor)
+	if err != nil || c.Sort != s.key {
+		return "", nil, ErrInvalidCursor
+	}
+	op := " > "
+	if s.desc {
+		op = " < "
+	}
+	query += ` AND (` + s.expr + `, ` + idColumn + `)` + op + `(?, ?)`
+	return query, append(args, c.At, c.ID), nil
+}
+
+// addInFilter adds "column IN (...)" for a comma separated value list
+func addInFilter(query string, args []interface{}, column, values string) (string, []interface{}) {
+	list := splitList(values)
+	if len(list) == 0 {
+		return query, args
+	}
+	query += ` AND ` + column + ` IN (?` + strings.Repeat(`, ?`, len(list)-1) + `)`
+	for _, v := range list {
+		args = append(args, v)
+	}
+	return query, args
+}
+
+// addDateFilters applies the created/updated ranges of p; bounds are inclusive
+func addDateFilters(query string, args []interface{}, alias string, p model.ListParams) (string, []interface{}) {
+	bounds := []struct {
+		value *time.Time
+		cond  string
+	}{
+		{p.CreatedFrom, alias + `.created_at >= ?`},
+		{p.CreatedTo, alias + `.created_at <= ?`},
+		{p.UpdatedFrom, alias + `.updated_at >= ?`},
+		{p.UpdatedTo, alias + `.updated_at <= ?`},
+	}
+	for _, b := range bounds {
+		if b.value != nil {
+			query += ` AND ` + b.cond
+			args = append(args, *b.value)
+		}
+	}
+	return query, args
+}
+
+func splitList(values string) []string {
+	var list []string
+	for _, v := range strings.Split(values, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
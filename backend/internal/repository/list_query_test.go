@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+var testSorts = map[string]string{
+	"created_at": "t.created_at",
+	"updated_at": "t.updated_at",
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC)
+	in := listCursor{Sort: "updated_at", At: at, ID: "t-1"}
+
+	out, err := decodeCursor(encodeCursor(in))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.Sort != in.Sort || out.ID != in.ID || !out.At.Equal(in.At) {
+		t.Errorf("decoded %+v, want %+v", *out, in)
+	}
+}
+
+func TestDecodeCursorRejectsMalformedInput(t *testing.T) {
+	cursors := []string{
+		"not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("not json")),
+		base64.RawURLEncoding.EncodeToString([]byte(`{"s":"created_at","t":"2024-05-01T00:00:00Z"}`)),
+		base64.RawURLEncoding.EncodeToString([]byte(`{"s":"created_at","t":"yesterday","id":"t-1"}`)),
+	}
+	for _, c := range cursors {
+		if _, err := decodeCursor(c); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("decodeCursor(%q) = %v, want ErrInvalidCursor", c, err)
+		}
+	}
+}
+
+func TestResolveSort(t *testing.T) {
+	cases := []struct {
+		sort    string
+		wantKey string
+		desc    bool
+		wantErr bool
+	}{
+		{sort: "", wantKey: "created_at", desc: true},
+		{sort: "updated_at", wantKey: "updated_at"},
+		{sort: "-updated_at", wantKey: "updated_at", desc: true},
+		{sort: "title", wantErr: true},
+		{sort: "-", wantErr: true},
+		{sort: "t.created_at", wantErr: true},
+	}
+	for _, tc := range cases {
+		s, err := resolveSort(tc.sort, "-created_at", testSorts)
+		if tc.wantErr {
+			if !errors.Is(err, ErrInvalidSort) {
+				t.Errorf("resolveSort(%q) = %v, want ErrInvalidSort", tc.sort, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("resolveSort(%q): %v", tc.sort, err)
+			continue
+		}
+		if s.key != tc.wantKey || s.desc != tc.desc || s.expr != testSorts[tc.wantKey] {
+			t.Errorf("resolveSort(%q) = %+v, want key %s desc %v", tc.sort, s, tc.wantKey, tc.desc)
+		}
+	}
+}
+
+func TestSortOrderByBreaksTiesOnID(t *testing.T) {
+	asc, _ := resolveSort("updated_at", "", testSorts)
+	if got, want := asc.orderBy("t.id"), ` ORDER BY t.updated_at ASC, t.id ASC`; got != want {
+		t.Errorf("orderBy = %q, want %q", got, want)
+	}
+	desc, _ := resolveSort("-updated_at", "", testSorts)
+	if got, want := desc.orderBy("t.id"), ` ORDER BY t.updated_at DESC, t.id DESC`; got != want {
+		t.Errorf("orderBy = %q, want %q", got, want)
+	}
+}
+
+func TestSortAfterCursor(t *testing.T) {
+	at := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	cursor := encodeCursor(listCursor{Sort: "updated_at", At: at, ID: "t-9"})
+	base := `SELECT * FROM tickets t WHERE t.tenant_id = ?`
+
+	desc, _ := resolveSort("-updated_at", "", testSorts)
+	q, args, err := desc.after(base, []interface{}{"tenant-a"}, "t.id", cursor)
+	if err != nil {
+		t.Fatalf("after: %v", err)
+	}
+	if want := base + ` AND (t.updated_at, t.id) < (?, ?)`; q != want {
+		t.Errorf("query = %q, want %q", q, want)
+	}
+	if len(args) != 3 || args[0] != "tenant-a" || !args[1].(time.Time).Equal(at) || args[2] != "t-9" {
+		t.Errorf("args = %v", args)
+	}
+
+	asc, _ := resolveSort("updated_at", "", testSorts)
+	if q, _, _ := asc.after(base, nil, "t.id", cursor); q != base+` AND (t.updated_at, t.id) > (?, ?)` {
+		t.Errorf("ascending query = %q", q)
+	}
+
+	if q, args, err := asc.after(base, nil, "t.id", ""); err != nil || q != base || len(args) != 0 {
+		t.Errorf("first page changed the query: %q %v %v", q, args, err)
+	}
+
+	// a cursor from a list sorted differently cannot be reused
+	created, _ := resolveSort("created_at", "", testSorts)
+	if _, _, err := created.after(base, nil, "t.id", cursor); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("cursor of another sort: got %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestAddInFilter(t *testing.T) {
+	q, args := addInFilter(`WHERE t.tenant_id = ?`, []interface{}{"tenant-a"}, "t.status", " open, ,pending ")
+	if want := `WHERE t.tenant_id = ? AND t.status IN (?, ?)`; q != want {
+		t.Errorf("query = %q, want %q", q, want)
+	}
+	if len(args) != 3 || args[1] != "open" || args[2] != "pending" {
+		t.Errorf("args = %v", args)
+	}
+	if q, args := addInFilter(`WHERE x`, nil, "t.status", " , "); q != `WHERE x` || len(args) != 0 {
+		t.Errorf("empty list changed the query: %q %v", q, args)
+	}
+}
@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"time"
 
 	"backend/internal/model"
@@ -11,35 +13,197 @@ import (
 )
 
 type MessageRepository struct {
-	db *sqlx.DB
+	db DBTX
 }
 
 func NewMessageRepository(db *sqlx.DB) *MessageRepository {
 	return &MessageRepository{db: db}
 }
 
-func (r *MessageRepository) Create(ctx context.Context, msg *model.Message) error {
+// Create inserts a message into a conversation of the tenant, copying the conversation's tenant
+// and channel; it returns sql.ErrNoRows when the conversation belongs to another tenant
+func (r *MessageRepository) Create(ctx context.Context, tenantID string, msg *model.Message) error {
 	msg.ID = uuid.New().String()
+	msg.TenantID = tenantID
 	msg.CreatedAt = time.Now()
 
-	query := `INSERT INTO messages (id, conversation_id, sender_type, sender_id, sender_name, message, created_at)
-			  VALUES (:id, :conversation_id, :sender_type, :sender_id, :sender_name, :message, :created_at)`
+	query := `INSERT INTO messages (id, tenant_id, channel, conversation_id, sender_type, sender_id, sender_name, message, delivery_status, external_message_id, created_at)
+			  SELECT :id, c.tenant_id, c.channel, :conversation_id, :sender_type, :sender_id, :sender_name, :message, :delivery_status, :external_message_id, :created_at
+			  FROM conversations c WHERE c.id = :conversation_id AND c.tenant_id = :tenant_id
+			  RETURNING channel`
 
-	_, err := r.db.NamedExecContext(ctx, query, msg)
-	return err
+	return scoped(r.db, tenantID).get(ctx, &msg.Channel, query, map[string]interface{}{
+		"id":                  msg.ID,
+		"conversation_id":     msg.ConversationID,
+		"sender_type":         msg.SenderType,
+		"sender_id":           msg.SenderID,
+		"sender_name":         msg.SenderName,
+		"message":             msg.Message,
+		"delivery_status":     msg.DeliveryStatus,
+		"external_message_id": msg.ExternalMessageID,
+		"created_at":          msg.CreatedAt,
+	})
+}
+
+// GetByID returns a message only if its conversation belongs to the tenant; deleted messages and
+// messages of deleted conversations are not found
+func (r *MessageRepository) GetByID(ctx context.Context, id, tenantID string) (*model.Message, error) {
+	var msg model.Message
+	query := `SELECT m.* FROM messages m JOIN conversations c ON c.id = m.conversation_id
+			  WHERE m.id = :id AND c.tenant_id = :tenant_id AND m.deleted_at IS NULL AND c.deleted_at IS NULL`
+	err := scoped(r.db, tenantID).get(ctx, &msg, query, map[string]interface{}{"id": id})
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// GetByExternalID returns the message stored for a provider message id of the tenant's channel,
+// deleted or not, since the id stays taken until the message is purged
+func (r *MessageRepository) GetByExternalID(ctx context.Context, tenantID, channel, externalID string) (*model.Message, error) {
+	var msg model.Message
+	query := `SELECT * FROM messages WHERE tenant_id = :tenant_id AND channel = :channel AND external_message_id = :external_message_id`
+	err := scoped(r.db, tenantID).get(ctx, &msg, query, map[string]interface{}{"channel": channel, "external_message_id": externalID})
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// GetForUpdate is GetByID that also locks the message row until the transaction ends, so
+// concurrent edits are applied one after the other. Must be called inside a transaction.
+func (r *MessageRepository) GetForUpdate(ctx context.Context, id, tenantID string) (*model.Message, error) {
+	var msg model.Message
+	query := `SELECT m.* FROM messages m JOIN conversations c ON c.id = m.conversation_id
+			  WHERE m.id = :id AND c.tenant_id = :tenant_id AND m.deleted_at IS NULL AND c.deleted_at IS NULL
+			  FOR UPDATE OF m`
+	err := scoped(r.db, tenantID).get(ctx, &msg, query, map[string]interface{}{"id": id})
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// ListPage returns up to q.Limit messages of a conversation of the tenant in chronological order:
+// the most recent ones, those before q.Before or those after q.After (message ids). more reports
+// whether further messages exist beyond the page in the direction being paged.
+func (r *MessageRepository) ListPage(ctx context.Context, conversationID, tenantID string, q model.MessagePageQuery) (messages []model.Message, more bool, err error) {
+	messages = []model.Message{}
+	scope := scoped(r.db, tenantID)
+	query := `SELECT m.* FROM messages m JOIN conversations c ON c.id = m.conversation_id
+			  WHERE m.conversation_id = :conversation_id AND c.tenant_id = :tenant_id AND m.deleted_at IS NULL`
+	args := map[string]interface{}{"conversation_id": conversationID, "limit": q.Limit + 1}
+
+	order := ` ORDER BY m.created_at DESC, m.id DESC`
+	cursorID, op := q.Before, " < "
+	if q.After != "" {
+		cursorID, op = q.After, " > "
+		order = ` ORDER BY m.created_at ASC, m.id ASC`
+	}
+	if cursorID != "" {
+		var cursorAt time.Time
+		cursorQuery := `SELECT m.created_at FROM messages m JOIN conversations c ON c.id = m.conversation_id
+						WHERE m.id = :cursor_id AND m.conversation_id = :conversation_id AND c.tenant_id = :tenant_id`
+		if err := scope.get(ctx, &cursorAt, cursorQuery, map[string]interface{}{"cursor_id": cursorID, "conversation_id": conversationID}); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, false, ErrInvalidCursor
+			}
+			return nil, false, err
+		}
+		query += ` AND (m.created_at, m.id)` + op + `(:cursor_at, :cursor_id)`
+		args["cursor_at"], args["cursor_id"] = cursorAt, cursorID
+	}
+
+	if err := scope.selectAll(ctx, &messages, query+order+` LIMIT :limit`, args); err != nil {
+		return nil, false, err
+	}
+
+	if len(messages) > q.Limit {
+		messages = messages[:q.Limit]
+		more = true
+	}
+	if q.After == "" {
+		// fetched newest first; return them oldest first
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+	return messages, more, nil
+}
+
+// messageOfTenant restricts an UPDATE of messages m to rows whose conversation c is the tenant's
+const messageOfTenant = ` FROM conversations c WHERE m.id = :id AND c.id = m.conversation_id AND c.tenant_id = :tenant_id`
+
+func (r *MessageRepository) UpdateDeliveryStatus(ctx context.Context, id, tenantID, status string, attempts int, deliveryErr string) error {
+	query := `UPDATE messages m SET delivery_status = :status, delivery_attempts = :attempts, delivery_error = :error` + messageOfTenant
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{"id": id, "status": status, "attempts": attempts, "error": deliveryErr})
 }
 
-func (r *MessageRepository) GetByConversationID(ctx context.Context, conversationID string) ([]model.Message, error) {
-	var messages []model.Message
-	query := `SELECT * FROM messages WHERE conversation_id = ? ORDER BY created_at ASC`
-	query = r.db.Rebind(query)
-	err := r.db.SelectContext(ctx, &messages, query, conversationID)
-	return messages, err
+// UpdateText replaces the text of a message and stamps edited_at
+func (r *MessageRepository) UpdateText(ctx context.Context, tenantID string, msg *model.Message) error {
+	msg.EditedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	query := `UPDATE messages m SET message = :message, edited_at = :edited_at` + messageOfTenant + ` AND m.deleted_at IS NULL`
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{"id": msg.ID, "message": msg.Message, "edited_at": msg.EditedAt})
 }
 
-func (r *MessageRepository) Delete(ctx context.Context, id string) error {
-	query := `DELETE FROM messages WHERE id = ?`
-	query = r.db.Rebind(query)
-	_, err := r.db.ExecContext(ctx, query, id)
+// AddEdit records the text a message had before an edit
+func (r *MessageRepository) AddEdit(ctx context.Context, tenantID string, edit *model.MessageEdit) error {
+	edit.ID = uuid.New().String()
+	edit.EditedAt = time.Now()
+
+	query := `INSERT INTO message_edits (id, tenant_id, message_id, previous_message, edited_by, edited_at)
+			  VALUES (:id, :tenant_id, :message_id, :previous_message, :edited_by, :edited_at)`
+	_, err := scoped(r.db, tenantID).exec(ctx, query, map[string]interface{}{
+		"id":               edit.ID,
+		"message_id":       edit.MessageID,
+		"previous_message": edit.PreviousMessage,
+		"edited_by":        edit.EditedBy,
+		"edited_at":        edit.EditedAt,
+	})
 	return err
 }
+
+// ListEdits returns the previous versions of a message of the tenant, oldest first
+func (r *MessageRepository) ListEdits(ctx context.Context, messageID, tenantID string) ([]model.MessageEdit, error) {
+	edits := []model.MessageEdit{}
+	query := `SELECT me.id, me.message_id, me.previous_message, me.edited_by, COALESCE(u.name, '') as edited_by_name, me.edited_at
+			  FROM message_edits me
+			  LEFT JOIN users u ON u.id = me.edited_by AND u.tenant_id = me.tenant_id
+			  WHERE me.message_id = :message_id AND me.tenant_id = :tenant_id
+			  ORDER BY me.edited_at ASC, me.id ASC`
+	err := scoped(r.db, tenantID).selectAll(ctx, &edits, query, map[string]interface{}{"message_id": messageID})
+	return edits, err
+}
+
+// Delete soft-deletes a message so it can be restored until the retention job purges it
+func (r *MessageRepository) Delete(ctx context.Context, id, tenantID, userID string) error {
+	query := `UPDATE messages m SET deleted_at = :deleted_at, deleted_by = :deleted_by` + messageOfTenant + ` AND m.deleted_at IS NULL`
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{"id": id, "deleted_at": time.Now(), "deleted_by": userID})
+}
+
+// GetDeleted returns a soft-deleted message whose conversation belongs to the tenant and is not
+// itself deleted
+func (r *MessageRepository) GetDeleted(ctx context.Context, id, tenantID string) (*model.Message, error) {
+	var msg model.Message
+	query := `SELECT m.* FROM messages m JOIN conversations c ON c.id = m.conversation_id
+			  WHERE m.id = :id AND c.tenant_id = :tenant_id AND m.deleted_at IS NOT NULL AND c.deleted_at IS NULL`
+	err := scoped(r.db, tenantID).get(ctx, &msg, query, map[string]interface{}{"id": id})
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (r *MessageRepository) Restore(ctx context.Context, id, tenantID string) error {
+	query := `UPDATE messages m SET deleted_at = NULL, deleted_by = NULL` + messageOfTenant + ` AND m.deleted_at IS NOT NULL`
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{"id": id})
+}
+
+// PurgeDeleted permanently removes messages soft-deleted before cutoff, across tenants
+func (r *MessageRepository) PurgeDeleted(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, r.db.Rebind(`DELETE FROM messages WHERE deleted_at < ?`), cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
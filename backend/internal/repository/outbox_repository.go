@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// OutboxRepository stores events waiting to be published. One relay drains the outbox of every
+// tenant, so apart from Enqueue its queries are deliberately not tenant-scoped; records keep
+// their tenant in tenant_id and in the payload.
+type OutboxRepository struct {
+	db DBTX
+}
+
+func NewOutboxRepository(db *sqlx.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// Enqueue records a message to be published to RabbitMQ once the surrounding transaction commits
+func (r *OutboxRepository) Enqueue(ctx context.Context, tenantID, exchange, routingKey string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	msg := &model.OutboxMessage{
+		ID:          uuid.New().String(),
+		TenantID:    tenantID,
+		Exchange:    exchange,
+		RoutingKey:  routingKey,
+		Payload:     string(body),
+		Status:      "pending",
+		AvailableAt: time.Now(),
+		CreatedAt:   time.Now(),
+	}
+
+	query := `INSERT INTO outbox (id, tenant_id, exchange, routing_key, payload, status, attempts, available_at, created_at)
+			  VALUES (:id, :tenant_id, :exchange, :routing_key, :payload, :status, :attempts, :available_at, :created_at)`
+
+	_, err = r.db.NamedExecContext(ctx, query, msg)
+	return err
+}
+
+// ClaimPending leases up to limit due messages, oldest first, by marking them 'publishing'
+// until now+lease. Messages still 'publishing' after their lease belong to a relay that died
+// and are claimed again. Rows being claimed by another relay instance are skipped. The claim
+// commits on its own, so no lock is held while the messages are published.
+func (r *OutboxRepository) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]model.OutboxMessage, error) {
+	var msgs []model.OutboxMessage
+	now := time.Now()
+	query := `UPDATE outbox SET status = 'publishing', available_at = ?
+			  WHERE id IN (SELECT id FROM outbox WHERE status IN ('pending', 'publishing') AND available_at <= ?
+			               ORDER BY created_at ASC LIMIT ? FOR UPDATE SKIP LOCKED)
+			  RETURNING *`
+	query = r.db.Rebind(query)
+	if err := r.db.SelectContext(ctx, &msgs, query, now.Add(lease), now, limit); err != nil {
+		return nil, err
+	}
+	// RETURNING has no order
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].CreatedAt.Before(msgs[j].CreatedAt) })
+	return msgs, nil
+}
+
+// MarkSent completes a leased message
+func (r *OutboxRepository) MarkSent(ctx context.Context, id string) error {
+	query := `UPDATE outbox SET status = 'sent', attempts = attempts + 1, last_error = '', sent_at = ? WHERE id = ? AND status = 'publishing'`
+	query = r.db.Rebind(query)
+	_, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	return err
+}
+
+// MarkRetry records a failed publish of a leased message and postpones the next attempt
+func (r *OutboxRepository) MarkRetry(ctx context.Context, id, lastError string, next time.Time) error {
+	query := `UPDATE outbox SET status = 'pending', attempts = attempts + 1, last_error = ?, available_at = ? WHERE id = ? AND status = 'publishing'`
+	query = r.db.Rebind(query)
+	_, err := r.db.ExecContext(ctx, query, lastError, next, id)
+	return err
+}
+
+// Release hands a leased message back unpublished, without counting an attempt
+func (r *OutboxRepository) Release(ctx context.Context, id string) error {
+	query := `UPDATE outbox SET status = 'pending', available_at = ? WHERE id = ? AND status = 'publishing'`
+	query = r.db.Rebind(query)
+	_, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	return err
+}
+
+// PurgeSent removes published messages older than cutoff
+func (r *OutboxRepository) PurgeSent(ctx context.Context, cutoff time.Time) (int64, error) {
+	query := `DELETE FROM outbox WHERE status = 'sent' AND sent_at < ?`
+	query = r.db.Rebind(query)
+	res, err := r.db.ExecContext(ctx, query, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// PurgeDeleted removes messages whose payload refers to rows that RetentionService is about
+// to purge, whatever their status. Must run in the purge transaction before the rows
+// themselves are deleted.
+func (r *OutboxRepository) PurgeDeleted(ctx context.Context, cutoff time.Time) (int64, error) {
+	query := `WITH purged AS (` + purgedEntities + `)
+			  DELETE FROM outbox
+			  WHERE payload->>'message_id' IN (SELECT id FROM purged)
+			     OR payload->>'conversation_id' IN (SELECT id FROM purged)
+			     OR payload->>'ticket_id' IN (SELECT id FROM purged)`
+	query = r.db.Rebind(query)
+	res, err := r.db.ExecContext(ctx, query, cutoff, cutoff, cutoff, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
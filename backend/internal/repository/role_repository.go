@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// RoleRepository stores custom tenant roles; permissions are kept as a JSON array
+type RoleRepository struct {
+	db DBTX
+}
+
+func NewRoleRepository(db *sqlx.DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+func (r *RoleRepository) Create(ctx context.Context, role *model.Role) error {
+	role.ID = uuid.New().String()
+	role.CreatedAt = time.Now()
+	role.UpdatedAt = time.Now()
+	if err := encodeRolePermissions(role); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO roles (id, tenant_id, name, description, permissions, created_at, updated_at)
+			  VALUES (:id, :tenant_id, :name, :description, :permissions, :created_at, :updated_at)`
+
+	_, err := scoped(r.db, role.TenantID).exec(ctx, query, map[string]interface{}{
+		"id":          role.ID,
+		"name":        role.Name,
+		"description": role.Description,
+		"permissions": role.PermissionData,
+		"created_at":  role.CreatedAt,
+		"updated_at":  role.UpdatedAt,
+	})
+	return err
+}
+
+func (r *RoleRepository) GetByID(ctx context.Context, id, tenantID string) (*model.Role, error) {
+	var role model.Role
+	query := `SELECT * FROM roles WHERE id = :id AND tenant_id = :tenant_id`
+	err := scoped(r.db, tenantID).get(ctx, &role, query, map[string]interface{}{"id": id})
+	if err != nil {
+		return nil, err
+	}
+	return &role, decodeRolePermissions(&role)
+}
+
+func (r *RoleRepository) GetByName(ctx context.Context, tenantID, name string) (*model.Role, error) {
+	var role model.Role
+	query := `SELECT * FROM roles WHERE tenant_id = :tenant_id AND name = :name`
+	err := scoped(r.db, tenantID).get(ctx, &role, query, map[string]interface{}{"name": name})
+	if err != nil {
+		return nil, err
+	}
+	return &role, decodeRolePermissions(&role)
+}
+
+func (r *RoleRepository) List(ctx context.Context, tenantID string) ([]model.Role, error) {
+	roles := []model.Role{}
+	query := `SELECT * FROM roles WHERE tenant_id = :tenant_id ORDER BY name`
+	if err := scoped(r.db, tenantID).selectAll(ctx, &roles, query, nil); err != nil {
+		return nil, err
+	}
+	for i := range roles {
+		if err := decodeRolePermissions(&roles[i]); err != nil {
+			return nil, err
+		}
+	}
+	return roles, nil
+}
+
+// Update saves description and permissions of a role of the tenant
+func (r *RoleRepository) Update(ctx context.Context, tenantID string, role *model.Role) error {
+	role.UpdatedAt = time.Now()
+	if err := encodeRolePermissions(role); err != nil {
+		return err
+	}
+	query := `UPDATE roles SET description = :description, permissions = :permissions, updated_at = :updated_at
+			  WHERE id = :id AND tenant_id = :tenant_id`
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{
+		"id":          role.ID,
+		"description": role.Description,
+		"permissions": role.PermissionData,
+		"updated_at":  role.UpdatedAt,
+	})
+}
+
+func (r *RoleRepository) Delete(ctx context.Context, id, tenantID string) error {
+	query := `DELETE FROM roles WHERE id = :id AND tenant_id = :tenant_id`
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{"id": id})
+}
+
+func encodeRolePermissions(role *model.Role) error {
+	if role.Permissions == nil {
+		role.Permissions = []string{}
+	}
+	b, err := json.Marshal(role.Permissions)
+	if err != nil {
+		return err
+	}
+	role.PermissionData = string(b)
+	return nil
+}
+
+func decodeRolePermissions(role *model.Role) error {
+	role.Permissions = []string{}
+	if role.PermissionData == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(role.PermissionData), &role.Permissions)
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"backend/internal/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Search types and the tsvector expression / query branch of each; the expressions must match
+// the GIN indexes in migrations/postgres_init.sql
+const (
+	SearchTypeMessage  = "message"
+	SearchTypeTicket   = "ticket"
+	SearchTypeCustomer = "customer"
+)
+
+// Matches are wrapped in control characters so the service can HTML-escape snippets safely
+const (
+	SearchMarkStart = "\x01"
+	SearchMarkStop  = "\x02"
+)
+
+var searchBranches = map[string]string{
+	SearchTypeMessage: `
+		SELECT 'message' AS type, m.id, m.conversation_id, m.sender_name AS title, m.message AS body,
+			   ts_rank(to_tsvector('simple', m.message), q.query) AS rank, m.created_at
+		FROM messages m JOIN conversations c ON c.id = m.conversation_id, q
+		WHERE c.tenant_id = ? AND m.deleted_at IS NULL AND c.deleted_at IS NULL AND to_tsvector('simple', m.message) @@ q.query`,
+	SearchTypeTicket: `
+		SELECT 'ticket' AS type, t.id,
+			   COALESCE(t.conversation_id, (SELECT ct.conversation_id FROM conversation_tickets ct WHERE ct.ticket_id = t.id LIMIT 1), '') AS conversation_id,
+			   COALESCE(t.code || ' ', '') || t.title AS title, t.title || ' ' || t.description AS body,
+			   ts_rank(to_tsvector('simple', t.title || ' ' || t.description || ' ' || COALESCE(t.code, '')), q.query) AS rank, t.created_at
+		FROM tickets t, q
+		WHERE t.tenant_id = ? AND t.deleted_at IS NULL AND to_tsvector('simple', t.title || ' ' || t.description || ' ' || COALESCE(t.code, '')) @@ q.query`,
+	SearchTypeCustomer: `
+		SELECT 'customer' AS type, cu.id,
+			   COALESCE((SELECT c.id FROM conversations c WHERE c.customer_id = cu.id AND c.deleted_at IS NULL ORDER BY c.created_at DESC LIMIT 1), '') AS conversation_id,
+			   cu.name AS title, cu.name || ' ' || cu.external_id AS body,
+			   ts_rank(to_tsvector('simple', cu.name || ' ' || cu.external_id), q.query) AS rank, cu.created_at
+		FROM customers cu, q
+		WHERE cu.tenant_id = ? AND to_tsvector('simple', cu.name || ' ' || cu.external_id) @@ q.query`,
+}
+
+// SearchTypes lists every searchable type in result order for ties
+var SearchTypes = []string{SearchTypeMessage, SearchTypeTicket, SearchTypeCustomer}
+
+type SearchRepository struct {
+	db *sqlx.DB
+}
+
+func NewSearchRepository(db *sqlx.DB) *SearchRepository {
+	return &SearchRepository{db: db}
+}
+
+// Search ranks hits of the given types within the tenant and returns one page plus the total
+func (r *SearchRepository) Search(ctx context.Context, tenantID string, filter model.SearchFilter) ([]model.SearchResult, int, error) {
+	results := []model.SearchResult{}
+	var total int
+
+	hits, args := searchHits(tenantID, filter.Query, filter.Types)
+
+	countQuery := r.db.Rebind(hits + ` SELECT COUNT(*) FROM hits`)
+	if err := r.db.GetContext(ctx, &total, countQuery, args...); err != nil {
+		return nil, 0, err
+	}
+
+	selectQuery := hits + `
+		SELECT h.type, h.id, h.conversation_id, h.title,
+			   ts_headline('simple', h.body, q.query, 'StartSel=` + SearchMarkStart + `, StopSel=` + SearchMarkStop + `, MaxFragments=2, MaxWords=20, MinWords=5') AS snippet,
+			   h.rank, h.created_at
+		FROM hits h, q
+		ORDER BY h.rank DESC, h.created_at DESC LIMIT ? OFFSET ?`
+	args = append(args, filter.PerPage, (filter.Page-1)*filter.PerPage)
+	selectQuery = r.db.Rebind(selectQuery)
+	err := r.db.SelectContext(ctx, &results, selectQuery, args...)
+
+	return results, total, err
+}
+
+// searchHits builds the "q" and "hits" CTEs: one UNION ALL branch per type, each restricted to
+// the tenant. Types must be known search types, as validated by the service.
+func searchHits(tenantID, query string, types []string) (string, []interface{}) {
+	branches := make([]string, 0, len(types))
+	args := []interface{}{query}
+	for _, t := range types {
+		branches = append(branches, searchBranches[t])
+		args = append(args, tenantID)
+	}
+	hits := `WITH q AS (SELECT websearch_to_tsquery('simple', ?) AS query), hits AS (` +
+		strings.Join(branches, ` UNION ALL `) + `)`
+	return hits, args
+}
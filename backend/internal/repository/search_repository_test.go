@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSearchHitsSelectsOneBranchPerType(t *testing.T) {
+	branchTables := map[string]string{
+		SearchTypeMessage:  "FROM messages m",
+		SearchTypeTicket:   "FROM tickets t",
+		SearchTypeCustomer: "FROM customers cu",
+	}
+	cases := [][]string{
+		{SearchTypeMessage},
+		{SearchTypeTicket},
+		{SearchTypeCustomer},
+		{SearchTypeMessage, SearchTypeCustomer},
+		SearchTypes,
+	}
+	for _, types := range cases {
+		hits, args := searchHits("tenant-a", "refund", types)
+
+		if got := strings.Count(hits, "UNION ALL"); got != len(types)-1 {
+			t.Errorf("%v: %d UNION ALL, want %d", types, got, len(types)-1)
+		}
+		want := map[string]bool{}
+		for _, typ := range types {
+			want[typ] = true
+		}
+		for typ, table := range branchTables {
+			if got := strings.Contains(hits, table); got != want[typ] {
+				t.Errorf("%v: %s branch included = %v, want %v", types, typ, got, want[typ])
+			}
+		}
+
+		// the search text, then the tenant once per branch, in placeholder order
+		if got := strings.Count(hits, "?"); got != len(args) {
+			t.Errorf("%v: %d placeholders for %d args", types, got, len(args))
+		}
+		if args[0] != "refund" {
+			t.Errorf("%v: first arg = %v, want the query", types, args[0])
+		}
+		for _, a := range args[1:] {
+			if a != "tenant-a" {
+				t.Errorf("%v: branch arg = %v, want the tenant", types, a)
+			}
+		}
+	}
+}
+
+func TestSearchBranchesFilterOnTenant(t *testing.T) {
+	for _, typ := range SearchTypes {
+		branch, ok := searchBranches[typ]
+		if !ok {
+			t.Errorf("no branch for search type %q", typ)
+			continue
+		}
+		if !strings.Contains(branch, "tenant_id = ?") || strings.Count(branch, "?") != 1 {
+			t.Errorf("%s branch must filter on the tenant with its only placeholder", typ)
+		}
+	}
+}
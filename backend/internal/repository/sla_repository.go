@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// SLA-tracked tables; used to build queries, never taken from user input
+const (
+	SLATableConversations = "conversations"
+	SLATableTickets       = "tickets"
+)
+
+// SLA deadlines, checked and alerted independently
+const (
+	SLADeadlineFirstResponse = "first_response"
+	SLADeadlineResolution    = "resolution"
+)
+
+// SLADeadlines lists every deadline in the order the checker visits them
+var SLADeadlines = []string{SLADeadlineFirstResponse, SLADeadlineResolution}
+
+// slaDeadlines maps a deadline to its state and due columns and the condition under which
+// it is still running (resolution is additionally stopped by resolved_at)
+var slaDeadlines = map[string]struct{ status, due, pending string }{
+	SLADeadlineFirstResponse: {"first_response_sla_status", "first_response_due_at", "first_response_at IS NULL"},
+	SLADeadlineResolution:    {"resolution_sla_status", "resolution_due_at", "TRUE"},
+}
+
+type SLARepository struct {
+	db DBTX
+}
+
+func NewSLARepository(db *sqlx.DB) *SLARepository {
+	return &SLARepository{db: db}
+}
+
+func (r *SLARepository) CreatePolicy(ctx context.Context, p *model.SLAPolicy) error {
+	p.ID = uuid.New().String()
+	p.CreatedAt = time.Now()
+	p.UpdatedAt = time.Now()
+
+	query := `INSERT INTO sla_policies (id, tenant_id, name, target_type, match_value, first_response_minutes, resolution_minutes, warning_percent, created_at, updated_at)
+			  VALUES (:id, :tenant_id, :name, :target_type, :match_value, :first_response_minutes, :resolution_minutes, :warning_percent, :created_at, :updated_at)`
+
+	_, err := scoped(r.db, p.TenantID).exec(ctx, query, policyArgs(p))
+	return err
+}
+
+func (r *SLARepository) GetPolicy(ctx context.Context, id, tenantID string) (*model.SLAPolicy, error) {
+	var p model.SLAPolicy
+	query := `SELECT * FROM sla_policies WHERE id = :id AND tenant_id = :tenant_id`
+	err := scoped(r.db, tenantID).get(ctx, &p, query, map[string]interface{}{"id": id})
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *SLARepository) ListPolicies(ctx context.Context, tenantID string) ([]model.SLAPolicy, error) {
+	policies := []model.SLAPolicy{}
+	query := `SELECT * FROM sla_policies WHERE tenant_id = :tenant_id ORDER BY target_type, match_value`
+	err := scoped(r.db, tenantID).selectAll(ctx, &policies, query, nil)
+	return policies, err
+}
+
+// UpdatePolicy saves a policy of the tenant
+func (r *SLARepository) UpdatePolicy(ctx context.Context, tenantID string, p *model.SLAPolicy) error {
+	p.UpdatedAt = time.Now()
+	query := `UPDATE sla_policies SET name = :name, target_type = :target_type, match_value = :match_value,
+			  first_response_minutes = :first_response_minutes, resolution_minutes = :resolution_minutes,
+			  warning_percent = :warning_percent, updated_at = :updated_at
+			  WHERE id = :id AND tenant_id = :tenant_id`
+	return scoped(r.db, tenantID).execOne(ctx, query, policyArgs(p))
+}
+
+func (r *SLARepository) DeletePolicy(ctx context.Context, id, tenantID string) error {
+	query := `DELETE FROM sla_policies WHERE id = :id AND tenant_id = :tenant_id`
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{"id": id})
+}
+
+// policyArgs binds the columns of a policy except its tenant, which the scope provides
+func policyArgs(p *model.SLAPolicy) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                     p.ID,
+		"name":                   p.Name,
+		"target_type":            p.TargetType,
+		"match_value":            p.MatchValue,
+		"first_response_minutes": p.FirstResponseMinutes,
+		"resolution_minutes":     p.ResolutionMinutes,
+		"warning_percent":        p.WarningPercent,
+		"created_at":             p.CreatedAt,
+		"updated_at":             p.UpdatedAt,
+	}
+}
+
+// FindPolicy returns the policy matching value for the target type, falling back to the
+// tenant default (empty match value). It returns nil without error when none applies.
+func (r *SLARepository) FindPolicy(ctx context.Context, tenantID, targetType, value string) (*model.SLAPolicy, error) {
+	var p model.SLAPolicy
+	query := `SELECT * FROM sla_policies WHERE tenant_id = :tenant_id AND target_type = :target_type AND (match_value = :value OR match_value = '')
+			  ORDER BY match_value DESC LIMIT 1`
+	err := scoped(r.db, tenantID).get(ctx, &p, query, map[string]interface{}{"target_type": targetType, "value": value})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SetDeadlines stores the policy and due dates on a row and returns its SLA state; a nil policy
+// clears SLA tracking. A recorded breach is kept, per deadline and overall, so recomputing
+// deadlines (e.g. on a priority change) cannot erase it.
+func (r *SLARepository) SetDeadlines(ctx context.Context, table, id, tenantID string, p *model.SLAPolicy, from time.Time) (model.SLAState, error) {
+	var policyID sql.NullString
+	var firstDue, resolutionDue sql.NullTime
+	status := "none"
+	if p != nil {
+		policyID = sql.NullString{String: p.ID, Valid: true}
+		firstDue = sql.NullTime{Time: from.Add(time.Duration(p.FirstResponseMinutes) * time.Minute), Valid: true}
+		resolutionDue = sql.NullTime{Time: from.Add(time.Duration(p.ResolutionMinutes) * time.Minute), Valid: true}
+		status = "ok"
+	}
+
+	query := `UPDATE ` + table + ` SET sla_policy_id = :policy_id, first_response_due_at = :first_due, resolution_due_at = :resolution_due,
+			  first_response_sla_status = CASE WHEN first_response_sla_status = 'breached' THEN first_response_sla_status ELSE :status END,
+			  resolution_sla_status = CASE WHEN resolution_sla_status = 'breached' THEN resolution_sla_status ELSE :status END,
+			  sla_status = CASE WHEN sla_status = 'breached' THEN sla_status ELSE :status END
+			  WHERE id = :id AND tenant_id = :tenant_id
+			  RETURNING sla_status, first_response_sla_status, resolution_sla_status`
+	var state model.SLAState
+	err := scoped(r.db, tenantID).get(ctx, &state, query, map[string]interface{}{
+		"id":             id,
+		"policy_id":      policyID,
+		"first_due":      firstDue,
+		"resolution_due": resolutionDue,
+		"status":         status,
+	})
+	return state, err
+}
+
+// MarkFirstResponse records the first response time once; later calls keep the first one
+func (r *SLARepository) MarkFirstResponse(ctx context.Context, table, id, tenantID string) error {
+	query := `UPDATE ` + table + ` SET first_response_at = COALESCE(first_response_at, :now) WHERE id = :id AND tenant_id = :tenant_id`
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{"id": id, "now": time.Now()})
+}
+
+// SetResolved records (or clears, when reopened) the resolution time
+func (r *SLARepository) SetResolved(ctx context.Context, table, id, tenantID string, resolved bool) error {
+	query := `UPDATE ` + table + ` SET resolved_at = NULL WHERE id = :id AND tenant_id = :tenant_id`
+	if resolved {
+		query = `UPDATE ` + table + ` SET resolved_at = COALESCE(resolved_at, now()) WHERE id = :id AND tenant_id = :tenant_id`
+	}
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{"id": id})
+}
+
+// MarkBreached flips unresolved rows past the given deadline to breached and returns them.
+// Each deadline keeps its own state, so a first-response breach does not hide a later
+// resolution breach; sla_status holds the worst state either deadline reached.
+func (r *SLARepository) MarkBreached(ctx context.Context, table, deadline string, now time.Time) ([]model.SLAAlert, error) {
+	d := slaDeadlines[deadline]
+	var alerts []model.SLAAlert
+	query := `
+		UPDATE ` + table + ` SET ` + d.status + ` = 'breached', sla_status = 'breached'
+		WHERE ` + d.status + ` IN ('ok', 'warning') AND resolved_at IS NULL AND deleted_at IS NULL
+		  AND ` + d.pending + ` AND ` + d.due + ` <= ?
+		RETURNING id, tenant_id, '` + deadline + `' as deadline`
+	query = r.db.Rebind(query)
+	err := r.db.SelectContext(ctx, &alerts, query, now)
+	return alerts, err
+}
+
+// MarkWarning flips unresolved rows that consumed warning_percent of the given deadline's
+// window to warning and returns them
+func (r *SLARepository) MarkWarning(ctx context.Context, table, deadline string, now time.Time) ([]model.SLAAlert, error) {
+	d := slaDeadlines[deadline]
+	var alerts []model.SLAAlert
+	query := `
+		UPDATE ` + table + ` t SET ` + d.status + ` = 'warning',
+		  sla_status = CASE WHEN t.sla_status = 'breached' THEN t.sla_status ELSE 'warning' END
+		FROM sla_policies p
+		WHERE p.id = t.sla_policy_id AND t.` + d.status + ` = 'ok' AND t.resolved_at IS NULL AND t.deleted_at IS NULL
+		  AND t.` + d.pending + ` AND t.created_at + (t.` + d.due + ` - t.created_at) * (p.warning_percent / 100.0) <= ?
+		RETURNING t.id, t.tenant_id, '` + deadline + `' as deadline`
+	query = r.db.Rebind(query)
+	err := r.db.SelectContext(ctx, &alerts, query, now)
+	return alerts, err
+}
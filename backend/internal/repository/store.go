@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DBTX is implemented by both *sqlx.DB and *sqlx.Tx so repositories can run inside a transaction
+type DBTX interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+}
+
+// Tx exposes repositories bound to a single database transaction
+type Tx struct {
+	Conversations *ConversationRepository
+	Messages      *MessageRepository
+	Customers     *CustomerRepository
+	Tickets       *TicketRepository
+	Events        *EventRepository
+	Outbox        *OutboxRepository
+	SLA           *SLARepository
+	Assignment    *AssignmentRepository
+	Idempotency   *IdempotencyRepository
+	Users         *UserRepository
+	Tenants       *TenantRepository
+	Invites       *InviteRepository
+	Channels      *ChannelRepository
+}
+
+// Store runs units of work whose writes, events rows and outbox records must commit together
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db: db}
+}
+
+// WithinTx runs fn in a transaction, committing when it returns nil and rolling back otherwise
+func (s *Store) WithinTx(ctx context.Context, fn func(tx *Tx) error) error {
+	sqlTx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer sqlTx.Rollback()
+
+	tx := &Tx{
+		Conversations: &ConversationRepository{db: sqlTx},
+		Messages:      &MessageRepository{db: sqlTx},
+		Customers:     &CustomerRepository{db: sqlTx},
+		Tickets:       &TicketRepository{db: sqlTx},
+		Events:        &EventRepository{db: sqlTx},
+		Outbox:        &OutboxRepository{db: sqlTx},
+		SLA:           &SLARepository{db: sqlTx},
+		Assignment:    &AssignmentRepository{db: sqlTx},
+		Idempotency:   &IdempotencyRepository{db: sqlTx},
+		Users:         &UserRepository{db: sqlTx},
+		Tenants:       &TenantRepository{db: sqlTx},
+		Invites:       &InviteRepository{db: sqlTx},
+		Channels:      &ChannelRepository{db: sqlTx},
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return sqlTx.Commit()
+}
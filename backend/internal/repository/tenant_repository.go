@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// Tenant statuses
+const (
+	TenantStatusActive    = "active"
+	TenantStatusSuspended = "suspended"
+)
+
+type TenantRepository struct {
+	db DBTX
+}
+
+func NewTenantRepository(db *sqlx.DB) *TenantRepository {
+	return &TenantRepository{db: db}
+}
+
+func (r *TenantRepository) Create(ctx context.Context, t *model.Tenant) error {
+	t.ID = uuid.New().String()
+	t.CreatedAt = time.Now()
+	t.UpdatedAt = time.Now()
+	if t.Status == "" {
+		t.Status = TenantStatusActive
+	}
+	if err := encodeTenantSettings(t); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO tenants (id, name, plan, settings, status, created_at, updated_at)
+			  VALUES (:id, :name, :plan, :settings, :status, :created_at, :updated_at)`
+
+	_, err := r.db.NamedExecContext(ctx, query, t)
+	return err
+}
+
+func (r *TenantRepository) GetByID(ctx context.Context, id string) (*model.Tenant, error) {
+	var t model.Tenant
+	query := `SELECT * FROM tenants WHERE id = ?`
+	query = r.db.Rebind(query)
+	err := r.db.GetContext(ctx, &t, query, id)
+	if err != nil {
+		return nil, err
+	}
+	return &t, decodeTenantSettings(&t)
+}
+
+func (r *TenantRepository) List(ctx context.Context) ([]model.Tenant, error) {
+	tenants := []model.Tenant{}
+	query := `SELECT * FROM tenants ORDER BY created_at DESC`
+	if err := r.db.SelectContext(ctx, &tenants, query); err != nil {
+		return nil, err
+	}
+	for i := range tenants {
+		if err := decodeTenantSettings(&tenants[i]); err != nil {
+			return nil, err
+		}
+	}
+	return tenants, nil
+}
+
+func (r *TenantRepository) Update(ctx context.Context, t *model.Tenant) error {
+	t.UpdatedAt = time.Now()
+	if err := encodeTenantSettings(t); err != nil {
+		return err
+	}
+	query := `UPDATE tenants SET name = :name, plan = :plan, settings = :settings, updated_at = :updated_at WHERE id = :id`
+	_, err := r.db.NamedExecContext(ctx, query, t)
+	return err
+}
+
+// SetStatus changes the tenant status; suspended_at is set on suspension and cleared otherwise
+func (r *TenantRepository) SetStatus(ctx context.Context, id, status string) error {
+	query := `UPDATE tenants SET status = ?, suspended_at = CASE WHEN ? = ? THEN now() ELSE NULL END, updated_at = now() WHERE id = ?`
+	query = r.db.Rebind(query)
+	_, err := r.db.ExecContext(ctx, query, status, status, TenantStatusSuspended, id)
+	return err
+}
+
+func encodeTenantSettings(t *model.Tenant) error {
+	if t.Settings == nil {
+		t.Settings = map[string]interface{}{}
+	}
+	b, err := json.Marshal(t.Settings)
+	if err != nil {
+		return err
+	}
+	t.SettingsData = string(b)
+	return nil
+}
+
+func decodeTenantSettings(t *model.Tenant) error {
+	t.Settings = map[string]interface{}{}
+	if t.SettingsData == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(t.SettingsData), &t.Settings)
+}
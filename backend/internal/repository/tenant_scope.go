@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+)
+
+var (
+	// ErrTenantRequired is returned by tenant-scoped queries run without a tenant id
+	ErrTenantRequired = errors.New("repository: tenant id is required")
+	// ErrUnscopedQuery is returned by tenant-scoped queries that do not filter on :tenant_id
+	ErrUnscopedQuery = errors.New("repository: query does not reference :tenant_id")
+)
+
+// tenantParam matches the :tenant_id named parameter but not a "::tenant_id" cast
+var tenantParam = regexp.MustCompile(`(^|[^:]):tenant_id\b`)
+
+// tenantScope runs queries restricted to one tenant. Queries use named parameters and must
+// reference :tenant_id, which the scope binds itself from the tenant it was created with, so a
+// query can neither leave out the tenant filter nor take the tenant from caller-supplied args.
+// Postgres casts must be written as CAST(x AS type): "::" escapes a colon in named queries.
+//
+// Every read and write of tenant data goes through a scope: records addressed by id (so an id
+// from another tenant matches no row), tenant configuration (roles, SLA policies, assignment
+// settings), customers, invites and idempotency keys. Updates take the tenant as an argument
+// rather than reading it from the struct being saved. The filtered list, search, timeline and
+// audit queries build positional filters dynamically and start with a tenant_id condition
+// instead. The intentionally unscoped queries are:
+//   - cross-tenant background jobs: the outbox relay, SLA sweeps and the retention purge
+//   - lookups that establish the tenant of a request: login by email, refresh session, invite
+//     token and webhook channel slug
+//   - the platform tenant API, which manages tenants themselves
+type tenantScope struct {
+	db       DBTX
+	tenantID string
+}
+
+func scoped(db DBTX, tenantID string) tenantScope {
+	return tenantScope{db: db, tenantID: tenantID}
+}
+
+// bind compiles a named query to the driver's positional form with the scope's tenant
+func (s tenantScope) bind(query string, args map[string]interface{}) (string, []interface{}, error) {
+	if s.tenantID == "" {
+		return "", nil, ErrTenantRequired
+	}
+	if !tenantParam.MatchString(query) {
+		return "", nil, ErrUnscopedQuery
+	}
+	named := make(map[string]interface{}, len(args)+1)
+	for k, v := range args {
+		named[k] = v
+	}
+	named["tenant_id"] = s.tenantID
+	return s.db.BindNamed(query, named)
+}
+
+func (s tenantScope) get(ctx context.Context, dest interface{}, query string, args map[string]interface{}) error {
+	q, a, err := s.bind(query, args)
+	if err != nil {
+		return err
+	}
+	return s.db.GetContext(ctx, dest, q, a...)
+}
+
+func (s tenantScope) selectAll(ctx context.Context, dest interface{}, query string, args map[string]interface{}) error {
+	q, a, err := s.bind(query, args)
+	if err != nil {
+		return err
+	}
+	return s.db.SelectContext(ctx, dest, q, a...)
+}
+
+// exec runs a write and returns the number of rows it affected
+func (s tenantScope) exec(ctx context.Context, query string, args map[string]interface{}) (int64, error) {
+	q, a, err := s.bind(query, args)
+	if err != nil {
+		return 0, err
+	}
+	res, err := s.db.ExecContext(ctx, q, a...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// execOne runs a write addressed to one row and returns sql.ErrNoRows when no row of the
+// tenant matched, so writes to another tenant's ids fail instead of silently doing nothing
+func (s tenantScope) execOne(ctx context.Context, query string, args map[string]interface{}) error {
+	n, err := s.exec(ctx, query, args)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
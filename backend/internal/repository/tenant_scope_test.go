@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestTenantScopeRequiresTenant(t *testing.T) {
+	db := sqlx.NewDb(nil, "pgx")
+	_, _, err := scoped(db, "").bind(`SELECT * FROM tickets WHERE id = :id AND tenant_id = :tenant_id`, map[string]interface{}{"id": "t1"})
+	if !errors.Is(err, ErrTenantRequired) {
+		t.Fatalf("bind without tenant: got %v, want ErrTenantRequired", err)
+	}
+}
+
+func TestTenantScopeRejectsUnscopedQueries(t *testing.T) {
+	db := sqlx.NewDb(nil, "pgx")
+	queries := []string{
+		`SELECT * FROM tickets WHERE id = :id`,
+		`SELECT * FROM tickets WHERE id = :id AND tenant_id = tenant_id`,
+		`SELECT * FROM tickets WHERE id = :id AND tenant_id = :tenant_identifier`,
+		`SELECT * FROM tickets WHERE id = :id AND tenant_id = CAST(id AS TEXT)::tenant_id`,
+	}
+	for _, q := range queries {
+		if _, _, err := scoped(db, "tenant-a").bind(q, map[string]interface{}{"id": "t1"}); !errors.Is(err, ErrUnscopedQuery) {
+			t.Errorf("bind %q: got %v, want ErrUnscopedQuery", q, err)
+		}
+	}
+}
+
+func TestTenantScopeBindsItsOwnTenant(t *testing.T) {
+	db := sqlx.NewDb(nil, "pgx")
+	args := map[string]interface{}{"id": "t1", "tenant_id": "tenant-b"}
+	q, a, err := scoped(db, "tenant-a").bind(`SELECT * FROM tickets WHERE id = :id AND tenant_id = :tenant_id`, args)
+	if err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	if want := `SELECT * FROM tickets WHERE id = $1 AND tenant_id = $2`; q != want {
+		t.Errorf("query = %q, want %q", q, want)
+	}
+	if len(a) != 2 || a[0] != "t1" || a[1] != "tenant-a" {
+		t.Errorf("args = %v, want [t1 tenant-a]", a)
+	}
+	if args["tenant_id"] != "tenant-b" {
+		t.Errorf("bind modified the caller's args: %v", args)
+	}
+}
+
+func TestTenantScopeFailsBeforeQuerying(t *testing.T) {
+	// A nil connection would panic if bind let the query through
+	db := sqlx.NewDb(nil, "pgx")
+	var n int
+	if err := scoped(db, "").get(context.Background(), &n, `SELECT 1 WHERE :tenant_id <> ''`, nil); !errors.Is(err, ErrTenantRequired) {
+		t.Errorf("get: got %v, want ErrTenantRequired", err)
+	}
+	if err := scoped(db, "tenant-a").execOne(context.Background(), `DELETE FROM tickets WHERE id = :id`, map[string]interface{}{"id": "t1"}); !errors.Is(err, ErrUnscopedQuery) {
+		t.Errorf("execOne: got %v, want ErrUnscopedQuery", err)
+	}
+}
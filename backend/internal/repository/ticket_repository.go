@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"backend/internal/model"
@@ -11,39 +12,56 @@ import (
 )
 
 type TicketRepository struct {
-	db *sqlx.DB
+	db DBTX
 }
 
 func NewTicketRepository(db *sqlx.DB) *TicketRepository {
 	return &TicketRepository{db: db}
 }
 
+// Create inserts a ticket of ticket.TenantID; its conversation and assignee, when set, must
+// belong to the same tenant
 func (r *TicketRepository) Create(ctx context.Context, ticket *model.Ticket) error {
 	ticket.ID = uuid.New().String()
 	ticket.Status = "open"
+	ticket.SLAStatus = "none"
+	ticket.FirstResponseSLAStatus = "none"
+	ticket.ResolutionSLAStatus = "none"
 	ticket.CreatedAt = time.Now()
 	ticket.UpdatedAt = time.Now()
 
 	query := `INSERT INTO tickets (id, tenant_id, conversation_id, code, title, description, status, priority, assigned_agent_id, created_by_id, created_at, updated_at)
-			  VALUES (:id, :tenant_id, :conversation_id, :code, :title, :description, :status, :priority, :assigned_agent_id, :created_by_id, :created_at, :updated_at)`
+			  SELECT :id, :tenant_id, :conversation_id, :code, :title, :description, :status, :priority, :assigned_agent_id, :created_by_id, :created_at, :updated_at
+			  WHERE (CAST(:conversation_id AS VARCHAR) IS NULL OR EXISTS(SELECT 1 FROM conversations c WHERE c.id = :conversation_id AND c.tenant_id = :tenant_id))
+			    AND (CAST(:assigned_agent_id AS VARCHAR) IS NULL OR EXISTS(SELECT 1 FROM users u WHERE u.id = :assigned_agent_id AND u.tenant_id = :tenant_id))`
 
-	_, err := r.db.NamedExecContext(ctx, query, ticket)
-	return err
+	return scoped(r.db, ticket.TenantID).execOne(ctx, query, map[string]interface{}{
+		"id":                ticket.ID,
+		"conversation_id":   ticket.ConversationID,
+		"code":              ticket.Code,
+		"title":             ticket.Title,
+		"description":       ticket.Description,
+		"status":            ticket.Status,
+		"priority":          ticket.Priority,
+		"assigned_agent_id": ticket.AssignedAgentID,
+		"created_by_id":     ticket.CreatedByID,
+		"created_at":        ticket.CreatedAt,
+		"updated_at":        ticket.UpdatedAt,
+	})
 }
 
-func (r *TicketRepository) GetByID(ctx context.Context, id, tenantID string) (*model.Ticket, error) {
-	var ticket model.Ticket
-	query := `
+const ticketSelect = `
 		SELECT t.*,
 			   COALESCE(u1.name, '') as assigned_agent_name,
 			   COALESCE(u2.name, '') as created_by_name
 		FROM tickets t
-		LEFT JOIN users u1 ON t.assigned_agent_id = u1.id
-		LEFT JOIN users u2 ON t.created_by_id = u2.id
-		WHERE t.id = ? AND t.tenant_id = ?`
+		LEFT JOIN users u1 ON t.assigned_agent_id = u1.id AND u1.tenant_id = t.tenant_id
+		LEFT JOIN users u2 ON t.created_by_id = u2.id AND u2.tenant_id = t.tenant_id`
 
-	query = r.db.Rebind(query)
-	err := r.db.GetContext(ctx, &ticket, query, id, tenantID)
+func (r *TicketRepository) GetByID(ctx context.Context, id, tenantID string) (*model.Ticket, error) {
+	var ticket model.Ticket
+	query := ticketSelect + ` WHERE t.id = :id AND t.tenant_id = :tenant_id AND t.deleted_at IS NULL`
+	err := scoped(r.db, tenantID).get(ctx, &ticket, query, map[string]interface{}{"id": id})
 	if err != nil {
 		return nil, err
 	}
@@ -52,42 +70,49 @@ func (r *TicketRepository) GetByID(ctx context.Context, id, tenantID string) (*m
 
 func (r *TicketRepository) GetByCode(ctx context.Context, code, tenantID string) (*model.Ticket, error) {
 	var ticket model.Ticket
-	query := `
-		SELECT t.*,
-			   COALESCE(u1.name, '') as assigned_agent_name,
-			   COALESCE(u2.name, '') as created_by_name
-		FROM tickets t
-		LEFT JOIN users u1 ON t.assigned_agent_id = u1.id
-		LEFT JOIN users u2 ON t.created_by_id = u2.id
-		WHERE t.code = ? AND t.tenant_id = ? LIMIT 1`
-	query = r.db.Rebind(query)
-	err := r.db.GetContext(ctx, &ticket, query, code, tenantID)
+	query := ticketSelect + ` WHERE t.code = :code AND t.tenant_id = :tenant_id AND t.deleted_at IS NULL LIMIT 1`
+	err := scoped(r.db, tenantID).get(ctx, &ticket, query, map[string]interface{}{"code": code})
 	if err != nil {
 		return nil, err
 	}
 	return &ticket, nil
 }
 
-func (r *TicketRepository) GetByConversationID(ctx context.Context, conversationID string) (*model.Ticket, error) {
+func (r *TicketRepository) GetByConversationID(ctx context.Context, conversationID, tenantID string) (*model.Ticket, error) {
 	// Deprecated: single-result; return first ticket linked to conversation via join table
 	var ticket model.Ticket
-	query := `SELECT t.* FROM tickets t JOIN conversation_tickets ct ON ct.ticket_id = t.id WHERE ct.conversation_id = ? LIMIT 1`
-	query = r.db.Rebind(query)
-	err := r.db.GetContext(ctx, &ticket, query, conversationID)
+	query := `SELECT t.* FROM tickets t JOIN conversation_tickets ct ON ct.ticket_id = t.id
+			  WHERE ct.conversation_id = :conversation_id AND t.tenant_id = :tenant_id AND t.deleted_at IS NULL LIMIT 1`
+	err := scoped(r.db, tenantID).get(ctx, &ticket, query, map[string]interface{}{"conversation_id": conversationID})
 	if err != nil {
 		return nil, err
 	}
 	return &ticket, nil
 }
 
-func (r *TicketRepository) ListByConversationID(ctx context.Context, conversationID string) ([]model.Ticket, error) {
-	var tickets []model.Ticket
-	query := `SELECT t.* FROM tickets t JOIN conversation_tickets ct ON ct.ticket_id = t.id WHERE ct.conversation_id = ? ORDER BY ct.created_at DESC`
-	query = r.db.Rebind(query)
-	err := r.db.SelectContext(ctx, &tickets, query, conversationID)
+// ListByConversationID returns the tenant's tickets linked to a conversation, newest link first
+func (r *TicketRepository) ListByConversationID(ctx context.Context, conversationID, tenantID string) ([]model.Ticket, error) {
+	tickets := []model.Ticket{}
+	query := `SELECT t.* FROM tickets t JOIN conversation_tickets ct ON ct.ticket_id = t.id
+			  WHERE ct.conversation_id = :conversation_id AND t.tenant_id = :tenant_id AND t.deleted_at IS NULL
+			  ORDER BY ct.created_at DESC`
+	err := scoped(r.db, tenantID).selectAll(ctx, &tickets, query, map[string]interface{}{"conversation_id": conversationID})
 	return tickets, err
 }
 
+// ticketSorts are the sort keys accepted by the ticket list
+var ticketSorts = map[string]string{
+	"created_at": "t.created_at",
+	"updated_at": "t.updated_at",
+}
+
+const ticketListSelect = `
+		SELECT t.*,
+			   COALESCE(u1.name, '') as assigned_agent_name,
+			   COALESCE(u2.name, '') as created_by_name
+		`
+
+// List returns one offset page of tickets and the total count
 func (r *TicketRepository) List(ctx context.Context, tenantID string, filter model.TicketFilter) ([]model.Ticket, int, error) {
 	var tickets []model.Ticket
 	var total int
@@ -101,39 +126,22 @@ func (r *TicketRepository) List(ctx context.Context, tenantID string, filter mod
 	}
 	offset := (filter.Page - 1) * filter.PerPage
 
-	// Build query
-	baseQuery := `
-		FROM tickets t
-		LEFT JOIN users u1 ON t.assigned_agent_id = u1.id
-		LEFT JOIN users u2 ON t.created_by_id = u2.id
-		WHERE t.tenant_id = ?`
-
-	args := []interface{}{tenantID}
-
-	if filter.Status != "" {
-		baseQuery += ` AND t.status = ?`
-		args = append(args, filter.Status)
-	}
-
-	if filter.Priority != "" {
-		baseQuery += ` AND t.priority = ?`
-		args = append(args, filter.Priority)
+	sort, err := resolveSort(filter.Sort, "-created_at", ticketSorts)
+	if err != nil {
+		return nil, 0, err
 	}
+	baseQuery, args := ticketListWhere(tenantID, filter)
 
 	// Count total
 	countQuery := `SELECT COUNT(*) ` + baseQuery
 	countQuery = r.db.Rebind(countQuery)
-	err := r.db.GetContext(ctx, &total, countQuery, args...)
+	err = r.db.GetContext(ctx, &total, countQuery, args...)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// Get data
-	selectQuery := `
-		SELECT t.*,
-			   COALESCE(u1.name, '') as assigned_agent_name,
-			   COALESCE(u2.name, '') as created_by_name
-		` + baseQuery + ` ORDER BY t.created_at DESC LIMIT ? OFFSET ?`
+	selectQuery := ticketListSelect + baseQuery + sort.orderBy("t.id") + ` LIMIT ? OFFSET ?`
 	args = append(args, filter.PerPage, offset)
 	selectQuery = r.db.Rebind(selectQuery)
 	err = r.db.SelectContext(ctx, &tickets, selectQuery, args...)
@@ -141,47 +149,159 @@ func (r *TicketRepository) List(ctx context.Context, tenantID string, filter mod
 	return tickets, total, err
 }
 
-func (r *TicketRepository) UpdateStatus(ctx context.Context, id, status string) error {
-	query := `UPDATE tickets SET status = ?, updated_at = ? WHERE id = ?`
-	query = r.db.Rebind(query)
-	_, err := r.db.ExecContext(ctx, query, status, time.Now(), id)
-	return err
+// ListAfter returns the keyset page following filter.Cursor (the first page when empty) and
+// the cursor of the next page, which is empty on the last page
+func (r *TicketRepository) ListAfter(ctx context.Context, tenantID string, filter model.TicketFilter) ([]model.Ticket, string, error) {
+	tickets := []model.Ticket{}
+
+	sort, err := resolveSort(filter.Sort, "-created_at", ticketSorts)
+	if err != nil {
+		return nil, "", err
+	}
+	baseQuery, args := ticketListWhere(tenantID, filter)
+	baseQuery, args, err = sort.after(baseQuery, args, "t.id", filter.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	selectQuery := ticketListSelect + baseQuery + sort.orderBy("t.id") + ` LIMIT ?`
+	args = append(args, filter.PerPage+1)
+	selectQuery = r.db.Rebind(selectQuery)
+	if err := r.db.SelectContext(ctx, &tickets, selectQuery, args...); err != nil {
+		return nil, "", err
+	}
+
+	if len(tickets) <= filter.PerPage {
+		return tickets, "", nil
+	}
+	tickets = tickets[:filter.PerPage]
+	last := tickets[len(tickets)-1]
+	next := listCursor{Sort: sort.key, ID: last.ID, At: last.CreatedAt}
+	if sort.key == "updated_at" {
+		next.At = last.UpdatedAt
+	}
+	return tickets, encodeCursor(next), nil
+}
+
+// ticketListWhere builds the FROM/WHERE clause shared by List and ListAfter
+func ticketListWhere(tenantID string, filter model.TicketFilter) (string, []interface{}) {
+	baseQuery := `
+		FROM tickets t
+		LEFT JOIN users u1 ON t.assigned_agent_id = u1.id AND u1.tenant_id = t.tenant_id
+		LEFT JOIN users u2 ON t.created_by_id = u2.id AND u2.tenant_id = t.tenant_id
+		WHERE t.tenant_id = ? AND t.deleted_at IS NULL`
+
+	args := []interface{}{tenantID}
+
+	baseQuery, args = addInFilter(baseQuery, args, "t.status", filter.Status)
+	baseQuery, args = addInFilter(baseQuery, args, "t.priority", filter.Priority)
+	baseQuery, args = addInFilter(baseQuery, args, "t.sla_status", filter.SLAStatus)
+
+	if filter.Unassigned {
+		baseQuery += ` AND t.assigned_agent_id IS NULL`
+	} else if filter.AssignedAgentID != "" {
+		baseQuery += ` AND t.assigned_agent_id = ?`
+		args = append(args, filter.AssignedAgentID)
+	}
+
+	if filter.ConversationID != "" {
+		baseQuery += ` AND (t.conversation_id = ? OR EXISTS(SELECT 1 FROM conversation_tickets ct WHERE ct.ticket_id = t.id AND ct.conversation_id = ?))`
+		args = append(args, filter.ConversationID, filter.ConversationID)
+	}
+
+	// Free text uses the ticket search index expression
+	if filter.Query != "" {
+		baseQuery += ` AND to_tsvector('simple', t.title || ' ' || t.description || ' ' || COALESCE(t.code, '')) @@ websearch_to_tsquery('simple', ?)`
+		args = append(args, filter.Query)
+	}
+
+	return addDateFilters(baseQuery, args, "t", filter.ListParams)
+}
+
+func (r *TicketRepository) UpdateStatus(ctx context.Context, id, tenantID, status string) error {
+	query := `UPDATE tickets SET status = :status, updated_at = :updated_at WHERE id = :id AND tenant_id = :tenant_id AND deleted_at IS NULL`
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{"id": id, "status": status, "updated_at": time.Now()})
 }
 
-func (r *TicketRepository) Update(ctx context.Context, ticket *model.Ticket) error {
+// Update saves the editable fields of a ticket of the tenant; a new assignee must belong to
+// the same tenant
+func (r *TicketRepository) Update(ctx context.Context, tenantID string, ticket *model.Ticket) error {
 	ticket.UpdatedAt = time.Now()
-	query := `UPDATE tickets SET title = :title, description = :description, priority = :priority, assigned_agent_id = :assigned_agent_id, code = :code, updated_at = :updated_at WHERE id = :id`
-	_, err := r.db.NamedExecContext(ctx, query, ticket)
-	return err
+	query := `UPDATE tickets SET title = :title, description = :description, priority = :priority, assigned_agent_id = :assigned_agent_id, code = :code, updated_at = :updated_at
+			  WHERE id = :id AND tenant_id = :tenant_id AND deleted_at IS NULL
+			    AND (CAST(:assigned_agent_id AS VARCHAR) IS NULL OR EXISTS(SELECT 1 FROM users u WHERE u.id = :assigned_agent_id AND u.tenant_id = :tenant_id))`
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{
+		"id":                ticket.ID,
+		"title":             ticket.Title,
+		"description":       ticket.Description,
+		"priority":          ticket.Priority,
+		"assigned_agent_id": ticket.AssignedAgentID,
+		"code":              ticket.Code,
+		"updated_at":        ticket.UpdatedAt,
+	})
 }
 
-func (r *TicketRepository) Delete(ctx context.Context, id, tenantID string) error {
-	query := `DELETE FROM tickets WHERE id = ? AND tenant_id = ?`
-	query = r.db.Rebind(query)
-	_, err := r.db.ExecContext(ctx, query, id, tenantID)
-	return err
+// Delete soft-deletes a ticket; its conversation links are kept for Restore
+func (r *TicketRepository) Delete(ctx context.Context, id, tenantID, userID string) error {
+	query := `UPDATE tickets SET deleted_at = :deleted_at, deleted_by = :deleted_by WHERE id = :id AND tenant_id = :tenant_id AND deleted_at IS NULL`
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{"id": id, "deleted_at": time.Now(), "deleted_by": userID})
 }
 
-// UpdateConversationID sets the conversation_id for a ticket (used when attaching ticket to a conversation)
-func (r *TicketRepository) UpdateConversationID(ctx context.Context, ticketID, conversationID string) error {
-	query := `UPDATE tickets SET conversation_id = :conversation_id, updated_at = :updated_at WHERE id = :id`
-	params := map[string]interface{}{
-		"conversation_id": conversationID,
-		"updated_at":      time.Now(),
-		"id":              ticketID,
+// GetDeleted returns a soft-deleted ticket of the tenant
+func (r *TicketRepository) GetDeleted(ctx context.Context, id, tenantID string) (*model.Ticket, error) {
+	var ticket model.Ticket
+	query := `SELECT * FROM tickets WHERE id = :id AND tenant_id = :tenant_id AND deleted_at IS NOT NULL`
+	err := scoped(r.db, tenantID).get(ctx, &ticket, query, map[string]interface{}{"id": id})
+	if err != nil {
+		return nil, err
 	}
-	_, err := r.db.NamedExecContext(ctx, query, params)
-	return err
+	return &ticket, nil
+}
+
+func (r *TicketRepository) Restore(ctx context.Context, id, tenantID string) error {
+	query := `UPDATE tickets SET deleted_at = NULL, deleted_by = NULL, updated_at = :updated_at WHERE id = :id AND tenant_id = :tenant_id AND deleted_at IS NOT NULL`
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{"id": id, "updated_at": time.Now()})
 }
 
-// AddConversationMapping creates a link between a ticket and a conversation
-func (r *TicketRepository) AddConversationMapping(ctx context.Context, ticketID, conversationID string) error {
-	query := `INSERT INTO conversation_tickets (conversation_id, ticket_id, created_at) VALUES (:conversation_id, :ticket_id, :created_at) ON CONFLICT DO NOTHING`
-	params := map[string]interface{}{
-		"conversation_id": conversationID,
-		"ticket_id":       ticketID,
-		"created_at":      time.Now(),
+// PurgeDeleted permanently removes tickets soft-deleted before cutoff, across tenants
+func (r *TicketRepository) PurgeDeleted(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, r.db.Rebind(`DELETE FROM tickets WHERE deleted_at < ?`), cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// UpdateConversationID sets the conversation_id for a ticket (used when attaching ticket to a
+// conversation); both must belong to the tenant
+func (r *TicketRepository) UpdateConversationID(ctx context.Context, ticketID, tenantID, conversationID string) error {
+	query := `UPDATE tickets SET conversation_id = :conversation_id, updated_at = :updated_at
+			  WHERE id = :id AND tenant_id = :tenant_id
+			    AND EXISTS(SELECT 1 FROM conversations c WHERE c.id = :conversation_id AND c.tenant_id = :tenant_id)`
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{"id": ticketID, "conversation_id": conversationID, "updated_at": time.Now()})
+}
+
+// AddConversationMapping creates a link between a ticket and a conversation of the tenant; it
+// returns sql.ErrNoRows when either belongs to another tenant
+func (r *TicketRepository) AddConversationMapping(ctx context.Context, tenantID, ticketID, conversationID string) error {
+	query := `INSERT INTO conversation_tickets (conversation_id, ticket_id, created_at)
+			  SELECT c.id, t.id, :created_at FROM conversations c, tickets t
+			  WHERE c.id = :conversation_id AND c.tenant_id = :tenant_id AND t.id = :ticket_id AND t.tenant_id = :tenant_id
+			  ON CONFLICT DO NOTHING`
+	scope := scoped(r.db, tenantID)
+	n, err := scope.exec(ctx, query, map[string]interface{}{"conversation_id": conversationID, "ticket_id": ticketID, "created_at": time.Now()})
+	if err != nil || n > 0 {
+		return err
+	}
+	// nothing inserted: either the link already exists or an id is not the tenant's
+	var linked bool
+	check := `SELECT EXISTS(SELECT 1 FROM conversation_tickets ct JOIN tickets t ON t.id = ct.ticket_id
+			  WHERE ct.conversation_id = :conversation_id AND ct.ticket_id = :ticket_id AND t.tenant_id = :tenant_id)`
+	if err := scope.get(ctx, &linked, check, map[string]interface{}{"conversation_id": conversationID, "ticket_id": ticketID}); err != nil {
+		return err
+	}
+	if !linked {
+		return sql.ErrNoRows
 	}
-	_, err := r.db.NamedExecContext(ctx, query, params)
-	return err
+	return nil
 }
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+
+	"backend/internal/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// timelineSorts are the sort keys accepted by timelines; the default is oldest first
+var timelineSorts = map[string]string{
+	"created_at": "tl.created_at",
+}
+
+// timelineEvents selects events rows as timeline entries with the acting user's name. An empty
+// user_id marks an event raised by the system (webhooks, auto-assignment, SLA checks).
+const timelineEvents = `
+		SELECT e.id, 'event' AS kind, e.event_type AS type,
+			   CASE WHEN COALESCE(e.user_id, '') = '' THEN 'system' ELSE 'user' END AS actor_type,
+			   COALESCE(e.user_id, '') AS actor_id, COALESCE(u.name, '') AS actor_name,
+			   COALESCE(e.data::text, '{}') AS data, e.created_at
+		FROM events e
+		LEFT JOIN users u ON u.id = e.user_id AND u.tenant_id = e.tenant_id
+		WHERE e.tenant_id = ?`
+
+// Messages are read from the messages table, so their message.received / message.sent events
+// are left out of the conversation timeline to avoid listing them twice
+const conversationTimelineQuery = `
+		SELECT m.id, 'message' AS kind,
+			   CASE WHEN m.sender_type = 'customer' THEN 'message.received' ELSE 'message.sent' END AS type,
+			   CASE WHEN m.sender_type = 'customer' THEN 'customer' ELSE 'user' END AS actor_type,
+			   m.sender_id AS actor_id, COALESCE(u.name, m.sender_name) AS actor_name,
+			   json_build_object('message', m.message, 'delivery_status', m.delivery_status, 'edited_at', m.edited_at)::text AS data,
+			   m.created_at
+		FROM messages m
+		JOIN conversations c ON c.id = m.conversation_id
+		LEFT JOIN users u ON m.sender_type <> 'customer' AND u.id = m.sender_id AND u.tenant_id = c.tenant_id
+		WHERE c.tenant_id = ? AND m.conversation_id = ? AND m.deleted_at IS NULL
+		UNION ALL` + timelineEvents + `
+		  AND ((e.entity_type = 'conversation' AND e.entity_id = ? AND e.event_type NOT IN ('message.received', 'message.sent'))
+			OR (e.entity_type = 'ticket' AND e.event_type = 'ticket.linked' AND e.data->>'conversation_id' = ?))`
+
+// A ticket's timeline is its own events plus the escalation that created it from a conversation
+const ticketTimelineQuery = timelineEvents + `
+		  AND ((e.entity_type = 'ticket' AND e.entity_id = ?)
+			OR (e.entity_type = 'conversation' AND e.event_type = 'conversation.escalated' AND e.data->>'ticket_id' = ?))`
+
+// TimelineRepository reads the activity feeds of conversations and tickets from messages and events
+type TimelineRepository struct {
+	db *sqlx.DB
+}
+
+func NewTimelineRepository(db *sqlx.DB) *TimelineRepository {
+	return &TimelineRepository{db: db}
+}
+
+// ListConversation returns the keyset page of a conversation's messages and events following
+// params.Cursor, and the cursor of the next page (empty on the last page)
+func (r *TimelineRepository) ListConversation(ctx context.Context, tenantID, conversationID string, params model.ListParams, limit int) ([]model.TimelineEntry, string, error) {
+	args := []interface{}{tenantID, conversationID, tenantID, conversationID, conversationID}
+	return r.list(ctx, conversationTimelineQuery, args, params, limit)
+}
+
+// ListTicket returns the keyset page of a ticket's events following params.Cursor
+func (r *TimelineRepository) ListTicket(ctx context.Context, tenantID, ticketID string, params model.ListParams, limit int) ([]model.TimelineEntry, string, error) {
+	args := []interface{}{tenantID, ticketID, ticketID}
+	return r.list(ctx, ticketTimelineQuery, args, params, limit)
+}
+
+func (r *TimelineRepository) list(ctx context.Context, source string, args []interface{}, params model.ListParams, limit int) ([]model.TimelineEntry, string, error) {
+	entries := []model.TimelineEntry{}
+
+	query, args, sort, err := timelinePageQuery(source, args, params, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := r.db.SelectContext(ctx, &entries, r.db.Rebind(query), args...); err != nil {
+		return nil, "", err
+	}
+	for i := range entries {
+		entries[i].Data = json.RawMessage(entries[i].RawData)
+	}
+	entries, next := timelinePage(entries, limit, sort)
+	return entries, next, nil
+}
+
+// timelinePageQuery wraps the merged messages and events of source in the date range, keyset
+// cursor and order of params. Entries are ordered by created_at with the id as a tiebreaker, so
+// a message and an event recorded at the same instant keep a stable order across pages. One
+// extra row is fetched to tell whether another page follows.
+func timelinePageQuery(source string, args []interface{}, params model.ListParams, limit int) (string, []interface{}, listSort, error) {
+	sort, err := resolveSort(params.Sort, "created_at", timelineSorts)
+	if err != nil {
+		return "", nil, sort, err
+	}
+
+	query := `SELECT tl.* FROM (` + source + `) tl WHERE 1 = 1`
+	if params.CreatedFrom != nil {
+		query += ` AND tl.created_at >= ?`
+		args = append(args, *params.CreatedFrom)
+	}
+	if params.CreatedTo != nil {
+		query += ` AND tl.created_at <= ?`
+		args = append(args, *params.CreatedTo)
+	}
+	query, args, err = sort.after(query, args, "tl.id", params.Cursor)
+	if err != nil {
+		return "", nil, sort, err
+	}
+	return query + sort.orderBy("tl.id") + ` LIMIT ?`, append(args, limit+1), sort, nil
+}
+
+// timelinePage trims the extra row fetched by timelinePageQuery and returns the cursor of the
+// next page, empty on the last one
+func timelinePage(entries []model.TimelineEntry, limit int, sort listSort) ([]model.TimelineEntry, string) {
+	if len(entries) <= limit {
+		return entries, ""
+	}
+	entries = entries[:limit]
+	last := entries[len(entries)-1]
+	return entries, encodeCursor(listCursor{Sort: sort.key, At: last.CreatedAt, ID: last.ID})
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/internal/model"
+)
+
+func TestTimelineQueriesBindEveryArg(t *testing.T) {
+	// ListConversation and ListTicket pass 5 and 3 args for these sources
+	if got := strings.Count(conversationTimelineQuery, "?"); got != 5 {
+		t.Errorf("conversation timeline has %d placeholders, want 5", got)
+	}
+	if got := strings.Count(ticketTimelineQuery, "?"); got != 3 {
+		t.Errorf("ticket timeline has %d placeholders, want 3", got)
+	}
+	if !strings.Contains(conversationTimelineQuery, "UNION ALL") {
+		t.Error("conversation timeline does not merge messages with events")
+	}
+}
+
+func TestTimelinePageQuery(t *testing.T) {
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	at := from.Add(time.Hour)
+	cursor := encodeCursor(listCursor{Sort: "created_at", At: at, ID: "e-1"})
+
+	q, args, s, err := timelinePageQuery("SRC ?", []interface{}{"tenant-a"}, model.ListParams{CreatedFrom: &from, CreatedTo: &to, Cursor: cursor}, 20)
+	if err != nil {
+		t.Fatalf("page query: %v", err)
+	}
+	want := `SELECT tl.* FROM (SRC ?) tl WHERE 1 = 1 AND tl.created_at >= ? AND tl.created_at <= ?` +
+		` AND (tl.created_at, tl.id) > (?, ?) ORDER BY tl.created_at ASC, tl.id ASC LIMIT ?`
+	if q != want {
+		t.Errorf("query =\n%s\nwant\n%s", q, want)
+	}
+	if strings.Count(q, "?") != len(args) {
+		t.Errorf("%d placeholders for %d args", strings.Count(q, "?"), len(args))
+	}
+	if len(args) != 6 || args[0] != "tenant-a" || !args[3].(time.Time).Equal(at) || args[4] != "e-1" || args[5] != 21 {
+		t.Errorf("args = %v", args)
+	}
+	if s.key != "created_at" || s.desc {
+		t.Errorf("default sort = %+v, want created_at ascending", s)
+	}
+
+	q, _, _, err = timelinePageQuery("SRC", nil, model.ListParams{Sort: "-created_at"}, 20)
+	if err != nil || !strings.HasSuffix(q, ` ORDER BY tl.created_at DESC, tl.id DESC LIMIT ?`) {
+		t.Errorf("newest first: %q, %v", q, err)
+	}
+
+	if _, _, _, err := timelinePageQuery("SRC", nil, model.ListParams{Sort: "type"}, 20); err != ErrInvalidSort {
+		t.Errorf("unknown sort: got %v, want ErrInvalidSort", err)
+	}
+}
+
+// TestTimelinePagesMergedEntries pages through messages and events sharing timestamps the way
+// the query does, ordered by (created_at, id) and resumed after the cursor, and checks that
+// every entry is returned once and in order
+func TestTimelinePagesMergedEntries(t *testing.T) {
+	base := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	all := []model.TimelineEntry{
+		{ID: "m-2", Kind: "message", CreatedAt: base},
+		{ID: "e-1", Kind: "event", CreatedAt: base},
+		{ID: "m-1", Kind: "message", CreatedAt: base.Add(time.Second)},
+		{ID: "e-3", Kind: "event", CreatedAt: base.Add(time.Second)},
+		{ID: "e-2", Kind: "event", CreatedAt: base.Add(2 * time.Second)},
+		{ID: "m-3", Kind: "message", CreatedAt: base.Add(3 * time.Second)},
+		{ID: "e-4", Kind: "event", CreatedAt: base.Add(3 * time.Second)},
+	}
+	less := func(a, b model.TimelineEntry) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	}
+	s, _ := resolveSort("", "created_at", timelineSorts)
+
+	var seen []string
+	cursor := ""
+	for pages := 0; pages < 10; pages++ {
+		// what the database returns for the page query: rows after the cursor, ordered, limit+1
+		var rows []model.TimelineEntry
+		for _, e := range all {
+			if cursor != "" {
+				c, err := decodeCursor(cursor)
+				if err != nil {
+					t.Fatalf("decode cursor: %v", err)
+				}
+				if !less(model.TimelineEntry{ID: c.ID, CreatedAt: c.At}, e) {
+					continue
+				}
+			}
+			rows = append(rows, e)
+		}
+		sort.Slice(rows, func(i, j int) bool { return less(rows[i], rows[j]) })
+		if len(rows) > 3 {
+			rows = rows[:3]
+		}
+
+		page, next := timelinePage(rows, 2, s)
+		if len(page) > 2 {
+			t.Fatalf("page of %d entries, limit 2", len(page))
+		}
+		for _, e := range page {
+			seen = append(seen, e.ID)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	want := []string{"e-1", "m-2", "e-3", "m-1", "e-2", "e-4", "m-3"}
+	if strings.Join(seen, ",") != strings.Join(want, ",") {
+		t.Errorf("paged %v, want %v", seen, want)
+	}
+}
@@ -11,7 +11,7 @@ import (
 )
 
 type UserRepository struct {
-	db *sqlx.DB
+	db DBTX
 }
 
 func NewUserRepository(db *sqlx.DB) *UserRepository {
@@ -26,10 +26,19 @@ func (r *UserRepository) Create(ctx context.Context, user *model.User) error {
 	query := `INSERT INTO users (id, tenant_id, email, password, name, role, created_at, updated_at)
 			  VALUES (:id, :tenant_id, :email, :password, :name, :role, :created_at, :updated_at)`
 
-	_, err := r.db.NamedExecContext(ctx, query, user)
+	_, err := scoped(r.db, user.TenantID).exec(ctx, query, map[string]interface{}{
+		"id":         user.ID,
+		"email":      user.Email,
+		"password":   user.Password,
+		"name":       user.Name,
+		"role":       user.Role,
+		"created_at": user.CreatedAt,
+		"updated_at": user.UpdatedAt,
+	})
 	return err
 }
 
+// GetByEmail finds the user logging in; it is unscoped because the user determines the tenant
 func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
 	var user model.User
 	query := `SELECT * FROM users WHERE email = ?`
@@ -41,7 +50,19 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*model.U
 	return &user, nil
 }
 
-func (r *UserRepository) GetByID(ctx context.Context, id string) (*model.User, error) {
+func (r *UserRepository) GetByID(ctx context.Context, id, tenantID string) (*model.User, error) {
+	var user model.User
+	query := `SELECT * FROM users WHERE id = :id AND tenant_id = :tenant_id`
+	err := scoped(r.db, tenantID).get(ctx, &user, query, map[string]interface{}{"id": id})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetForSession loads the user a refresh token was issued to. It is unscoped because the
+// session, not the request, identifies the user and with it the tenant.
+func (r *UserRepository) GetForSession(ctx context.Context, id string) (*model.User, error) {
 	var user model.User
 	query := `SELECT * FROM users WHERE id = ?`
 	query = r.db.Rebind(query)
@@ -54,22 +75,48 @@ func (r *UserRepository) GetByID(ctx context.Context, id string) (*model.User, e
 
 func (r *UserRepository) GetByTenantID(ctx context.Context, tenantID string) ([]model.User, error) {
 	var users []model.User
-	query := `SELECT * FROM users WHERE tenant_id = ? ORDER BY created_at DESC`
-	query = r.db.Rebind(query)
-	err := r.db.SelectContext(ctx, &users, query, tenantID)
+	query := `SELECT * FROM users WHERE tenant_id = :tenant_id ORDER BY created_at DESC`
+	err := scoped(r.db, tenantID).selectAll(ctx, &users, query, nil)
 	return users, err
 }
 
-func (r *UserRepository) Update(ctx context.Context, user *model.User) error {
+// Update saves email, name and role of a user of the tenant
+func (r *UserRepository) Update(ctx context.Context, tenantID string, user *model.User) error {
 	user.UpdatedAt = time.Now()
-	query := `UPDATE users SET email = :email, name = :name, role = :role, updated_at = :updated_at WHERE id = :id`
-	_, err := r.db.NamedExecContext(ctx, query, user)
-	return err
+	query := `UPDATE users SET email = :email, name = :name, role = :role, updated_at = :updated_at WHERE id = :id AND tenant_id = :tenant_id`
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{
+		"id":         user.ID,
+		"email":      user.Email,
+		"name":       user.Name,
+		"role":       user.Role,
+		"updated_at": user.UpdatedAt,
+	})
 }
 
-func (r *UserRepository) Delete(ctx context.Context, id string) error {
-	query := `DELETE FROM users WHERE id = ?`
-	query = r.db.Rebind(query)
-	_, err := r.db.ExecContext(ctx, query, id)
+func (r *UserRepository) Delete(ctx context.Context, id, tenantID string) error {
+	query := `DELETE FROM users WHERE id = :id AND tenant_id = :tenant_id`
+	return scoped(r.db, tenantID).execOne(ctx, query, map[string]interface{}{"id": id})
+}
+
+// CountByRole returns how many users of the tenant hold role
+func (r *UserRepository) CountByRole(ctx context.Context, tenantID, role string) (int, error) {
+	var n int
+	query := `SELECT COUNT(*) FROM users WHERE tenant_id = :tenant_id AND role = :role`
+	err := scoped(r.db, tenantID).get(ctx, &n, query, map[string]interface{}{"role": role})
+	return n, err
+}
+
+// ListIDsByTenant returns the ids of every user of the tenant
+func (r *UserRepository) ListIDsByTenant(ctx context.Context, tenantID string) ([]string, error) {
+	ids := []string{}
+	query := `SELECT id FROM users WHERE tenant_id = :tenant_id`
+	err := scoped(r.db, tenantID).selectAll(ctx, &ids, query, nil)
+	return ids, err
+}
+
+// SetMaxConversations stores the agent's auto-assignment capacity
+func (r *UserRepository) SetMaxConversations(ctx context.Context, id, tenantID string, max int) error {
+	query := `UPDATE users SET max_conversations = :max, updated_at = :updated_at WHERE id = :id AND tenant_id = :tenant_id`
+	_, err := scoped(r.db, tenantID).exec(ctx, query, map[string]interface{}{"id": id, "max": max, "updated_at": time.Now()})
 	return err
 }
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	"backend/internal/logging"
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+// AssignmentService picks an agent for newly created conversations according to the tenant strategy
+type AssignmentService struct {
+	assignRepo *repository.AssignmentRepository
+	convRepo   *repository.ConversationRepository
+	userRepo   *repository.UserRepository
+	eventRepo  *repository.EventRepository
+	store      *repository.Store
+	presence   *PresenceService
+	roles      *RoleService
+}
+
+func NewAssignmentService(
+	assignRepo *repository.AssignmentRepository,
+	convRepo *repository.ConversationRepository,
+	userRepo *repository.UserRepository,
+	eventRepo *repository.EventRepository,
+	store *repository.Store,
+	presence *PresenceService,
+	roles *RoleService,
+) *AssignmentService {
+	return &AssignmentService{
+		assignRepo: assignRepo,
+		convRepo:   convRepo,
+		userRepo:   userRepo,
+		eventRepo:  eventRepo,
+		store:      store,
+		presence:   presence,
+		roles:      roles,
+	}
+}
+
+func (s *AssignmentService) GetSettings(ctx context.Context, tenantID string) (*model.AssignmentSettings, error) {
+	return s.assignRepo.GetSettings(ctx, tenantID)
+}
+
+func (s *AssignmentService) UpdateSettings(ctx context.Context, tenantID, userID string, req model.UpdateAssignmentSettingsRequest) (*model.AssignmentSettings, error) {
+	settings, err := s.assignRepo.GetSettings(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Enabled != nil {
+		settings.Enabled = *req.Enabled
+	}
+	if req.Strategy != "" {
+		settings.Strategy = req.Strategy
+	}
+	if req.MaxOpenPerAgent != nil {
+		settings.MaxOpenPerAgent = *req.MaxOpenPerAgent
+	}
+	if req.RequireOnline != nil {
+		settings.RequireOnline = *req.RequireOnline
+	}
+
+	err = s.assignRepo.UpsertSettings(ctx, tenantID, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logEvent(ctx, tenantID, "assignment.settings_updated", "tenant", tenantID, userID, settings)
+	return settings, nil
+}
+
+func (s *AssignmentService) GetAgentChannels(ctx context.Context, agentID, tenantID string) ([]string, error) {
+	if err := s.ensureAgent(ctx, agentID, tenantID); err != nil {
+		return nil, err
+	}
+	return s.assignRepo.GetAgentChannels(ctx, agentID, tenantID)
+}
+
+func (s *AssignmentService) SetAgentChannels(ctx context.Context, agentID, tenantID string, channels []string) error {
+	if err := s.ensureAgent(ctx, agentID, tenantID); err != nil {
+		return err
+	}
+	return s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		return tx.Assignment.SetAgentChannels(ctx, agentID, tenantID, channels)
+	})
+}
+
+func (s *AssignmentService) ensureAgent(ctx context.Context, agentID, tenantID string) error {
+	if _, err := s.userRepo.GetByID(ctx, agentID, tenantID); err != nil {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// AutoAssign assigns the conversation to an agent when auto-assignment is enabled for the tenant.
+// It is a no-op when disabled, when no agent has capacity or when an agent claimed the
+// conversation first; conv is updated in place on success. The tenant's settings row stays
+// locked from reading the round-robin cursor and agent loads until the assignment commits, so
+// concurrent conversations neither pick the same agent nor overfill one.
+func (s *AssignmentService) AutoAssign(ctx context.Context, conv *model.Conversation) error {
+	var agent *model.AgentLoad
+	err := s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		settings, err := tx.Assignment.LockSettings(ctx, conv.TenantID)
+		if err != nil {
+			return err
+		}
+		if !settings.Enabled {
+			return nil
+		}
+
+		roles, err := s.roles.RolesWith(ctx, conv.TenantID, model.PermConversationReply)
+		if err != nil {
+			return err
+		}
+		candidates, err := tx.Assignment.ListCandidates(ctx, conv.TenantID, conv.Channel, roles)
+		if err != nil {
+			return err
+		}
+		if settings.RequireOnline {
+			candidates = s.onlineOnly(ctx, conv.TenantID, candidates)
+		}
+
+		agent = pickAgent(settings.Strategy, candidates, settings.LastAssignedAgentID.String, settings.MaxOpenPerAgent)
+		if agent == nil {
+			return skipAutoAssign(ctx, tx, conv, settings.Strategy, "no available agent")
+		}
+
+		err = tx.Conversations.AssignUnassigned(ctx, conv.ID, conv.TenantID, agent.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			agent = nil
+			return skipAutoAssign(ctx, tx, conv, settings.Strategy, "already assigned")
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Assignment.SetLastAssigned(ctx, conv.TenantID, agent.ID); err != nil {
+			return err
+		}
+		after := *conv
+		after.AssignedAgentID = sql.NullString{String: agent.ID, Valid: true}
+		if err := tx.Events.LogChange(ctx, conv.TenantID, "conversation.assigned", "conversation", conv.ID, "", map[string]interface{}{
+			"agent_id":           agent.ID,
+			"strategy":           settings.Strategy,
+			"open_conversations": agent.OpenConversations,
+			"auto":               true,
+		}, conv, &after); err != nil {
+			return err
+		}
+		return tx.Outbox.Enqueue(ctx, conv.TenantID, "conversation.events", "conversation.assigned", map[string]string{
+			"tenant_id":       conv.TenantID,
+			"conversation_id": conv.ID,
+			"agent_id":        agent.ID,
+		})
+	})
+	if err != nil || agent == nil {
+		return err
+	}
+
+	conv.Status = "assigned"
+	conv.AssignedAgentID = sql.NullString{String: agent.ID, Valid: true}
+	conv.AssignedAgentName = agent.Name
+
+	return nil
+}
+
+// skipAutoAssign records why a conversation was left unassigned
+func skipAutoAssign(ctx context.Context, tx *repository.Tx, conv *model.Conversation, strategy, reason string) error {
+	return tx.Events.LogEvent(ctx, conv.TenantID, "conversation.auto_assign_skipped", "conversation", conv.ID, "", map[string]string{
+		"strategy": strategy,
+		"reason":   reason,
+	})
+}
+
+// onlineOnly drops candidates whose presence is away or offline
+func (s *AssignmentService) onlineOnly(ctx context.Context, tenantID string, candidates []model.AgentLoad) []model.AgentLoad {
+	ids := make([]string, len(candidates))
+	for i, c := range candidates {
+		ids[i] = c.ID
+	}
+	available := s.presence.Available(ctx, tenantID, ids)
+	online := make([]model.AgentLoad, 0, len(candidates))
+	for _, c := range candidates {
+		if available[c.ID] {
+			online = append(online, c)
+		}
+	}
+	return online
+}
+
+// pickAgent selects an agent from candidates (ordered by id) according to strategy.
+// Agents at or above the lower of their own max_conversations and maxOpen are skipped, so a
+// capacity stored before the tenant limit was lowered cannot exceed it; 0 means no limit.
+func pickAgent(strategy string, candidates []model.AgentLoad, lastAgentID string, maxOpen int) *model.AgentLoad {
+	available := make([]model.AgentLoad, 0, len(candidates))
+	for _, c := range candidates {
+		limit := maxOpen
+		if c.MaxConversations > 0 && (limit == 0 || c.MaxConversations < limit) {
+			limit = c.MaxConversations
+		}
+		if limit > 0 && c.OpenConversations >= limit {
+			continue
+		}
+		available = append(available, c)
+	}
+	if len(available) == 0 {
+		return nil
+	}
+
+	switch strategy {
+	case "least_open":
+		return leastOpen(available)
+	case "channel_match":
+		matched := make([]model.AgentLoad, 0, len(available))
+		for _, c := range available {
+			if c.ChannelMatch {
+				matched = append(matched, c)
+			}
+		}
+		// fall back to the whole pool when nobody is skilled for the channel
+		if len(matched) == 0 {
+			return leastOpen(available)
+		}
+		return leastOpen(matched)
+	default:
+		for i := range available {
+			if available[i].ID > lastAgentID {
+				return &available[i]
+			}
+		}
+		return &available[0]
+	}
+}
+
+func leastOpen(agents []model.AgentLoad) *model.AgentLoad {
+	best := &agents[0]
+	for i := range agents {
+		if agents[i].OpenConversations < best.OpenConversations {
+			best = &agents[i]
+		}
+	}
+	return best
+}
+
+func (s *AssignmentService) logEvent(ctx context.Context, tenantID, eventType, entityType, entityID, userID string, data interface{}) {
+	err := s.eventRepo.LogEvent(ctx, tenantID, eventType, entityType, entityID, userID, data)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to log event", "event_type", eventType, logging.Err(err))
+	}
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"testing"
+
+	"backend/internal/model"
+)
+
+func TestPickAgent(t *testing.T) {
+	agents := func(loads ...model.AgentLoad) []model.AgentLoad { return loads }
+	a := model.AgentLoad{ID: "a", OpenConversations: 2}
+	b := model.AgentLoad{ID: "b", OpenConversations: 1}
+	c := model.AgentLoad{ID: "c", OpenConversations: 3}
+
+	cases := []struct {
+		name       string
+		strategy   string
+		candidates []model.AgentLoad
+		last       string
+		maxOpen    int
+		want       string // "" means nobody
+	}{
+		{name: "no candidates", strategy: "round_robin", want: ""},
+		{name: "round robin starts at the first agent", strategy: "round_robin", candidates: agents(a, b, c), want: "a"},
+		{name: "round robin takes the next id", strategy: "round_robin", candidates: agents(a, b, c), last: "a", want: "b"},
+		{name: "round robin wraps around", strategy: "round_robin", candidates: agents(a, b, c), last: "c", want: "a"},
+		{name: "round robin continues after a removed agent", strategy: "round_robin", candidates: agents(a, c), last: "b", want: "c"},
+		{name: "round robin skips agents at the tenant limit", strategy: "round_robin", candidates: agents(a, b, c), last: "a", maxOpen: 1, want: ""},
+		{name: "round robin skips full agents and wraps", strategy: "round_robin", candidates: agents(a, b, c), last: "b", maxOpen: 3, want: "a"},
+		{name: "unknown strategy is round robin", strategy: "bogus", candidates: agents(a, b), last: "a", want: "b"},
+
+		{name: "least open", strategy: "least_open", candidates: agents(a, b, c), want: "b"},
+		{name: "least open ties go to the lowest id", strategy: "least_open", candidates: agents(
+			model.AgentLoad{ID: "a", OpenConversations: 1}, b, model.AgentLoad{ID: "c", OpenConversations: 1}), want: "a"},
+		{name: "least open skips full agents", strategy: "least_open", candidates: agents(a, b, c), maxOpen: 2, want: "b"},
+
+		{name: "own capacity lowers the tenant limit", strategy: "least_open", candidates: agents(
+			model.AgentLoad{ID: "a", OpenConversations: 1, MaxConversations: 1},
+			model.AgentLoad{ID: "b", OpenConversations: 2}), maxOpen: 5, want: "b"},
+		{name: "own capacity cannot raise the tenant limit", strategy: "least_open", candidates: agents(
+			model.AgentLoad{ID: "a", OpenConversations: 2, MaxConversations: 10}), maxOpen: 2, want: ""},
+		{name: "own capacity applies without a tenant limit", strategy: "least_open", candidates: agents(
+			model.AgentLoad{ID: "a", OpenConversations: 4, MaxConversations: 4},
+			model.AgentLoad{ID: "b", OpenConversations: 9}), want: "b"},
+
+		{name: "channel match prefers skilled agents", strategy: "channel_match", candidates: agents(
+			a, model.AgentLoad{ID: "b", OpenConversations: 5, ChannelMatch: true}), want: "b"},
+		{name: "channel match picks the least open skilled agent", strategy: "channel_match", candidates: agents(
+			model.AgentLoad{ID: "a", OpenConversations: 3, ChannelMatch: true},
+			model.AgentLoad{ID: "b", OpenConversations: 0},
+			model.AgentLoad{ID: "c", OpenConversations: 1, ChannelMatch: true}), want: "c"},
+		{name: "channel match falls back to everyone", strategy: "channel_match", candidates: agents(a, b, c), want: "b"},
+		{name: "channel match falls back when skilled agents are full", strategy: "channel_match", candidates: agents(
+			model.AgentLoad{ID: "a", OpenConversations: 2, ChannelMatch: true}, b), maxOpen: 2, want: "b"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := pickAgent(tc.strategy, tc.candidates, tc.last, tc.maxOpen)
+			switch {
+			case tc.want == "" && got != nil:
+				t.Errorf("picked %q, want nobody", got.ID)
+			case tc.want != "" && got == nil:
+				t.Errorf("picked nobody, want %q", tc.want)
+			case got != nil && got.ID != tc.want:
+				t.Errorf("picked %q, want %q", got.ID, tc.want)
+			}
+		})
+	}
+}
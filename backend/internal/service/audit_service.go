@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+// AuditService answers "who changed what" from the events every service writes
+type AuditService struct {
+	auditRepo *repository.AuditRepository
+}
+
+func NewAuditService(auditRepo *repository.AuditRepository) *AuditService {
+	return &AuditService{auditRepo: auditRepo}
+}
+
+// List returns one keyset page of the tenant's audit log, newest first by default
+func (s *AuditService) List(ctx context.Context, tenantID string, filter model.AuditFilter) ([]model.AuditEntry, *model.CursorMeta, error) {
+	entries, next, err := s.auditRepo.List(ctx, tenantID, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	return entries, &model.CursorMeta{PerPage: filter.PerPage, NextCursor: next, HasMore: next != ""}, nil
+}
+
+// Export passes every entry matching filter to fn; cursor and page size are ignored
+func (s *AuditService) Export(ctx context.Context, tenantID string, filter model.AuditFilter, fn func(*model.AuditEntry) error) error {
+	filter.Cursor = ""
+	return s.auditRepo.Export(ctx, tenantID, filter, fn)
+}
@@ -5,25 +5,27 @@ import (
 	"errors"
 	"time"
 
+	"backend/internal/logging"
 	"backend/internal/model"
 
 	"backend/internal/repository"
 
-	"log"
+	"log/slog"
 
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthService struct {
-	userRepo  *repository.UserRepository
-	jwtSecret string
+	userRepo *repository.UserRepository
+	tokens   *TokenService
+	tenants  *TenantService
 }
 
-func NewAuthService(userRepo *repository.UserRepository, jwtSecret string) *AuthService {
+func NewAuthService(userRepo *repository.UserRepository, tokens *TokenService, tenants *TenantService) *AuthService {
 	return &AuthService{
-		userRepo:  userRepo,
-		jwtSecret: jwtSecret,
+		userRepo: userRepo,
+		tokens:   tokens,
+		tenants:  tenants,
 	}
 }
 
@@ -35,60 +37,63 @@ func (s *AuthService) Login(ctx context.Context, req model.LoginRequest) (*model
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
 	if err != nil {
-		log.Printf("auth: bcrypt compare error for user %s: %v", req.Email, err)
+		slog.InfoContext(ctx, "auth: invalid password", "email", req.Email)
 		return nil, errors.New("invalid credentials")
 	}
 
-	token, err := s.generateToken(user)
+	if err := s.tenants.CheckActive(ctx, user.TenantID); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.tokens.Issue(ctx, user)
 	if err != nil {
+		slog.ErrorContext(ctx, "auth: failed to issue tokens", "target_user_id", user.ID, logging.Err(err))
 		return nil, errors.New("failed to generate token")
 	}
 
-	return &model.LoginResponse{
-		Token: token,
-		User:  *user,
-	}, nil
+	return resp, nil
 }
 
-func (s *AuthService) Register(ctx context.Context, req model.RegisterRequest) (*model.User, error) {
-	// Check if user exists
-	existingUser, _ := s.userRepo.GetByEmail(ctx, req.Email)
-	if existingUser != nil {
-		return nil, errors.New("user already exists")
+// Refresh exchanges a refresh token for a new token pair; the old refresh token is consumed.
+// Claims are rebuilt from the stored user so role changes apply immediately.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*model.LoginResponse, error) {
+	userID, err := s.tokens.Consume(ctx, refreshToken)
+	if err != nil {
+		if !errors.Is(err, ErrInvalidRefreshToken) {
+			slog.ErrorContext(ctx, "auth: refresh failed", logging.Err(err))
+		}
+		return nil, ErrInvalidRefreshToken
 	}
 
-	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	user, err := s.userRepo.GetForSession(ctx, userID)
 	if err != nil {
-		return nil, errors.New("failed to hash password")
+		return nil, ErrInvalidRefreshToken
 	}
 
-	user := &model.User{
-		TenantID: req.TenantID,
-		Email:    req.Email,
-		Password: string(hashedPassword),
-		Name:     req.Name,
-		Role:     req.Role,
+	if err := s.tenants.CheckActive(ctx, user.TenantID); err != nil {
+		return nil, err
 	}
 
-	err = s.userRepo.Create(ctx, user)
+	resp, err := s.tokens.Issue(ctx, user)
 	if err != nil {
-		return nil, err
+		slog.ErrorContext(ctx, "auth: failed to issue tokens", "target_user_id", user.ID, logging.Err(err))
+		return nil, errors.New("failed to generate token")
 	}
-
-	return user, nil
+	return resp, nil
 }
 
-func (s *AuthService) generateToken(user *model.User) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id":   user.ID,
-		"tenant_id": user.TenantID,
-		"email":     user.Email,
-		"role":      user.Role,
-		"exp":       time.Now().Add(24 * time.Hour).Unix(),
-		"iat":       time.Now().Unix(),
+// Logout revokes the calling access token and, when given, the refresh token
+func (s *AuthService) Logout(ctx context.Context, jti string, expiresAt time.Time, refreshToken string) error {
+	if err := s.tokens.RevokeAccess(ctx, jti, expiresAt); err != nil {
+		return err
 	}
+	if refreshToken != "" {
+		return s.tokens.RevokeRefresh(ctx, refreshToken)
+	}
+	return nil
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.jwtSecret))
+// Register creates a user from an invite
+func (s *AuthService) Register(ctx context.Context, req model.RegisterRequest) (*model.User, error) {
+	return s.tenants.AcceptInvite(ctx, req)
 }
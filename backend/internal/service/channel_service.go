@@ -1,35 +1,243 @@
 package service
 
 import (
+	"backend/internal/connector"
+	"backend/internal/logging"
 	"backend/internal/model"
 	"backend/internal/repository"
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// webhookTolerance bounds how far a signed webhook timestamp may drift from now
+const webhookTolerance = 5 * time.Minute
+
+var (
+	ErrWebhookUnauthorized = errors.New("webhook signature verification failed")
+	ErrChannelNotFound     = errors.New("channel not found")
+	ErrInvalidOutboundURL  = errors.New("invalid outbound url")
 )
 
 type ChannelService struct {
-	repo *repository.ChannelRepository
+	repo      *repository.ChannelRepository
+	eventRepo *repository.EventRepository
+	store     *repository.Store
+	redis     *redis.Client
 }
 
-func NewChannelService(r *repository.ChannelRepository) *ChannelService {
-	return &ChannelService{repo: r}
+func NewChannelService(r *repository.ChannelRepository, eventRepo *repository.EventRepository, store *repository.Store, redis *redis.Client) *ChannelService {
+	return &ChannelService{repo: r, eventRepo: eventRepo, store: store, redis: redis}
 }
 
-func (s *ChannelService) Create(ctx context.Context, ch *model.Channel) error {
-	return s.repo.Create(ctx, ch)
+func (s *ChannelService) Create(ctx context.Context, ch *model.Channel, tenantID, userID string) error {
+	if err := checkOutboundURL(ctx, ch.OutboundURL); err != nil {
+		return err
+	}
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return err
+	}
+	ch.WebhookSecret = secret
+	err = s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Channels.Create(ctx, tenantID, ch); err != nil {
+			return err
+		}
+		return logChannelChange(ctx, tx, tenantID, "channel.created", ch.ID, userID, nil, ch)
+	})
+	if err != nil {
+		return err
+	}
+	ch.InboundURL = inboundURL(ch.Slug)
+	return nil
 }
 
-func (s *ChannelService) GetByID(ctx context.Context, id string) (*model.Channel, error) {
-	return s.repo.GetByID(ctx, id)
+func (s *ChannelService) GetByID(ctx context.Context, id, tenantID string) (*model.Channel, error) {
+	ch, err := s.repo.GetByID(ctx, id, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	ch.InboundURL = inboundURL(ch.Slug)
+	return ch, nil
+}
+
+func (s *ChannelService) List(ctx context.Context, tenantID string) ([]model.Channel, error) {
+	chs, err := s.repo.List(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range chs {
+		chs[i].InboundURL = inboundURL(chs[i].Slug)
+	}
+	return chs, nil
+}
+
+// Update changes name, slug, description and outbound URL of a channel of the tenant
+func (s *ChannelService) Update(ctx context.Context, ch *model.Channel, tenantID, userID string) error {
+	if err := checkOutboundURL(ctx, ch.OutboundURL); err != nil {
+		return err
+	}
+	before, err := s.repo.GetByID(ctx, ch.ID, tenantID)
+	if err != nil {
+		return orNotFound(err, ErrChannelNotFound)
+	}
+	ch.TenantID = tenantID
+	return s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Channels.Update(ctx, tenantID, ch); err != nil {
+			return orNotFound(err, ErrChannelNotFound)
+		}
+		after := *before
+		after.Name, after.Slug, after.Description, after.OutboundURL = ch.Name, ch.Slug, ch.Description, ch.OutboundURL
+		after.UpdatedAt = ch.UpdatedAt
+		return logChannelChange(ctx, tx, tenantID, "channel.updated", ch.ID, userID, before, &after)
+	})
+}
+
+func (s *ChannelService) Delete(ctx context.Context, id, tenantID, userID string) error {
+	before, err := s.repo.GetByID(ctx, id, tenantID)
+	if err != nil {
+		return orNotFound(err, ErrChannelNotFound)
+	}
+	return s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Channels.Delete(ctx, id, tenantID); err != nil {
+			return orNotFound(err, ErrChannelNotFound)
+		}
+		return logChannelChange(ctx, tx, tenantID, "channel.deleted", id, userID, before, nil)
+	})
+}
+
+// logChannelChange records the audit event of a change in its transaction, without the webhook secret
+func logChannelChange(ctx context.Context, tx *repository.Tx, tenantID, eventType, channelID, userID string, before, after *model.Channel) error {
+	redact := func(ch *model.Channel) *model.Channel {
+		if ch == nil {
+			return nil
+		}
+		c := *ch
+		c.WebhookSecret = ""
+		return &c
+	}
+	return tx.Events.LogChange(ctx, tenantID, eventType, "channel", channelID, userID, nil, redact(before), redact(after))
+}
+
+// RotateSecret issues a new webhook secret for a channel of the tenant; the old one stops working immediately
+func (s *ChannelService) RotateSecret(ctx context.Context, id, tenantID, userID string) (*model.Channel, error) {
+	ch, err := s.repo.GetByID(ctx, id, tenantID)
+	if err != nil {
+		return nil, orNotFound(err, ErrChannelNotFound)
+	}
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	err = s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Channels.UpdateSecret(ctx, id, tenantID, secret); err != nil {
+			return orNotFound(err, ErrChannelNotFound)
+		}
+		return tx.Events.LogEvent(ctx, tenantID, "channel.secret_rotated", "channel", id, userID, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	ch.WebhookSecret = secret
+	ch.InboundURL = inboundURL(ch.Slug)
+	return ch, nil
+}
+
+// VerifyWebhook authenticates an inbound webhook for the channel identified by slug.
+// The signature is hex(HMAC-SHA256(secret, timestamp + "." + body)), optionally prefixed
+// with "sha256="; timestamps outside webhookTolerance and replayed signatures are rejected.
+func (s *ChannelService) VerifyWebhook(ctx context.Context, slug, timestamp, signature, remoteAddr string, body []byte) (*model.Channel, error) {
+	ch, err := s.repo.GetBySlugWithSecret(ctx, slug)
+	if err != nil {
+		slog.WarnContext(ctx, "webhook: rejected request for unknown channel", "channel", slug, "remote_addr", remoteAddr)
+		return nil, ErrWebhookUnauthorized
+	}
+
+	if reason := s.checkSignature(ctx, ch.WebhookSecret, timestamp, signature, body); reason != "" {
+		if err := s.eventRepo.LogEvent(ctx, ch.TenantID, "webhook.rejected", "channel", ch.ID, "", map[string]string{
+			"reason":      reason,
+			"remote_addr": remoteAddr,
+		}); err != nil {
+			slog.ErrorContext(ctx, "failed to log event", logging.Err(err))
+		}
+		return nil, ErrWebhookUnauthorized
+	}
+
+	ch.WebhookSecret = ""
+	return ch, nil
+}
+
+// checkSignature returns an empty string when valid, otherwise the rejection reason
+func (s *ChannelService) checkSignature(ctx context.Context, secret, timestamp, signature string, body []byte) string {
+	if secret == "" {
+		return "channel has no webhook secret"
+	}
+	if timestamp == "" || signature == "" {
+		return "missing signature headers"
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "invalid timestamp"
+	}
+	drift := time.Since(time.Unix(ts, 0))
+	if drift > webhookTolerance || drift < -webhookTolerance {
+		return "timestamp outside tolerance"
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || !hmac.Equal(got, expected) {
+		return "signature mismatch"
+	}
+
+	// A valid signature may only be used once within the tolerance window. Without Redis the
+	// guard is skipped (logged) so inbound messages keep flowing, like the revocation check.
+	if s.redis != nil {
+		ok, err := s.redis.SetNX(ctx, "webhook:sig:"+hex.EncodeToString(expected), 1, 2*webhookTolerance).Result()
+		if err != nil {
+			slog.WarnContext(ctx, "webhook: replay check skipped", logging.Err(err))
+		} else if !ok {
+			return "replayed signature"
+		}
+	}
+
+	return ""
 }
 
-func (s *ChannelService) List(ctx context.Context) ([]model.Channel, error) {
-	return s.repo.List(ctx)
+// checkOutboundURL accepts an empty URL (no outbound delivery) or a public https endpoint
+func checkOutboundURL(ctx context.Context, raw string) error {
+	if raw == "" {
+		return nil
+	}
+	if err := connector.ValidateURL(ctx, raw); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOutboundURL, err)
+	}
+	return nil
 }
 
-func (s *ChannelService) Update(ctx context.Context, ch *model.Channel) error {
-	return s.repo.Update(ctx, ch)
+func inboundURL(slug string) string {
+	return "/api/v1/channel/webhook/" + slug
 }
 
-func (s *ChannelService) Delete(ctx context.Context, id string) error {
-	return s.repo.Delete(ctx, id)
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
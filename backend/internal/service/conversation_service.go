@@ -2,119 +2,255 @@ package service
 
 import (
 	"context"
-	"encoding/json"
+	"database/sql"
 	"errors"
-	"log"
+	"log/slog"
+	"time"
 
+	"backend/internal/logging"
 	"backend/internal/model"
 	"backend/internal/repository"
 
-	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/redis/go-redis/v9"
 )
 
+// ErrIdempotencyInProgress is returned while another request with the same key is still being processed
+var ErrIdempotencyInProgress = errors.New("a request with this idempotency key is still in progress")
+
+// ErrIdempotencyKeyTooLong rejects keys that do not fit the idempotency_keys table
+var ErrIdempotencyKeyTooLong = errors.New("idempotency key must be at most 255 characters")
+
+var (
+	ErrMessageNotFound    = errors.New("message not found")
+	ErrMessageNotOwned    = errors.New("only your own messages can be changed")
+	ErrMessageEditExpired = errors.New("the edit window for this message has passed")
+	ErrAgentNotFound      = errors.New("agent not found")
+	ErrCustomerNotFound   = errors.New("customer not found")
+)
+
+// orNotFound reports a write that matched no row, such as one aimed at another tenant, as notFound
+func orNotFound(err, notFound error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFound
+	}
+	return err
+}
+
+// WebhookResult is the outcome of an inbound webhook; Duplicate is set when the provider
+// message id was already ingested and the original conversation/message are returned
+type WebhookResult struct {
+	Conversation *model.Conversation
+	Message      *model.Message
+	Duplicate    bool
+}
+
 type ConversationService struct {
-	convRepo     *repository.ConversationRepository
-	msgRepo      *repository.MessageRepository
-	customerRepo *repository.CustomerRepository
-	eventRepo    *repository.EventRepository
-	ticketRepo   *repository.TicketRepository
-	redis        *redis.Client
-	rabbitCh     *amqp.Channel
+	convRepo   *repository.ConversationRepository
+	msgRepo    *repository.MessageRepository
+	ticketRepo *repository.TicketRepository
+	idemRepo   *repository.IdempotencyRepository
+	store      *repository.Store
+	assignSvc  *AssignmentService
+	slaSvc     *SLAService
+	quota      *QuotaService
+	redis      *redis.Client
+
+	// editWindow is how long after sending an agent may edit or delete their own message
+	editWindow time.Duration
 }
 
 func NewConversationService(
 	convRepo *repository.ConversationRepository,
 	msgRepo *repository.MessageRepository,
-	customerRepo *repository.CustomerRepository,
-	eventRepo *repository.EventRepository,
 	ticketRepo *repository.TicketRepository,
+	idemRepo *repository.IdempotencyRepository,
+	store *repository.Store,
+	assignSvc *AssignmentService,
+	slaSvc *SLAService,
+	quota *QuotaService,
 	redis *redis.Client,
-	rabbitCh *amqp.Channel,
+	editWindow time.Duration,
 ) *ConversationService {
 	return &ConversationService{
-		convRepo:     convRepo,
-		msgRepo:      msgRepo,
-		customerRepo: customerRepo,
-		eventRepo:    eventRepo,
-		ticketRepo:   ticketRepo,
-		redis:        redis,
-		rabbitCh:     rabbitCh,
+		convRepo:   convRepo,
+		msgRepo:    msgRepo,
+		ticketRepo: ticketRepo,
+		idemRepo:   idemRepo,
+		store:      store,
+		assignSvc:  assignSvc,
+		slaSvc:     slaSvc,
+		quota:      quota,
+		redis:      redis,
+		editWindow: editWindow,
 	}
 }
 
-func (s *ConversationService) HandleWebhook(ctx context.Context, req model.WebhookRequest) (*model.Conversation, error) {
-	// Get or create customer
+func (s *ConversationService) HandleWebhook(ctx context.Context, req model.WebhookRequest) (*WebhookResult, error) {
 	channel := req.Channel
 	if channel == "" {
 		channel = "unknown"
 	}
 
-	customer, err := s.customerRepo.GetOrCreate(ctx, req.CustomerExternalID, req.TenantID, channel)
-	if err != nil {
-		return nil, err
+	if req.ExternalMessageID == "" {
+		conv, msg, err := s.ingestWebhook(ctx, req, channel, "")
+		if err != nil {
+			return nil, err
+		}
+		return &WebhookResult{Conversation: conv, Message: msg}, nil
 	}
-	// removed debug logging
 
-	// Find existing open conversation or create new one
-	conv, err := s.convRepo.GetByCustomerAndTenant(ctx, customer.ID, req.TenantID)
+	// Providers retry deliveries: the external message id is unique per tenant and channel
+	scope := "webhook:" + channel
+	existing, err := s.idemRepo.Reserve(ctx, req.TenantID, scope, req.ExternalMessageID)
 	if err != nil {
-		// Create new conversation
-		conv = &model.Conversation{
-			TenantID:   req.TenantID,
-			CustomerID: customer.ID,
-			Channel:    channel,
+		return nil, err
+	}
+	if existing == nil {
+		// The key expires before the message does: a late retry finds the stored message instead
+		stored, err := s.msgRepo.GetByExternalID(ctx, req.TenantID, channel, req.ExternalMessageID)
+		switch {
+		case err == nil:
+			existing = &model.IdempotencyKey{
+				TenantID:       req.TenantID,
+				ConversationID: sql.NullString{String: stored.ConversationID, Valid: true},
+				MessageID:      sql.NullString{String: stored.ID, Valid: true},
+			}
+			if err := s.idemRepo.Complete(ctx, req.TenantID, scope, req.ExternalMessageID, stored.ConversationID, stored.ID); err != nil {
+				return nil, err
+			}
+		case !errors.Is(err, sql.ErrNoRows):
+			s.releaseIdempotencyKey(ctx, req.TenantID, scope, req.ExternalMessageID)
+			return nil, err
 		}
-		err = s.convRepo.Create(ctx, conv)
+	}
+	if existing != nil {
+		conv, msg, err := s.replay(ctx, existing)
 		if err != nil {
 			return nil, err
 		}
-
-		// Log event
-		s.logEvent(ctx, req.TenantID, "conversation.created", "conversation", conv.ID, "", conv)
+		return &WebhookResult{Conversation: conv, Message: msg, Duplicate: true}, nil
 	}
 
-	// Create message
-	msg := &model.Message{
-		ConversationID: conv.ID,
-		SenderType:     "customer",
-		SenderID:       customer.ID,
-		SenderName:     customer.Name,
-		Message:        req.Message,
-	}
-	err = s.msgRepo.Create(ctx, msg)
+	conv, msg, err := s.ingestWebhook(ctx, req, channel, scope)
 	if err != nil {
+		s.releaseIdempotencyKey(ctx, req.TenantID, scope, req.ExternalMessageID)
 		return nil, err
 	}
+	return &WebhookResult{Conversation: conv, Message: msg}, nil
+}
 
-	// Update conversation last message time
-	s.convRepo.UpdateLastMessage(ctx, conv.ID)
+// ingestWebhook stores the customer message; when idemScope is set the external message id
+// is completed as an idempotency key in the same transaction
+func (s *ConversationService) ingestWebhook(ctx context.Context, req model.WebhookRequest, channel, idemScope string) (*model.Conversation, *model.Message, error) {
+	// Replays of accepted messages are answered before this point and are not counted again
+	releaseQuota, err := s.quota.ReserveMessage(ctx, req.TenantID)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	// Invalidate cache
-	s.invalidateConversationCache(ctx, req.TenantID)
+	var conv *model.Conversation
+	var msg *model.Message
+	created := false
 
-	// Log event
-	s.logEvent(ctx, req.TenantID, "message.received", "conversation", conv.ID, "", msg)
+	err = s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		// Get or create customer
+		customer, err := tx.Customers.GetOrCreate(ctx, req.CustomerExternalID, req.TenantID, channel)
+		if err != nil {
+			return err
+		}
 
-	// Publish to message queue for realtime delivery
-	go func(m *model.Message, tenant string) {
-		payload := map[string]interface{}{
-			"tenant_id":       tenant,
-			"conversation_id": m.ConversationID,
-			"message_id":      m.ID,
-			"sender_id":       m.SenderID,
-			"sender_name":     m.SenderName,
-			"sender_type":     m.SenderType,
-			"message":         m.Message,
-			"created_at":      m.CreatedAt,
+		// Find existing open conversation or create new one
+		conv, err = tx.Conversations.GetByCustomerAndTenant(ctx, customer.ID, req.TenantID)
+		if err != nil {
+			conv = &model.Conversation{
+				TenantID:   req.TenantID,
+				CustomerID: customer.ID,
+				Channel:    channel,
+			}
+			if err := tx.Conversations.Create(ctx, conv); err != nil {
+				return err
+			}
+			if err := tx.Events.LogChange(ctx, req.TenantID, "conversation.created", "conversation", conv.ID, "", conv, nil, conv); err != nil {
+				return err
+			}
+			created = true
 		}
-		s.publishEvent(context.Background(), "conversation.events", "message.received", payload)
-	}(msg, req.TenantID)
 
-	return conv, nil
+		msg = &model.Message{
+			ConversationID: conv.ID,
+			SenderType:     "customer",
+			SenderID:       customer.ID,
+			SenderName:     customer.Name,
+			Message:        req.Message,
+
+			ExternalMessageID: req.ExternalMessageID,
+		}
+		if err := tx.Messages.Create(ctx, req.TenantID, msg); err != nil {
+			return err
+		}
+		if err := tx.Conversations.UpdateLastMessage(ctx, conv.ID, req.TenantID); err != nil {
+			return err
+		}
+
+		if err := tx.Events.LogChange(ctx, req.TenantID, "message.received", "conversation", conv.ID, "", msg, nil, msg); err != nil {
+			return err
+		}
+		if err := tx.Outbox.Enqueue(ctx, req.TenantID, "conversation.events", "message.received", messagePayload(req.TenantID, msg)); err != nil {
+			return err
+		}
+
+		if idemScope != "" {
+			return tx.Idempotency.Complete(ctx, req.TenantID, idemScope, req.ExternalMessageID, conv.ID, msg.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		releaseQuota()
+		return nil, nil, err
+	}
+
+	if created {
+		s.slaSvc.StartConversationClock(ctx, conv)
+		s.autoAssign(ctx, conv)
+	}
+	s.invalidateConversationCache(ctx, req.TenantID)
+
+	return conv, msg, nil
 }
 
-func (s *ConversationService) List(ctx context.Context, tenantID string, filter model.ConversationFilter) ([]model.Conversation, *model.PaginationMeta, error) {
+// replay loads the conversation and message recorded for an already used idempotency key
+func (s *ConversationService) replay(ctx context.Context, key *model.IdempotencyKey) (*model.Conversation, *model.Message, error) {
+	if !key.MessageID.Valid {
+		return nil, nil, ErrIdempotencyInProgress
+	}
+	conv, err := s.convRepo.GetByID(ctx, key.ConversationID.String, key.TenantID)
+	if err != nil {
+		return nil, nil, ErrConversationNotFound
+	}
+	msg, err := s.msgRepo.GetByID(ctx, key.MessageID.String, key.TenantID)
+	if err != nil {
+		return nil, nil, ErrMessageNotFound
+	}
+	return conv, msg, nil
+}
+
+func (s *ConversationService) releaseIdempotencyKey(ctx context.Context, tenantID, scope, key string) {
+	if err := s.idemRepo.Release(ctx, tenantID, scope, key); err != nil {
+		slog.ErrorContext(ctx, "failed to release idempotency key", logging.Err(err))
+	}
+}
+
+// List returns one page of conversations. Meta is a *model.CursorMeta when filter.CursorMode is set
+// and a *model.PaginationMeta (offset mode) otherwise.
+func (s *ConversationService) List(ctx context.Context, tenantID string, filter model.ConversationFilter) ([]model.Conversation, interface{}, error) {
+	if filter.CursorMode {
+		conversations, next, err := s.convRepo.ListAfter(ctx, tenantID, filter)
+		if err != nil {
+			return nil, nil, err
+		}
+		return conversations, &model.CursorMeta{PerPage: filter.PerPage, NextCursor: next, HasMore: next != ""}, nil
+	}
+
 	conversations, total, err := s.convRepo.List(ctx, tenantID, filter)
 	if err != nil {
 		return nil, nil, err
@@ -131,30 +267,163 @@ func (s *ConversationService) List(ctx context.Context, tenantID string, filter
 	return conversations, meta, nil
 }
 
-func (s *ConversationService) GetByID(ctx context.Context, id, tenantID string) (*model.Conversation, []model.Message, error) {
+// Message history page sizes; GetByID returns the latest defaultMessagePageSize messages
+const (
+	defaultMessagePageSize = 50
+	maxMessagePageSize     = 200
+)
+
+// GetByID returns the conversation with its most recent page of messages
+func (s *ConversationService) GetByID(ctx context.Context, id, tenantID string) (*model.Conversation, []model.Message, *model.MessagePageMeta, error) {
 	conv, err := s.convRepo.GetByID(ctx, id, tenantID)
 	if err != nil {
-		return nil, nil, errors.New("conversation not found")
+		return nil, nil, nil, ErrConversationNotFound
 	}
 
-	messages, err := s.msgRepo.GetByConversationID(ctx, id)
+	messages, meta, err := s.listMessages(ctx, id, tenantID, model.MessagePageQuery{})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return conv, messages, meta, nil
+}
+
+// ListMessages pages through the history of a conversation, oldest message first
+func (s *ConversationService) ListMessages(ctx context.Context, conversationID, tenantID string, q model.MessagePageQuery) ([]model.Message, *model.MessagePageMeta, error) {
+	if _, err := s.convRepo.GetByID(ctx, conversationID, tenantID); err != nil {
+		return nil, nil, ErrConversationNotFound
+	}
+	if q.Before != "" && q.After != "" {
+		return nil, nil, errors.New("use either before or after, not both")
+	}
+	return s.listMessages(ctx, conversationID, tenantID, q)
+}
+
+// MarkRead moves the agent's read marker to messageID, or to the latest message when empty.
+// Markers never move backwards; conversation.read is published when the marker advances.
+func (s *ConversationService) MarkRead(ctx context.Context, conversationID, tenantID, userID, messageID string) (*model.ConversationRead, error) {
+	if _, err := s.convRepo.GetByID(ctx, conversationID, tenantID); err != nil {
+		return nil, ErrConversationNotFound
+	}
+
+	read := &model.ConversationRead{ConversationID: conversationID, UserID: userID, LastReadAt: time.Now()}
+	if messageID != "" {
+		msg, err := s.msgRepo.GetByID(ctx, messageID, tenantID)
+		if err != nil || msg.ConversationID != conversationID {
+			return nil, ErrMessageNotFound
+		}
+		read.LastReadMessageID = msg.ID
+		read.LastReadAt = msg.CreatedAt
+	} else {
+		latest, _, err := s.msgRepo.ListPage(ctx, conversationID, tenantID, model.MessagePageQuery{Limit: 1})
+		if err != nil {
+			return nil, err
+		}
+		if len(latest) > 0 {
+			read.LastReadMessageID = latest[0].ID
+			read.LastReadAt = latest[0].CreatedAt
+		}
+	}
+
+	err := s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		advanced, err := tx.Conversations.MarkRead(ctx, tenantID, read)
+		if err != nil || !advanced {
+			return err
+		}
+		return tx.Outbox.Enqueue(ctx, tenantID, "conversation.events", "conversation.read", map[string]interface{}{
+			"tenant_id":            tenantID,
+			"conversation_id":      conversationID,
+			"user_id":              userID,
+			"last_read_message_id": read.LastReadMessageID,
+			"last_read_at":         read.LastReadAt,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return read, nil
+}
+
+// ListReads returns the read receipts of a conversation
+func (s *ConversationService) ListReads(ctx context.Context, conversationID, tenantID string) ([]model.ConversationRead, error) {
+	if _, err := s.convRepo.GetByID(ctx, conversationID, tenantID); err != nil {
+		return nil, ErrConversationNotFound
+	}
+	return s.convRepo.ListReads(ctx, conversationID, tenantID)
+}
+
+func (s *ConversationService) listMessages(ctx context.Context, conversationID, tenantID string, q model.MessagePageQuery) ([]model.Message, *model.MessagePageMeta, error) {
+	if q.Limit <= 0 {
+		q.Limit = defaultMessagePageSize
+	}
+	if q.Limit > maxMessagePageSize {
+		q.Limit = maxMessagePageSize
+	}
+
+	messages, more, err := s.msgRepo.ListPage(ctx, conversationID, tenantID, q)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return conv, messages, nil
+	meta := &model.MessagePageMeta{Limit: q.Limit}
+	if q.After != "" {
+		meta.HasOlder = true
+		meta.HasNewer = more
+	} else {
+		meta.HasOlder = more
+		meta.HasNewer = q.Before != ""
+	}
+	if len(messages) > 0 {
+		meta.OldestID = messages[0].ID
+		meta.NewestID = messages[len(messages)-1].ID
+	}
+	return messages, meta, nil
 }
 
-func (s *ConversationService) SendMessage(ctx context.Context, conversationID, tenantID, userID, userName, message string) (*model.Message, error) {
+// SendMessage stores an agent reply. When idempotencyKey is set, a repeated request with the
+// same key returns the original message and replayed = true instead of sending it twice.
+func (s *ConversationService) SendMessage(ctx context.Context, conversationID, tenantID, userID, userName, message, idempotencyKey string) (msg *model.Message, replayed bool, err error) {
+	if idempotencyKey == "" {
+		msg, err = s.sendMessage(ctx, conversationID, tenantID, userID, userName, message, "", "")
+		return msg, false, err
+	}
+	if len(idempotencyKey) > 255 {
+		return nil, false, ErrIdempotencyKeyTooLong
+	}
+
+	scope := "message:" + userID + ":" + conversationID
+	existing, err := s.idemRepo.Reserve(ctx, tenantID, scope, idempotencyKey)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		_, msg, err = s.replay(ctx, existing)
+		return msg, err == nil, err
+	}
+
+	msg, err = s.sendMessage(ctx, conversationID, tenantID, userID, userName, message, scope, idempotencyKey)
+	if err != nil {
+		s.releaseIdempotencyKey(ctx, tenantID, scope, idempotencyKey)
+		return nil, false, err
+	}
+	return msg, false, nil
+}
+
+func (s *ConversationService) sendMessage(ctx context.Context, conversationID, tenantID, userID, userName, message, idemScope, idemKey string) (*model.Message, error) {
 	// Verify conversation exists and belongs to tenant
 	conv, err := s.convRepo.GetByID(ctx, conversationID, tenantID)
 	if err != nil {
-		return nil, errors.New("conversation not found")
+		return nil, ErrConversationNotFound
 	}
 
 	if conv.Status == "closed" {
 		return nil, errors.New("conversation is closed")
 	}
+	// Replays of accepted messages are answered before this point and are not counted again
+	releaseQuota, err := s.quota.ReserveMessage(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
 
 	msg := &model.Message{
 		ConversationID: conversationID,
@@ -162,51 +431,83 @@ func (s *ConversationService) SendMessage(ctx context.Context, conversationID, t
 		SenderID:       userID,
 		SenderName:     userName,
 		Message:        message,
+		DeliveryStatus: "queued",
 	}
 
-	err = s.msgRepo.Create(ctx, msg)
+	err = s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Messages.Create(ctx, tenantID, msg); err != nil {
+			return err
+		}
+		if err := tx.Conversations.UpdateLastMessage(ctx, conversationID, tenantID); err != nil {
+			return err
+		}
+		if err := tx.Events.LogChange(ctx, tenantID, "message.sent", "conversation", conversationID, userID, msg, nil, msg); err != nil {
+			return err
+		}
+		// message.sent also drives outbound delivery, so it must never be lost
+		if err := tx.Outbox.Enqueue(ctx, tenantID, "conversation.events", "message.sent", messagePayload(tenantID, msg)); err != nil {
+			return err
+		}
+		if idemKey != "" {
+			return tx.Idempotency.Complete(ctx, tenantID, idemScope, idemKey, conversationID, msg.ID)
+		}
+		return nil
+	})
 	if err != nil {
+		releaseQuota()
 		return nil, err
 	}
 
-	// Update conversation
-	s.convRepo.UpdateLastMessage(ctx, conversationID)
-
-	// Invalidate cache
+	s.slaSvc.RecordFirstResponse(ctx, repository.SLATableConversations, conversationID, tenantID)
 	s.invalidateConversationCache(ctx, tenantID)
 
-	// Log event
-	s.logEvent(ctx, tenantID, "message.sent", "conversation", conversationID, userID, msg)
-
-	// Publish to message queue for realtime delivery
-	go func(m *model.Message, tenant string) {
-		payload := map[string]interface{}{
-			"tenant_id":       tenant,
-			"conversation_id": m.ConversationID,
-			"message_id":      m.ID,
-			"sender_id":       m.SenderID,
-			"sender_name":     m.SenderName,
-			"sender_type":     m.SenderType,
-			"message":         m.Message,
-			"created_at":      m.CreatedAt,
-		}
-		s.publishEvent(context.Background(), "conversation.events", "message.sent", payload)
-	}(msg, tenantID)
-
 	return msg, nil
 }
 
-func (s *ConversationService) Assign(ctx context.Context, conversationID, tenantID, agentID string) error {
+// messagePayload is the realtime representation of a message published on conversation.events
+func messagePayload(tenantID string, m *model.Message) map[string]interface{} {
+	return map[string]interface{}{
+		"tenant_id":       tenantID,
+		"conversation_id": m.ConversationID,
+		"message_id":      m.ID,
+		"sender_id":       m.SenderID,
+		"sender_name":     m.SenderName,
+		"sender_type":     m.SenderType,
+		"message":         m.Message,
+		"created_at":      m.CreatedAt,
+	}
+}
+
+// Assign gives the conversation to agentID; userID is the user making the assignment
+func (s *ConversationService) Assign(ctx context.Context, conversationID, tenantID, agentID, userID string) error {
 	conv, err := s.convRepo.GetByID(ctx, conversationID, tenantID)
 	if err != nil {
-		return errors.New("conversation not found")
+		return ErrConversationNotFound
 	}
 
 	if conv.Status == "closed" {
 		return errors.New("cannot assign closed conversation")
 	}
+	after := *conv
+	after.AssignedAgentID = sql.NullString{String: agentID, Valid: true}
 
-	err = s.convRepo.Assign(ctx, conversationID, agentID)
+	err = s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Conversations.Assign(ctx, conversationID, tenantID, agentID); err != nil {
+			return err
+		}
+		if err := tx.Events.LogChange(ctx, tenantID, "conversation.assigned", "conversation", conversationID, userID, map[string]string{"agent_id": agentID}, conv, &after); err != nil {
+			return err
+		}
+		return tx.Outbox.Enqueue(ctx, tenantID, "conversation.events", "conversation.assigned", map[string]string{
+			"tenant_id":       tenantID,
+			"conversation_id": conversationID,
+			"agent_id":        agentID,
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// the agent is not a user of the tenant
+		return ErrAgentNotFound
+	}
 	if err != nil {
 		return err
 	}
@@ -214,85 +515,151 @@ func (s *ConversationService) Assign(ctx context.Context, conversationID, tenant
 	// Invalidate cache
 	s.invalidateConversationCache(ctx, tenantID)
 
-	// Log event and publish to queue
-	s.logEvent(ctx, tenantID, "conversation.assigned", "conversation", conversationID, agentID, map[string]string{"agent_id": agentID})
-	s.publishEvent(ctx, "conversation.events", "conversation.assigned", map[string]string{
-		"conversation_id": conversationID,
-		"agent_id":        agentID,
-	})
-
 	return nil
 }
 
-func (s *ConversationService) UpdateStatus(ctx context.Context, id, status string) error {
-	err := s.convRepo.UpdateStatus(ctx, id, status)
+// UpdateStatus sets any status other than closed, which goes through Close
+func (s *ConversationService) UpdateStatus(ctx context.Context, id, tenantID, userID, status string) error {
+	conv, err := s.convRepo.GetByID(ctx, id, tenantID)
+	if err != nil {
+		return ErrConversationNotFound
+	}
+	after := *conv
+	after.Status = status
+
+	err = s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Conversations.UpdateStatus(ctx, id, tenantID, status); err != nil {
+			return err
+		}
+		return tx.Events.LogChange(ctx, tenantID, "conversation.status_updated", "conversation", id, userID, map[string]string{
+			"old_status": conv.Status,
+			"new_status": status,
+		}, conv, &after)
+	})
 	if err != nil {
 		return err
 	}
-	// no tenant context here for cache invalidation; caller should handle if needed
+	s.slaSvc.RecordResolution(ctx, repository.SLATableConversations, id, tenantID, status == "closed")
+	s.invalidateConversationCache(ctx, tenantID)
 	return nil
 }
 
 func (s *ConversationService) Close(ctx context.Context, conversationID, tenantID, userID string) error {
 	conv, err := s.convRepo.GetByID(ctx, conversationID, tenantID)
 	if err != nil {
-		return errors.New("conversation not found")
+		return ErrConversationNotFound
 	}
 
 	if conv.Status == "closed" {
 		return errors.New("conversation already closed")
 	}
+	after := *conv
+	after.Status = "closed"
 
-	err = s.convRepo.UpdateStatus(ctx, conversationID, "closed")
+	err = s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Conversations.UpdateStatus(ctx, conversationID, tenantID, "closed"); err != nil {
+			return err
+		}
+		return tx.Events.LogChange(ctx, tenantID, "conversation.closed", "conversation", conversationID, userID, nil, conv, &after)
+	})
 	if err != nil {
 		return err
 	}
+	s.slaSvc.RecordResolution(ctx, repository.SLATableConversations, conversationID, tenantID, true)
 
 	// Invalidate cache
 	s.invalidateConversationCache(ctx, tenantID)
 
-	// Log event
-	s.logEvent(ctx, tenantID, "conversation.closed", "conversation", conversationID, userID, nil)
-
 	return nil
 }
 
-func (s *ConversationService) Create(ctx context.Context, tenantID string, conv *model.Conversation) (*model.Conversation, error) {
+func (s *ConversationService) Create(ctx context.Context, tenantID, userID string, conv *model.Conversation) (*model.Conversation, error) {
 	conv.TenantID = tenantID
 	if conv.Channel == "" {
 		conv.Channel = "unknown"
 	}
-	err := s.convRepo.Create(ctx, conv)
+	err := s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Conversations.Create(ctx, conv); err != nil {
+			return err
+		}
+		return tx.Events.LogChange(ctx, tenantID, "conversation.created", "conversation", conv.ID, userID, conv, nil, conv)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCustomerNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
-	s.logEvent(ctx, tenantID, "conversation.created", "conversation", conv.ID, "", conv)
+	s.slaSvc.StartConversationClock(ctx, conv)
+	s.autoAssign(ctx, conv)
 	s.invalidateConversationCache(ctx, tenantID)
 	return conv, nil
 }
 
-func (s *ConversationService) Delete(ctx context.Context, id, tenantID string) error {
-	_, err := s.convRepo.GetByID(ctx, id, tenantID)
+func (s *ConversationService) Delete(ctx context.Context, id, tenantID, userID string) error {
+	conv, err := s.convRepo.GetByID(ctx, id, tenantID)
 	if err != nil {
-		return errors.New("conversation not found")
+		return ErrConversationNotFound
 	}
-	err = s.convRepo.Delete(ctx, id, tenantID)
+	err = s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Conversations.Delete(ctx, id, tenantID, userID); err != nil {
+			return orNotFound(err, ErrConversationNotFound)
+		}
+		if err := tx.Events.LogChange(ctx, tenantID, "conversation.deleted", "conversation", id, userID, nil, conv, nil); err != nil {
+			return err
+		}
+		return tx.Outbox.Enqueue(ctx, tenantID, "conversation.events", "conversation.deleted", map[string]string{
+			"tenant_id":         tenantID,
+			"conversation_id":   id,
+			"assigned_agent_id": conv.AssignedAgentID.String,
+			"deleted_by":        userID,
+		})
+	})
 	if err != nil {
 		return err
 	}
 	s.invalidateConversationCache(ctx, tenantID)
-	s.logEvent(ctx, tenantID, "conversation.deleted", "conversation", id, "", nil)
 	return nil
 }
 
+// Restore undoes a soft delete of a conversation that has not been purged yet
+func (s *ConversationService) Restore(ctx context.Context, id, tenantID, userID string) (*model.Conversation, error) {
+	deleted, err := s.convRepo.GetDeleted(ctx, id, tenantID)
+	if err != nil {
+		return nil, ErrConversationNotFound
+	}
+	after := *deleted
+	after.DeletedAt, after.DeletedBy = sql.NullTime{}, sql.NullString{}
+
+	err = s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Conversations.Restore(ctx, id, tenantID); err != nil {
+			return orNotFound(err, ErrConversationNotFound)
+		}
+		if err := tx.Events.LogChange(ctx, tenantID, "conversation.restored", "conversation", id, userID, nil, deleted, &after); err != nil {
+			return err
+		}
+		return tx.Outbox.Enqueue(ctx, tenantID, "conversation.events", "conversation.restored", map[string]string{
+			"tenant_id":         tenantID,
+			"conversation_id":   id,
+			"assigned_agent_id": after.AssignedAgentID.String,
+			"restored_by":       userID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateConversationCache(ctx, tenantID)
+	return s.convRepo.GetByID(ctx, id, tenantID)
+}
+
 func (s *ConversationService) ListTicketsForConversation(ctx context.Context, conversationID, tenantID string) ([]model.Ticket, error) {
 	// ensure conversation belongs to tenant
 	_, err := s.convRepo.GetByID(ctx, conversationID, tenantID)
 	if err != nil {
-		return nil, errors.New("conversation not found")
+		return nil, ErrConversationNotFound
 	}
 
-	tickets, err := s.convRepoListTickets(ctx, conversationID)
+	tickets, err := s.convRepoListTickets(ctx, conversationID, tenantID)
 	if err != nil {
 		return nil, err
 	}
@@ -300,83 +667,188 @@ func (s *ConversationService) ListTicketsForConversation(ctx context.Context, co
 }
 
 // convRepoListTickets bridges to ticketRepo to list tickets for conversation
-func (s *ConversationService) convRepoListTickets(ctx context.Context, conversationID string) ([]model.Ticket, error) {
-	return s.convRepoListTicketsImpl(ctx, conversationID)
+func (s *ConversationService) convRepoListTickets(ctx context.Context, conversationID, tenantID string) ([]model.Ticket, error) {
+	return s.convRepoListTicketsImpl(ctx, conversationID, tenantID)
 }
 
 // This is a small indirection to keep repository references organized; we'll implement impl below by casting to known repository
-func (s *ConversationService) convRepoListTicketsImpl(ctx context.Context, conversationID string) ([]model.Ticket, error) {
+func (s *ConversationService) convRepoListTicketsImpl(ctx context.Context, conversationID, tenantID string) ([]model.Ticket, error) {
 	// convRepo doesn't have ticket listing, use ticketRepo directly if available
 	if s == nil || s.ticketRepo == nil {
 		return nil, errors.New("internal error")
 	}
-	return s.ticketRepo.ListByConversationID(ctx, conversationID)
+	return s.ticketRepo.ListByConversationID(ctx, conversationID, tenantID)
 }
 
 func (s *ConversationService) SetSelectedTicket(ctx context.Context, conversationID, tenantID, userID, ticketID string) error {
 	// verify conversation exists and belongs to tenant
-	_, err := s.convRepo.GetByID(ctx, conversationID, tenantID)
+	conv, err := s.convRepo.GetByID(ctx, conversationID, tenantID)
 	if err != nil {
-		return errors.New("conversation not found")
+		return ErrConversationNotFound
 	}
+	after := *conv
+	after.SelectedTicketID = sql.NullString{String: ticketID, Valid: true}
 
 	// optionally verify ticket exists
 	_, err = s.ticketRepo.GetByID(ctx, ticketID, tenantID)
 	if err != nil {
-		return errors.New("ticket not found")
+		return ErrTicketNotFound
 	}
 
-	// ensure mapping exists between ticket and conversation (many-to-many)
-	if err := s.ticketRepo.AddConversationMapping(ctx, ticketID, conversationID); err != nil {
+	return s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		// ensure mapping exists between ticket and conversation (many-to-many)
+		if err := tx.Tickets.AddConversationMapping(ctx, tenantID, ticketID, conversationID); err != nil {
+			return err
+		}
+		if err := tx.Conversations.SetSelectedTicket(ctx, conversationID, tenantID, ticketID); err != nil {
+			return err
+		}
+		if err := tx.Events.LogChange(ctx, tenantID, "conversation.selected_ticket", "conversation", conversationID, userID, map[string]string{"ticket_id": ticketID}, conv, &after); err != nil {
+			return err
+		}
+		return tx.Outbox.Enqueue(ctx, tenantID, "conversation.events", "conversation.selected_ticket", map[string]string{
+			"tenant_id":       tenantID,
+			"conversation_id": conversationID,
+			"ticket_id":       ticketID,
+		})
+	})
+}
+
+// DeleteMessage soft-deletes a message. Without moderate, only the caller's own agent messages
+// within the edit window can be deleted.
+func (s *ConversationService) DeleteMessage(ctx context.Context, id, tenantID, userID string, moderate bool) error {
+	msg, err := s.msgRepo.GetByID(ctx, id, tenantID)
+	if err != nil {
+		return ErrMessageNotFound
+	}
+	if err := s.checkMessageOwner(msg, userID, moderate); err != nil {
 		return err
 	}
-
-	err = s.convRepo.SetSelectedTicket(ctx, conversationID, ticketID)
+	err = s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Messages.Delete(ctx, id, tenantID, userID); err != nil {
+			return orNotFound(err, ErrMessageNotFound)
+		}
+		if err := tx.Events.LogChange(ctx, tenantID, "message.deleted", "message", id, userID, map[string]string{"conversation_id": msg.ConversationID}, msg, nil); err != nil {
+			return err
+		}
+		return tx.Outbox.Enqueue(ctx, tenantID, "conversation.events", "message.deleted", map[string]string{
+			"tenant_id":       tenantID,
+			"conversation_id": msg.ConversationID,
+			"message_id":      id,
+			"deleted_by":      userID,
+		})
+	})
 	if err != nil {
 		return err
 	}
-
-	s.logEvent(ctx, tenantID, "conversation.selected_ticket", "conversation", conversationID, userID, map[string]string{"ticket_id": ticketID})
-	s.publishEvent(ctx, "conversation.events", "conversation.selected_ticket", map[string]string{"conversation_id": conversationID, "ticket_id": ticketID})
+	s.invalidateConversationCache(ctx, tenantID)
 	return nil
 }
 
-func (s *ConversationService) DeleteMessage(ctx context.Context, id, tenantID, userID string) error {
-	// Verify message exists and belongs to a conversation under tenant
-	// Fetch message by conversation via repository (no GetByID implemented), query conversations ownership
-	// We'll perform a simple delete assuming authorization handled by middleware (tenant scope)
-	err := s.msgRepo.Delete(ctx, id)
+// EditMessage replaces the text of a message and keeps the previous text in its edit history.
+// Without moderate, only the caller's own agent messages within the edit window can be edited.
+// Agent replies already handed to the channel are not re-delivered.
+func (s *ConversationService) EditMessage(ctx context.Context, id, tenantID, userID, text string, moderate bool) (*model.Message, error) {
+	var msg *model.Message
+	changed := false
+	// The message is read under a row lock so concurrent edits each record the text they replaced
+	err := s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		var err error
+		msg, err = tx.Messages.GetForUpdate(ctx, id, tenantID)
+		if err != nil {
+			return ErrMessageNotFound
+		}
+		if err := s.checkMessageOwner(msg, userID, moderate); err != nil {
+			return err
+		}
+		if text == msg.Message {
+			return nil
+		}
+		before := *msg
+		msg.Message = text
+		changed = true
+
+		edit := &model.MessageEdit{MessageID: id, PreviousMessage: before.Message, EditedBy: userID}
+		if err := tx.Messages.AddEdit(ctx, tenantID, edit); err != nil {
+			return err
+		}
+		if err := tx.Messages.UpdateText(ctx, tenantID, msg); err != nil {
+			return err
+		}
+		if err := tx.Events.LogChange(ctx, tenantID, "message.updated", "message", id, userID, map[string]string{"conversation_id": msg.ConversationID}, &before, msg); err != nil {
+			return err
+		}
+		payload := messagePayload(tenantID, msg)
+		payload["edited_at"] = msg.EditedAt.Time
+		payload["edited_by"] = userID
+		return tx.Outbox.Enqueue(ctx, tenantID, "conversation.events", "message.updated", payload)
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	s.logEvent(ctx, tenantID, "message.deleted", "message", id, userID, nil)
-	return nil
+	if changed {
+		s.invalidateConversationCache(ctx, tenantID)
+	}
+	return msg, nil
 }
 
-func (s *ConversationService) logEvent(ctx context.Context, tenantID, eventType, entityType, entityID, userID string, data interface{}) {
-	err := s.eventRepo.LogEvent(ctx, tenantID, eventType, entityType, entityID, userID, data)
-	if err != nil {
-		log.Printf("Failed to log event: %v", err)
+// ListMessageEdits returns the previous versions of a message, oldest first
+func (s *ConversationService) ListMessageEdits(ctx context.Context, id, tenantID string) ([]model.MessageEdit, error) {
+	if _, err := s.msgRepo.GetByID(ctx, id, tenantID); err != nil {
+		return nil, ErrMessageNotFound
 	}
+	return s.msgRepo.ListEdits(ctx, id, tenantID)
 }
 
-func (s *ConversationService) publishEvent(ctx context.Context, exchange, routingKey string, data interface{}) {
-	if s.rabbitCh == nil {
-		return
+// checkMessageOwner lets moderators change any message and everyone else only their own agent
+// messages, until the edit window has passed
+func (s *ConversationService) checkMessageOwner(msg *model.Message, userID string, moderate bool) error {
+	if moderate {
+		return nil
+	}
+	if msg.SenderType != "agent" || msg.SenderID != userID {
+		return ErrMessageNotOwned
 	}
+	if time.Since(msg.CreatedAt) > s.editWindow {
+		return ErrMessageEditExpired
+	}
+	return nil
+}
 
-	body, err := json.Marshal(data)
+// RestoreMessage undoes a soft delete of a message; messages of deleted conversations are
+// restored together with their conversation instead
+func (s *ConversationService) RestoreMessage(ctx context.Context, id, tenantID, userID string) (*model.Message, error) {
+	deleted, err := s.msgRepo.GetDeleted(ctx, id, tenantID)
 	if err != nil {
-		log.Printf("Failed to marshal event data: %v", err)
-		return
+		return nil, ErrMessageNotFound
 	}
+	after := *deleted
+	after.DeletedAt, after.DeletedBy = sql.NullTime{}, sql.NullString{}
 
-	err = s.rabbitCh.PublishWithContext(ctx, exchange, routingKey, false, false, amqp.Publishing{
-		ContentType: "application/json",
-		Body:        body,
+	err = s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Messages.Restore(ctx, id, tenantID); err != nil {
+			return orNotFound(err, ErrMessageNotFound)
+		}
+		if err := tx.Events.LogChange(ctx, tenantID, "message.restored", "message", id, userID, map[string]string{"conversation_id": deleted.ConversationID}, deleted, &after); err != nil {
+			return err
+		}
+		payload := messagePayload(tenantID, &after)
+		payload["restored_by"] = userID
+		return tx.Outbox.Enqueue(ctx, tenantID, "conversation.events", "message.restored", payload)
 	})
 	if err != nil {
-		log.Printf("Failed to publish event: %v", err)
+		return nil, err
+	}
+	return s.msgRepo.GetByID(ctx, id, tenantID)
+}
+
+// autoAssign runs the assignment engine for a freshly created conversation; failures leave it open
+func (s *ConversationService) autoAssign(ctx context.Context, conv *model.Conversation) {
+	if s.assignSvc == nil {
+		return
+	}
+	if err := s.assignSvc.AutoAssign(ctx, conv); err != nil {
+		slog.ErrorContext(ctx, "failed to auto-assign conversation", "conversation_id", conv.ID, logging.Err(err))
 	}
 }
 
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"backend/internal/broker"
+	"backend/internal/connector"
+	"backend/internal/logging"
+	"backend/internal/model"
+	"backend/internal/repository"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	deliveryQueue       = "outbound.delivery"
+	deliveryConsumerTag = "delivery"
+)
+
+// DeliveryService consumes message.sent events and delivers agent replies to the
+// customer's channel through the connector registered for the channel slug
+type DeliveryService struct {
+	msgRepo     *repository.MessageRepository
+	convRepo    *repository.ConversationRepository
+	channelRepo *repository.ChannelRepository
+	registry    *connector.Registry
+	store       *repository.Store
+	maxAttempts int
+	backoff     time.Duration
+	inflight    sync.WaitGroup
+}
+
+func NewDeliveryService(
+	msgRepo *repository.MessageRepository,
+	convRepo *repository.ConversationRepository,
+	channelRepo *repository.ChannelRepository,
+	registry *connector.Registry,
+	store *repository.Store,
+) *DeliveryService {
+	return &DeliveryService{
+		msgRepo:     msgRepo,
+		convRepo:    convRepo,
+		channelRepo: channelRepo,
+		registry:    registry,
+		store:       store,
+		maxAttempts: 5,
+		backoff:     time.Second,
+	}
+}
+
+// Start consumes from a durable queue shared by all instances so each reply is delivered once;
+// the consumer is restarted whenever the broker connection comes back. When ctx is cancelled
+// the consumer is cancelled and Start returns once in-flight deliveries have finished;
+// deliveries interrupted by the shutdown are requeued.
+func (s *DeliveryService) Start(ctx context.Context, rabbit *broker.Manager) {
+	rabbit.Consume(ctx, deliveryConsumerTag, s.consume)
+	s.inflight.Wait()
+}
+
+func (s *DeliveryService) consume(ctx context.Context, ch *amqp.Channel) error {
+	q, err := ch.QueueDeclare(deliveryQueue, true, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("declare queue: %w", err)
+	}
+	if err := ch.QueueBind(q.Name, "message.sent", "conversation.events", false, nil); err != nil {
+		return fmt.Errorf("bind queue: %w", err)
+	}
+	if err := ch.Qos(10, 0, false); err != nil {
+		return fmt.Errorf("set qos: %w", err)
+	}
+
+	msgs, err := ch.Consume(q.Name, deliveryConsumerTag, false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("consume: %w", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := ch.Cancel(deliveryConsumerTag, false); err != nil {
+				slog.WarnContext(ctx, "delivery: failed to cancel consumer", logging.Err(err))
+			}
+			// acks need the channel, so keep it open until in-flight deliveries finish
+			s.inflight.Wait()
+			return nil
+		case d, ok := <-msgs:
+			if !ok {
+				return errors.New("delivery channel closed")
+			}
+			var payload struct {
+				TenantID  string `json:"tenant_id"`
+				MessageID string `json:"message_id"`
+			}
+			if err := json.Unmarshal(d.Body, &payload); err != nil || payload.MessageID == "" {
+				d.Nack(false, false)
+				continue
+			}
+			s.inflight.Add(1)
+			go func(d amqp.Delivery) {
+				defer s.inflight.Done()
+				// a delivery cut short by the shutdown goes back to the queue for another instance
+				if !s.Deliver(ctx, payload.TenantID, payload.MessageID) {
+					d.Nack(false, true)
+					return
+				}
+				d.Ack(false)
+			}(d)
+		}
+	}
+}
+
+// Deliver sends one message with exponential backoff between attempts and records the outcome.
+// It returns false when ctx was cancelled before the message was settled, so the caller can
+// requeue it instead of dropping it.
+func (s *DeliveryService) Deliver(ctx context.Context, tenantID, messageID string) bool {
+	msg, err := s.msgRepo.GetByID(ctx, messageID, tenantID)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false
+		}
+		slog.WarnContext(ctx, "delivery: message not found", "message_id", messageID, logging.Err(err))
+		return true
+	}
+	if msg.SenderType != "agent" || msg.DeliveryStatus == "sent" || msg.DeliveryStatus == "delivered" {
+		return true
+	}
+
+	conv, err := s.convRepo.GetByID(ctx, msg.ConversationID, tenantID)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false
+		}
+		s.setStatus(ctx, tenantID, msg, "failed", "conversation not found")
+		return true
+	}
+
+	ch, err := s.channelRepo.GetBySlug(ctx, conv.Channel, tenantID)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false
+		}
+		s.setStatus(ctx, tenantID, msg, "failed", "channel "+conv.Channel+" not configured")
+		return true
+	}
+	conn := s.registry.Resolve(ch)
+	if conn == nil {
+		s.setStatus(ctx, tenantID, msg, "failed", "no outbound connector for channel "+ch.Slug)
+		return true
+	}
+
+	out := connector.OutboundMessage{
+		TenantID:           tenantID,
+		Channel:            *ch,
+		CustomerExternalID: conv.CustomerExternalID,
+		Message:            *msg,
+	}
+
+	backoff := s.backoff
+	for msg.DeliveryAttempts < s.maxAttempts {
+		msg.DeliveryAttempts++
+		res, err := conn.Send(ctx, out)
+		if err == nil {
+			status := "sent"
+			if res != nil && res.Delivered {
+				status = "delivered"
+			}
+			s.setStatus(ctx, tenantID, msg, status, "")
+			return true
+		}
+
+		if ctx.Err() != nil {
+			return false
+		}
+		slog.WarnContext(ctx, "delivery: attempt failed", "message_id", msg.ID, "attempt", msg.DeliveryAttempts, logging.Err(err))
+		if errors.Is(err, connector.ErrPermanent) || msg.DeliveryAttempts >= s.maxAttempts {
+			s.setStatus(ctx, tenantID, msg, "failed", err.Error())
+			return true
+		}
+		s.setStatus(ctx, tenantID, msg, "queued", err.Error())
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return true
+}
+
+// setStatus stores the delivery status and queues the update for websocket clients
+func (s *DeliveryService) setStatus(ctx context.Context, tenantID string, msg *model.Message, status, deliveryErr string) {
+	// record the outcome even when shutdown cancels ctx right after the send
+	ctx = context.WithoutCancel(ctx)
+	msg.DeliveryStatus = status
+	msg.DeliveryError = deliveryErr
+	err := s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Messages.UpdateDeliveryStatus(ctx, msg.ID, tenantID, status, msg.DeliveryAttempts, deliveryErr); err != nil {
+			return err
+		}
+		return tx.Outbox.Enqueue(ctx, tenantID, "conversation.events", "message.delivery_updated", map[string]interface{}{
+			"type":              "message.delivery_updated",
+			"tenant_id":         tenantID,
+			"conversation_id":   msg.ConversationID,
+			"message_id":        msg.ID,
+			"delivery_status":   status,
+			"delivery_attempts": msg.DeliveryAttempts,
+			"delivery_error":    deliveryErr,
+		})
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "delivery: failed to update status", "message_id", msg.ID, logging.Err(err))
+	}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"backend/internal/broker"
+	"backend/internal/logging"
+	"backend/internal/metrics"
+	"backend/internal/model"
+	"backend/internal/repository"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	outboxBatchSize    = 100
+	outboxMaxBackoff   = 5 * time.Minute
+	outboxRetention    = 7 * 24 * time.Hour
+	outboxPurgeEvery   = time.Hour
+	outboxPublishLimit = 5 * time.Second
+	// outboxLease covers a whole batch of publishes that each hit outboxPublishLimit, so a
+	// live relay never has its records claimed again by another instance
+	outboxLease = outboxBatchSize*outboxPublishLimit + time.Minute
+)
+
+// OutboxRelay publishes committed outbox records to RabbitMQ, oldest due records first. Order is
+// not guaranteed: a failed record waits out its backoff while later ones are published, and
+// records are leased with SKIP LOCKED so several instances relay concurrently. Publishing
+// happens outside any transaction; a record is marked sent only once the broker confirms it, a
+// failed publish is retried with exponential backoff and delivery is at-least-once (the AMQP
+// message id is the outbox id so consumers can deduplicate).
+// While the broker is unreachable records stay pending in the outbox, without using up attempts.
+type OutboxRelay struct {
+	outbox   *repository.OutboxRepository
+	broker   *broker.Manager
+	interval time.Duration
+}
+
+func NewOutboxRelay(outbox *repository.OutboxRepository, broker *broker.Manager, interval time.Duration) *OutboxRelay {
+	return &OutboxRelay{
+		outbox:   outbox,
+		broker:   broker,
+		interval: interval,
+	}
+}
+
+// Run relays pending records every interval until ctx is cancelled
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	lastPurge := time.Time{}
+
+	for {
+		// keep draining while full batches come back
+		for {
+			n, err := r.relayBatch(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "outbox: relay failed", logging.Err(err))
+			}
+			if err != nil || n < outboxBatchSize {
+				break
+			}
+		}
+
+		if time.Since(lastPurge) >= outboxPurgeEvery {
+			if n, err := r.outbox.PurgeSent(ctx, time.Now().Add(-outboxRetention)); err != nil {
+				slog.ErrorContext(ctx, "outbox: purge failed", logging.Err(err))
+			} else if n > 0 {
+				slog.InfoContext(ctx, "outbox: purged sent records", "count", n)
+			}
+			lastPurge = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relayBatch publishes one batch and returns how many records were claimed
+func (r *OutboxRelay) relayBatch(ctx context.Context) (int, error) {
+	// Without a broker connection records simply stay pending until one is available
+	if !r.broker.Connected() {
+		return 0, nil
+	}
+
+	msgs, err := r.outbox.ClaimPending(ctx, outboxBatchSize, outboxLease)
+	if err != nil {
+		return 0, err
+	}
+
+	// Outcomes are recorded even when shutdown cancels ctx mid-batch; records left unmarked
+	// after an error are claimed again when their lease ends
+	markCtx := context.WithoutCancel(ctx)
+	for i, m := range msgs {
+		err := r.publish(ctx, m)
+		if errors.Is(err, broker.ErrUnavailable) || ctx.Err() != nil {
+			// connection dropped or shutting down; hand the rest back for the next relay
+			for _, rest := range msgs[i:] {
+				if err := r.outbox.Release(markCtx, rest.ID); err != nil {
+					return len(msgs), err
+				}
+			}
+			return len(msgs), nil
+		}
+		if err != nil {
+			metrics.RabbitPublishFailures.WithLabelValues(m.Exchange).Inc()
+			slog.WarnContext(ctx, "outbox: failed to publish", "routing_key", m.RoutingKey, "outbox_id", m.ID, logging.Err(err))
+			if err := r.outbox.MarkRetry(markCtx, m.ID, err.Error(), time.Now().Add(outboxBackoff(m.Attempts))); err != nil {
+				return len(msgs), err
+			}
+			continue
+		}
+		if err := r.outbox.MarkSent(markCtx, m.ID); err != nil {
+			return len(msgs), err
+		}
+	}
+	return len(msgs), nil
+}
+
+func (r *OutboxRelay) publish(ctx context.Context, m model.OutboxMessage) error {
+	ctx, cancel := context.WithTimeout(ctx, outboxPublishLimit)
+	defer cancel()
+
+	return r.broker.Publish(ctx, m.Exchange, m.RoutingKey, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    m.ID,
+		Timestamp:    m.CreatedAt,
+		Body:         []byte(m.Payload),
+	})
+}
+
+// outboxBackoff doubles the retry delay per failed attempt, capped at outboxMaxBackoff
+func outboxBackoff(attempts int) time.Duration {
+	if attempts > 8 {
+		return outboxMaxBackoff
+	}
+	d := time.Second << attempts
+	if d > outboxMaxBackoff {
+		return outboxMaxBackoff
+	}
+	return d
+}
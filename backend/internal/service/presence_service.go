@@ -0,0 +1,280 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/broker"
+	"backend/internal/logging"
+	"backend/internal/model"
+	"backend/internal/repository"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// Presence keys:
+//   - presenceConnsKey + tenant + ":" + user: sorted set of live connection ids scored by expiry (ms)
+//   - presenceExpiryKey: every connection as "tenant|user|conn" scored by expiry, for the sweeper
+//   - presenceStatusKey + tenant: hash of user id -> manual status
+const (
+	presenceConnsKey  = "presence:conns:"
+	presenceExpiryKey = "presence:expiry"
+	presenceStatusKey = "presence:status:"
+)
+
+const (
+	// PresenceHeartbeat is how often a connection refreshes its presence entry
+	PresenceHeartbeat = 30 * time.Second
+	// presenceTTL is how long a connection counts as live without a heartbeat
+	presenceTTL = 3 * PresenceHeartbeat
+)
+
+// PresenceService tracks which agents have a live websocket connection on any instance and the
+// status they chose. Changes are published as agent.presence events. Redis errors are logged and
+// agents are treated as available so assignment keeps working.
+type PresenceService struct {
+	redis          *redis.Client
+	userRepo       *repository.UserRepository
+	assignmentRepo *repository.AssignmentRepository
+	rabbit         *broker.Manager
+}
+
+func NewPresenceService(redis *redis.Client, userRepo *repository.UserRepository, assignmentRepo *repository.AssignmentRepository, rabbit *broker.Manager) *PresenceService {
+	return &PresenceService{redis: redis, userRepo: userRepo, assignmentRepo: assignmentRepo, rabbit: rabbit}
+}
+
+// Connect registers a websocket connection of the user
+func (s *PresenceService) Connect(ctx context.Context, tenantID, userID, connID string) {
+	before := s.status(ctx, tenantID, userID)
+	if err := s.touch(ctx, tenantID, userID, connID); err != nil {
+		slog.WarnContext(ctx, "presence: failed to register connection", logging.Err(err))
+		return
+	}
+	s.publishIfChanged(ctx, tenantID, userID, before)
+}
+
+// Heartbeat keeps a connection live; call it every PresenceHeartbeat
+func (s *PresenceService) Heartbeat(ctx context.Context, tenantID, userID, connID string) {
+	if err := s.touch(ctx, tenantID, userID, connID); err != nil {
+		slog.WarnContext(ctx, "presence: heartbeat failed", logging.Err(err))
+	}
+}
+
+// Disconnect removes a connection; the user goes offline with their last connection
+func (s *PresenceService) Disconnect(ctx context.Context, tenantID, userID, connID string) {
+	before := s.status(ctx, tenantID, userID)
+	pipe := s.redis.TxPipeline()
+	pipe.ZRem(ctx, presenceConnsKey+tenantID+":"+userID, connID)
+	pipe.ZRem(ctx, presenceExpiryKey, presenceMember(tenantID, userID, connID))
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.WarnContext(ctx, "presence: failed to remove connection", logging.Err(err))
+		return
+	}
+	s.publishIfChanged(ctx, tenantID, userID, before)
+}
+
+func (s *PresenceService) touch(ctx context.Context, tenantID, userID, connID string) error {
+	expiry := float64(time.Now().Add(presenceTTL).UnixMilli())
+	key := presenceConnsKey + tenantID + ":" + userID
+	pipe := s.redis.TxPipeline()
+	pipe.ZAdd(ctx, key, redis.Z{Score: expiry, Member: connID})
+	pipe.Expire(ctx, key, presenceTTL)
+	pipe.ZAdd(ctx, presenceExpiryKey, redis.Z{Score: expiry, Member: presenceMember(tenantID, userID, connID)})
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SetStatus updates the caller's manual status and/or auto-assignment capacity. The capacity
+// is clamped to the tenant's max_open_per_agent when one is set, so agents can lower their
+// share of new conversations but not raise it above what admins allow.
+func (s *PresenceService) SetStatus(ctx context.Context, tenantID, userID string, req model.UpdatePresenceRequest) (*model.AgentPresence, error) {
+	user, err := s.userRepo.GetByID(ctx, userID, tenantID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	before := s.status(ctx, tenantID, userID)
+	if req.Status != "" {
+		if err := s.redis.HSet(ctx, presenceStatusKey+tenantID, userID, req.Status).Err(); err != nil {
+			return nil, err
+		}
+	}
+	if req.MaxConversations != nil {
+		max := *req.MaxConversations
+		settings, err := s.assignmentRepo.GetSettings(ctx, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		if settings.MaxOpenPerAgent > 0 && max > settings.MaxOpenPerAgent {
+			max = settings.MaxOpenPerAgent
+		}
+		if err := s.userRepo.SetMaxConversations(ctx, userID, tenantID, max); err != nil {
+			return nil, err
+		}
+		user.MaxConversations = max
+	}
+	s.publishIfChanged(ctx, tenantID, userID, before)
+
+	list, err := s.load(ctx, tenantID, []model.User{*user})
+	if err != nil {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// List returns the presence of every user of the tenant
+func (s *PresenceService) List(ctx context.Context, tenantID string) ([]model.AgentPresence, error) {
+	users, err := s.userRepo.GetByTenantID(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return s.load(ctx, tenantID, users)
+}
+
+func (s *PresenceService) load(ctx context.Context, tenantID string, users []model.User) ([]model.AgentPresence, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	pipe := s.redis.Pipeline()
+	manual := pipe.HGetAll(ctx, presenceStatusKey+tenantID)
+	counts := make([]*redis.IntCmd, len(users))
+	for i, u := range users {
+		counts[i] = pipe.ZCount(ctx, presenceConnsKey+tenantID+":"+u.ID, "("+now, "+inf")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	list := make([]model.AgentPresence, 0, len(users))
+	for i, u := range users {
+		p := model.AgentPresence{
+			UserID:           u.ID,
+			Name:             u.Name,
+			Role:             u.Role,
+			ManualStatus:     manualStatus(manual.Val()[u.ID]),
+			Connections:      int(counts[i].Val()),
+			MaxConversations: u.MaxConversations,
+		}
+		p.Status = effectiveStatus(p.ManualStatus, p.Connections)
+		list = append(list, p)
+	}
+	return list, nil
+}
+
+// Available returns the ids among userIDs whose presence is online. On Redis errors all are returned.
+func (s *PresenceService) Available(ctx context.Context, tenantID string, userIDs []string) map[string]bool {
+	users := make([]model.User, len(userIDs))
+	for i, id := range userIDs {
+		users[i] = model.User{ID: id}
+	}
+	available := make(map[string]bool, len(userIDs))
+	list, err := s.load(ctx, tenantID, users)
+	if err != nil {
+		slog.WarnContext(ctx, "presence: failed to load presence", logging.Err(err))
+		for _, id := range userIDs {
+			available[id] = true
+		}
+		return available
+	}
+	for _, p := range list {
+		available[p.UserID] = p.Status == model.PresenceOnline
+	}
+	return available
+}
+
+// Run removes connections whose instance stopped heartbeating (e.g. it crashed) and publishes
+// the resulting offline transitions. Every instance may run it; ZREM decides who handles an entry.
+func (s *PresenceService) Run(ctx context.Context) {
+	ticker := time.NewTicker(PresenceHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		expired, err := s.redis.ZRangeByScore(ctx, presenceExpiryKey, &redis.ZRangeBy{Min: "-inf", Max: now, Count: 1000}).Result()
+		if err != nil {
+			slog.WarnContext(ctx, "presence: sweep failed", logging.Err(err))
+			continue
+		}
+		for _, member := range expired {
+			parts := strings.SplitN(member, "|", 3)
+			if len(parts) != 3 {
+				s.redis.ZRem(ctx, presenceExpiryKey, member)
+				continue
+			}
+			if n, err := s.redis.ZRem(ctx, presenceExpiryKey, member).Result(); err != nil || n == 0 {
+				continue
+			}
+			tenantID, userID, connID := parts[0], parts[1], parts[2]
+			s.redis.ZRem(ctx, presenceConnsKey+tenantID+":"+userID, connID)
+			if s.status(ctx, tenantID, userID) == model.PresenceOffline {
+				s.publish(ctx, tenantID, userID, model.PresenceOffline)
+			}
+		}
+	}
+}
+
+// status returns the effective status of one user, offline when it cannot be read
+func (s *PresenceService) status(ctx context.Context, tenantID, userID string) string {
+	list, err := s.load(ctx, tenantID, []model.User{{ID: userID}})
+	if err != nil {
+		return model.PresenceOffline
+	}
+	return list[0].Status
+}
+
+// publishIfChanged publishes the user's status when it differs from before
+func (s *PresenceService) publishIfChanged(ctx context.Context, tenantID, userID, before string) {
+	if after := s.status(ctx, tenantID, userID); after != before {
+		s.publish(ctx, tenantID, userID, after)
+	}
+}
+
+// publish sends agent.presence to the tenant. Presence events are not stored; without a broker
+// they are dropped and clients can poll GET /agents/presence.
+func (s *PresenceService) publish(ctx context.Context, tenantID, userID, status string) {
+	body, err := json.Marshal(map[string]string{
+		"type":      "agent.presence",
+		"tenant_id": tenantID,
+		"user_id":   userID,
+		"status":    status,
+	})
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	err = s.rabbit.Publish(ctx, "conversation.events", "agent.presence", amqp.Publishing{
+		ContentType: "application/json",
+		Body:        body,
+	})
+	if err != nil && !errors.Is(err, broker.ErrUnavailable) {
+		slog.WarnContext(ctx, "presence: failed to publish change", "target_user_id", userID, logging.Err(err))
+	}
+}
+
+func presenceMember(tenantID, userID, connID string) string {
+	return tenantID + "|" + userID + "|" + connID
+}
+
+func manualStatus(s string) string {
+	if s == "" {
+		return model.PresenceOnline
+	}
+	return s
+}
+
+func effectiveStatus(manual string, connections int) string {
+	if connections == 0 {
+		return model.PresenceOffline
+	}
+	return manual
+}
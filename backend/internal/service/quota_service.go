@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"backend/internal/logging"
+	"backend/internal/model"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrQuotaExceeded = errors.New("monthly message quota exceeded")
+
+// messageQuotaKey counts messages per tenant and month: + tenant id + ":" + YYYY-MM
+const messageQuotaKey = "quota:messages:"
+
+// QuotaService enforces monthly message quotas per tenant plan. Counters live in Redis;
+// if Redis is unavailable messages are allowed and not counted.
+type QuotaService struct {
+	redis   *redis.Client
+	tenants *TenantService
+	limits  map[string]int64 // plan -> messages per month, 0 = unlimited
+}
+
+func NewQuotaService(redis *redis.Client, tenants *TenantService, limits map[string]int64) *QuotaService {
+	return &QuotaService{redis: redis, tenants: tenants, limits: limits}
+}
+
+// ReserveMessage counts one message against the tenant's monthly quota before it is stored and
+// returns ErrQuotaExceeded when the quota is used up. Counting and checking is a single INCR, so
+// concurrent senders cannot overshoot the quota. Call release when the message is not stored.
+func (s *QuotaService) ReserveMessage(ctx context.Context, tenantID string) (release func(), err error) {
+	release = func() {}
+	plan, err := s.tenants.Plan(ctx, tenantID)
+	if err != nil {
+		slog.WarnContext(ctx, "quota: failed to read plan", "target_tenant_id", tenantID, logging.Err(err))
+		return release, nil
+	}
+
+	key := messageQuotaKey + tenantID + ":" + quotaMonth(time.Now())
+	pipe := s.redis.TxPipeline()
+	count := pipe.Incr(ctx, key)
+	// keep last month's counter around for a while after the month rolls over
+	pipe.Expire(ctx, key, 40*24*time.Hour)
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.WarnContext(ctx, "quota: failed to record message", "target_tenant_id", tenantID, logging.Err(err))
+		return release, nil
+	}
+
+	release = func() {
+		if err := s.redis.Decr(context.WithoutCancel(ctx), key).Err(); err != nil {
+			slog.WarnContext(ctx, "quota: failed to release message", "target_tenant_id", tenantID, logging.Err(err))
+		}
+	}
+	if limit := s.limits[plan]; limit > 0 && count.Val() > limit {
+		release()
+		return func() {}, ErrQuotaExceeded
+	}
+	return release, nil
+}
+
+// Usage returns the tenant's message count and limit for the current month
+func (s *QuotaService) Usage(ctx context.Context, tenantID string) (*model.QuotaUsage, error) {
+	plan, err := s.tenants.Plan(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	month := quotaMonth(time.Now())
+	n, err := s.redis.Get(ctx, messageQuotaKey+tenantID+":"+month).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	return &model.QuotaUsage{Month: month, Plan: plan, Messages: n, Limit: s.limits[plan]}, nil
+}
+
+func quotaMonth(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
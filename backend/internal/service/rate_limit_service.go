@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// rateLimitKey prefixes token buckets: + group + ":" + bucket key
+const rateLimitKey = "ratelimit:"
+
+// tokenBucketScript refills the bucket for the elapsed time, then takes one token if available.
+// KEYS[1] bucket; ARGV capacity, refill rate (tokens per ms), now (ms).
+// Returns {allowed, remaining, retry_after_ms, reset_after_ms}.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or capacity
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry = math.ceil((1 - tokens) / rate)
+end
+
+local reset = math.ceil((capacity - tokens) / rate)
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', KEYS[1], reset + 1000)
+return {allowed, math.floor(tokens), retry, reset}
+`)
+
+// RateLimitService keeps token buckets in Redis so limits hold across API instances
+type RateLimitService struct {
+	redis *redis.Client
+}
+
+func NewRateLimitService(redis *redis.Client) *RateLimitService {
+	return &RateLimitService{redis: redis}
+}
+
+// Allow takes a token from the bucket key of group
+func (s *RateLimitService) Allow(ctx context.Context, group, key string, limit model.RateLimit) (model.RateLimitResult, error) {
+	rate := float64(limit.Requests) / float64(limit.Window.Milliseconds())
+	res, err := tokenBucketScript.Run(ctx, s.redis, []string{rateLimitKey + group + ":" + key},
+		limit.Requests, rate, time.Now().UnixMilli()).Int64Slice()
+	if err != nil {
+		return model.RateLimitResult{}, err
+	}
+	return model.RateLimitResult{
+		Allowed:    res[0] == 1,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+		ResetAfter: time.Duration(res[3]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,82 @@
+//go:build integration
+
+package service
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Runs the token bucket script against a real Redis:
+//
+//	TEST_REDIS_ADDR=localhost:6379 go test -tags integration ./internal/service/
+func TestTokenBucket(t *testing.T) {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR is not set")
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+	ctx := context.Background()
+
+	group := "test-" + uuid.New().String()
+	t.Cleanup(func() {
+		client.Del(ctx, rateLimitKey+group+":a", rateLimitKey+group+":b")
+	})
+	limits := NewRateLimitService(client)
+	limit := model.RateLimit{Requests: 3, Window: 300 * time.Millisecond} // a token every 100ms
+
+	for i, wantRemaining := range []int{2, 1, 0} {
+		res, err := limits.Allow(ctx, group, "a", limit)
+		if err != nil {
+			t.Fatalf("allow: %v", err)
+		}
+		if !res.Allowed || res.Remaining != wantRemaining {
+			t.Fatalf("request %d = %+v, want allowed with %d remaining", i+1, res, wantRemaining)
+		}
+	}
+
+	res, err := limits.Allow(ctx, group, "a", limit)
+	if err != nil {
+		t.Fatalf("allow: %v", err)
+	}
+	if res.Allowed {
+		t.Fatal("request over capacity was allowed")
+	}
+	if res.RetryAfter <= 0 || res.RetryAfter > 100*time.Millisecond {
+		t.Errorf("retry after = %v, want (0, 100ms]", res.RetryAfter)
+	}
+	if res.ResetAfter <= 200*time.Millisecond || res.ResetAfter > 300*time.Millisecond {
+		t.Errorf("reset after = %v, want (200ms, 300ms]", res.ResetAfter)
+	}
+
+	// other buckets are unaffected
+	if res, err := limits.Allow(ctx, group, "b", limit); err != nil || !res.Allowed || res.Remaining != 2 {
+		t.Errorf("other bucket = %+v, %v; want allowed with 2 remaining", res, err)
+	}
+
+	// a denied request does not consume anything, so one token is back after the refill interval
+	time.Sleep(res.RetryAfter + 20*time.Millisecond)
+	if res, err := limits.Allow(ctx, group, "a", limit); err != nil || !res.Allowed || res.Remaining != 0 {
+		t.Errorf("after refill = %+v, %v; want allowed with 0 remaining", res, err)
+	}
+
+	// the bucket refills to capacity and no further
+	time.Sleep(limit.Window * 2)
+	if res, err := limits.Allow(ctx, group, "a", limit); err != nil || !res.Allowed || res.Remaining != 2 {
+		t.Errorf("after full refill = %+v, %v; want allowed with 2 remaining", res, err)
+	}
+
+	// keys expire once the bucket would be full again
+	ttl, err := client.PTTL(ctx, rateLimitKey+group+":a").Result()
+	if err != nil || ttl <= 0 || ttl > 100*time.Millisecond+time.Second {
+		t.Errorf("bucket ttl = %v, %v; want at most the refill time plus 1s", ttl, err)
+	}
+}
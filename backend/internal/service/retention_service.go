@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"backend/internal/logging"
+	"backend/internal/repository"
+)
+
+// RetentionService permanently removes soft-deleted conversations, messages and tickets once
+// they are older than the retention period, together with the events and outbox messages that
+// carry copies of them; until then they can be restored. It also expires idempotency keys.
+type RetentionService struct {
+	store     *repository.Store
+	retention time.Duration
+	keyTTL    time.Duration
+}
+
+func NewRetentionService(store *repository.Store, retention, keyTTL time.Duration) *RetentionService {
+	return &RetentionService{store: store, retention: retention, keyTTL: keyTTL}
+}
+
+// Run purges expired rows every interval until ctx is cancelled
+func (s *RetentionService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.Purge(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge deletes rows soft-deleted before now minus the retention period in one transaction.
+// Events and outbox messages go first, since they are found through the rows being purged.
+func (s *RetentionService) Purge(ctx context.Context) {
+	now := time.Now()
+	cutoff := now.Add(-s.retention)
+
+	var keys, events, outbox, messages, tickets, conversations int64
+	err := s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		var err error
+		if keys, err = tx.Idempotency.PurgeExpired(ctx, now.Add(-s.keyTTL)); err != nil {
+			return err
+		}
+		if events, err = tx.Events.PurgeDeleted(ctx, cutoff); err != nil {
+			return err
+		}
+		if outbox, err = tx.Outbox.PurgeDeleted(ctx, cutoff); err != nil {
+			return err
+		}
+		if messages, err = tx.Messages.PurgeDeleted(ctx, cutoff); err != nil {
+			return err
+		}
+		if tickets, err = tx.Tickets.PurgeDeleted(ctx, cutoff); err != nil {
+			return err
+		}
+		conversations, err = tx.Conversations.PurgeDeleted(ctx, cutoff)
+		return err
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "retention purge failed", logging.Err(err))
+		return
+	}
+	if keys > 0 {
+		slog.InfoContext(ctx, "purged expired idempotency keys", "keys", keys)
+	}
+	if messages+tickets+conversations > 0 {
+		slog.InfoContext(ctx, "purged soft-deleted rows", "conversations", conversations, "messages", messages, "tickets", tickets,
+			"events", events, "outbox", outbox, "cutoff", cutoff)
+	}
+}
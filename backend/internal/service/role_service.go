@@ -0,0 +1,411 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"backend/internal/logging"
+	"backend/internal/model"
+	"backend/internal/repository"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrRoleNotFound    = errors.New("role not found")
+	ErrRoleInUse       = errors.New("role is assigned to users")
+	ErrBuiltInRole     = errors.New("built-in roles cannot be changed")
+	ErrInvalidRoleName = errors.New("role name must be 2-50 lowercase letters, digits, '_' or '-'")
+	ErrRoleEscalation  = errors.New("cannot grant permissions your own role does not have")
+)
+
+// Built-in role names
+const (
+	RoleAdmin      = "admin"
+	RoleSupervisor = "supervisor"
+	RoleAgent      = "agent"
+	RoleViewer     = "viewer"
+)
+
+// AllPermissions lists every permission a role can grant
+var AllPermissions = []string{
+	model.PermConversationRead,
+	model.PermConversationCreate,
+	model.PermConversationUpdate,
+	model.PermConversationDelete,
+	model.PermConversationReply,
+	model.PermConversationAssign,
+	model.PermConversationClose,
+	model.PermMessageEdit,
+	model.PermMessageDelete,
+	model.PermMessageModerate,
+	model.PermTicketRead,
+	model.PermTicketCreate,
+	model.PermTicketUpdate,
+	model.PermTicketDelete,
+	model.PermTicketStatusUpdate,
+	model.PermChannelRead,
+	model.PermChannelManage,
+	model.PermUserRead,
+	model.PermUserManage,
+	model.PermRoleManage,
+	model.PermAssignmentManage,
+	model.PermSLAManage,
+	model.PermTenantManage,
+	model.PermAuditRead,
+	model.PermDeletedRestore,
+}
+
+// builtInRoles are available to every tenant and cannot be edited
+var builtInRoles = []model.Role{
+	{
+		Name:        RoleAdmin,
+		Description: "Full access, including tenant settings, users, roles and channels",
+		Permissions: AllPermissions,
+	},
+	{
+		Name:        RoleSupervisor,
+		Description: "Manages conversations, tickets, assignment and SLAs",
+		Permissions: []string{
+			model.PermConversationRead, model.PermConversationCreate, model.PermConversationUpdate,
+			model.PermConversationDelete, model.PermConversationReply, model.PermConversationAssign,
+			model.PermConversationClose, model.PermMessageEdit, model.PermMessageDelete,
+			model.PermTicketRead, model.PermTicketCreate, model.PermTicketUpdate,
+			model.PermTicketDelete, model.PermTicketStatusUpdate,
+			model.PermChannelRead, model.PermUserRead, model.PermAssignmentManage, model.PermSLAManage,
+		},
+	},
+	{
+		Name:        RoleAgent,
+		Description: "Handles conversations and tickets; cannot delete them",
+		Permissions: []string{
+			model.PermConversationRead, model.PermConversationCreate, model.PermConversationUpdate,
+			model.PermConversationReply, model.PermConversationAssign, model.PermConversationClose,
+			model.PermMessageEdit, model.PermMessageDelete,
+			model.PermTicketRead, model.PermTicketCreate, model.PermTicketUpdate,
+			model.PermChannelRead,
+		},
+	},
+	{
+		Name:        RoleViewer,
+		Description: "Read-only access to conversations and tickets",
+		Permissions: []string{model.PermConversationRead, model.PermTicketRead, model.PermChannelRead},
+	},
+}
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,49}$`)
+
+// roleCacheTTL bounds how long a custom role's permissions are served from memory
+const roleCacheTTL = 30 * time.Second
+
+// roleInvalidationChannel is the Redis pub/sub channel on which instances announce changed
+// roles ("tenant_id/role"), so every instance drops its cached copy at once
+const roleInvalidationChannel = "roles:invalidate"
+
+type cachedRole struct {
+	permissions map[string]bool
+	expiresAt   time.Time
+}
+
+// RoleService resolves role permissions for the permission middleware and manages
+// custom tenant roles
+type RoleService struct {
+	roleRepo  *repository.RoleRepository
+	userRepo  *repository.UserRepository
+	eventRepo *repository.EventRepository
+	redis     *redis.Client
+
+	builtIn map[string]map[string]bool
+
+	mu    sync.Mutex
+	cache map[string]cachedRole // tenant_id + "/" + role name
+}
+
+func NewRoleService(roleRepo *repository.RoleRepository, userRepo *repository.UserRepository, eventRepo *repository.EventRepository, redis *redis.Client) *RoleService {
+	builtIn := make(map[string]map[string]bool, len(builtInRoles))
+	for _, r := range builtInRoles {
+		builtIn[r.Name] = permissionSet(r.Permissions)
+	}
+	return &RoleService{
+		roleRepo:  roleRepo,
+		userRepo:  userRepo,
+		eventRepo: eventRepo,
+		redis:     redis,
+		builtIn:   builtIn,
+		cache:     make(map[string]cachedRole),
+	}
+}
+
+// HasPermission reports whether role grants permission within the tenant. Unknown roles and
+// lookup errors deny.
+func (s *RoleService) HasPermission(ctx context.Context, tenantID, role, permission string) bool {
+	perms, err := s.permissions(ctx, tenantID, role)
+	if err != nil {
+		if !errors.Is(err, ErrRoleNotFound) {
+			slog.ErrorContext(ctx, "failed to resolve role", "role", role, logging.Err(err))
+		}
+		return false
+	}
+	return perms[permission]
+}
+
+// RolesWith returns the names of the built-in and tenant roles that grant permission
+func (s *RoleService) RolesWith(ctx context.Context, tenantID, permission string) ([]string, error) {
+	roles, err := s.ListRoles(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, r := range roles {
+		for _, p := range r.Permissions {
+			if p == permission {
+				names = append(names, r.Name)
+				break
+			}
+		}
+	}
+	return names, nil
+}
+
+// CheckGrant rejects assigning role unless it exists and actorRole holds every permission it
+// grants, so managing users never hands out more access than the actor has
+func (s *RoleService) CheckGrant(ctx context.Context, tenantID, actorRole, role string) error {
+	perms, err := s.permissions(ctx, tenantID, role)
+	if err != nil {
+		return err
+	}
+	return s.checkSubset(ctx, tenantID, actorRole, perms)
+}
+
+// checkSubset returns ErrRoleEscalation when perms include a permission actorRole lacks
+func (s *RoleService) checkSubset(ctx context.Context, tenantID, actorRole string, perms map[string]bool) error {
+	actor, err := s.permissions(ctx, tenantID, actorRole)
+	if errors.Is(err, ErrRoleNotFound) {
+		return ErrRoleEscalation
+	}
+	if err != nil {
+		return err
+	}
+	for p := range perms {
+		if !actor[p] {
+			return ErrRoleEscalation
+		}
+	}
+	return nil
+}
+
+func (s *RoleService) permissions(ctx context.Context, tenantID, role string) (map[string]bool, error) {
+	if perms, ok := s.builtIn[role]; ok {
+		return perms, nil
+	}
+
+	key := tenantID + "/" + role
+	s.mu.Lock()
+	entry, ok := s.cache[key]
+	s.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		if entry.permissions == nil {
+			return nil, ErrRoleNotFound
+		}
+		return entry.permissions, nil
+	}
+
+	var perms map[string]bool
+	r, err := s.roleRepo.GetByName(ctx, tenantID, role)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if r != nil {
+		perms = permissionSet(r.Permissions)
+	}
+
+	// Misses are cached too, so a bogus role in a token doesn't hit the database every request
+	s.mu.Lock()
+	s.cache[key] = cachedRole{permissions: perms, expiresAt: time.Now().Add(roleCacheTTL)}
+	s.mu.Unlock()
+
+	if perms == nil {
+		return nil, ErrRoleNotFound
+	}
+	return perms, nil
+}
+
+// invalidate drops a changed role from this instance's cache and tells the other instances to do
+// the same; if the publish fails they catch up within roleCacheTTL
+func (s *RoleService) invalidate(ctx context.Context, tenantID, role string) {
+	key := tenantID + "/" + role
+	s.drop(key)
+	if err := s.redis.Publish(ctx, roleInvalidationChannel, key).Err(); err != nil {
+		slog.WarnContext(ctx, "failed to publish role invalidation", "role", role, logging.Err(err))
+	}
+}
+
+func (s *RoleService) drop(key string) {
+	s.mu.Lock()
+	delete(s.cache, key)
+	s.mu.Unlock()
+}
+
+// Run applies the role invalidations published by other instances until ctx is cancelled. The
+// subscription reconnects on its own; changes missed meanwhile expire with roleCacheTTL.
+func (s *RoleService) Run(ctx context.Context) {
+	sub := s.redis.Subscribe(ctx, roleInvalidationChannel)
+	defer sub.Close()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			s.drop(msg.Payload)
+		}
+	}
+}
+
+// ListPermissions returns every permission name
+func (s *RoleService) ListPermissions() []string {
+	return AllPermissions
+}
+
+// ListRoles returns the built-in roles followed by the tenant's custom roles
+func (s *RoleService) ListRoles(ctx context.Context, tenantID string) ([]model.Role, error) {
+	custom, err := s.roleRepo.List(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	roles := make([]model.Role, 0, len(builtInRoles)+len(custom))
+	for _, r := range builtInRoles {
+		r.BuiltIn = true
+		roles = append(roles, r)
+	}
+	return append(roles, custom...), nil
+}
+
+// CreateRole defines a custom role; it may only grant permissions the actor's role has
+func (s *RoleService) CreateRole(ctx context.Context, tenantID, userID, actorRole string, req model.RoleRequest) (*model.Role, error) {
+	if !roleNamePattern.MatchString(req.Name) {
+		return nil, ErrInvalidRoleName
+	}
+	if _, ok := s.builtIn[req.Name]; ok {
+		return nil, ErrBuiltInRole
+	}
+	perms, err := normalizePermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkSubset(ctx, tenantID, actorRole, permissionSet(perms)); err != nil {
+		return nil, err
+	}
+	if existing, _ := s.roleRepo.GetByName(ctx, tenantID, req.Name); existing != nil {
+		return nil, errors.New("role with this name already exists")
+	}
+
+	role := &model.Role{
+		TenantID:    tenantID,
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: perms,
+	}
+	if err := s.roleRepo.Create(ctx, role); err != nil {
+		return nil, err
+	}
+
+	s.invalidate(ctx, tenantID, role.Name)
+	s.logEvent(ctx, tenantID, "role.created", role.ID, userID, role)
+	return role, nil
+}
+
+// UpdateRole replaces the description and permissions of a custom role; names are immutable
+// because users reference roles by name. It may only grant permissions the actor's role has.
+func (s *RoleService) UpdateRole(ctx context.Context, id, tenantID, userID, actorRole string, req model.RoleRequest) (*model.Role, error) {
+	role, err := s.roleRepo.GetByID(ctx, id, tenantID)
+	if err != nil {
+		return nil, ErrRoleNotFound
+	}
+	if req.Name != role.Name {
+		return nil, errors.New("role name cannot be changed")
+	}
+	perms, err := normalizePermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkSubset(ctx, tenantID, actorRole, permissionSet(perms)); err != nil {
+		return nil, err
+	}
+
+	role.Description = req.Description
+	role.Permissions = perms
+	if err := s.roleRepo.Update(ctx, tenantID, role); err != nil {
+		return nil, orNotFound(err, ErrRoleNotFound)
+	}
+
+	s.invalidate(ctx, tenantID, role.Name)
+	s.logEvent(ctx, tenantID, "role.updated", role.ID, userID, role)
+	return role, nil
+}
+
+// DeleteRole removes a custom role that no user holds
+func (s *RoleService) DeleteRole(ctx context.Context, id, tenantID, userID string) error {
+	role, err := s.roleRepo.GetByID(ctx, id, tenantID)
+	if err != nil {
+		return ErrRoleNotFound
+	}
+	n, err := s.userRepo.CountByRole(ctx, tenantID, role.Name)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrRoleInUse
+	}
+	if err := s.roleRepo.Delete(ctx, id, tenantID); err != nil {
+		return orNotFound(err, ErrRoleNotFound)
+	}
+
+	s.invalidate(ctx, tenantID, role.Name)
+	s.logEvent(ctx, tenantID, "role.deleted", id, userID, map[string]string{"name": role.Name})
+	return nil
+}
+
+func (s *RoleService) logEvent(ctx context.Context, tenantID, eventType, entityID, userID string, data interface{}) {
+	err := s.eventRepo.LogEvent(ctx, tenantID, eventType, "role", entityID, userID, data)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to log event", "event_type", eventType, logging.Err(err))
+	}
+}
+
+// normalizePermissions validates, de-duplicates and sorts permission names
+func normalizePermissions(perms []string) ([]string, error) {
+	known := permissionSet(AllPermissions)
+	set := make(map[string]bool, len(perms))
+	for _, p := range perms {
+		if !known[p] {
+			return nil, fmt.Errorf("unknown permission %q", p)
+		}
+		set[p] = true
+	}
+	out := make([]string, 0, len(set))
+	for p := range set {
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func permissionSet(perms []string) map[string]bool {
+	set := make(map[string]bool, len(perms))
+	for _, p := range perms {
+		set[p] = true
+	}
+	return set
+}
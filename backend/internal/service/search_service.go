@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"html"
+	"strings"
+
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+var (
+	ErrSearchQueryRequired = errors.New("search query is required")
+	ErrInvalidSearchType   = errors.New("invalid search type; use message, ticket or customer")
+)
+
+var snippetMarks = strings.NewReplacer(repository.SearchMarkStart, "<mark>", repository.SearchMarkStop, "</mark>")
+
+type SearchService struct {
+	searchRepo *repository.SearchRepository
+}
+
+func NewSearchService(searchRepo *repository.SearchRepository) *SearchService {
+	return &SearchService{searchRepo: searchRepo}
+}
+
+// Search runs a tenant-scoped full-text search. An empty type list searches every type.
+func (s *SearchService) Search(ctx context.Context, tenantID string, filter model.SearchFilter) ([]model.SearchResult, *model.PaginationMeta, error) {
+	filter.Query = strings.TrimSpace(filter.Query)
+	if filter.Query == "" {
+		return nil, nil, ErrSearchQueryRequired
+	}
+
+	types, err := normalizeSearchTypes(filter.Types)
+	if err != nil {
+		return nil, nil, err
+	}
+	filter.Types = types
+
+	results, total, err := s.searchRepo.Search(ctx, tenantID, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range results {
+		results[i].Title = html.EscapeString(results[i].Title)
+		results[i].Snippet = snippetMarks.Replace(html.EscapeString(results[i].Snippet))
+	}
+
+	totalPages := (total + filter.PerPage - 1) / filter.PerPage
+	meta := &model.PaginationMeta{
+		Page:       filter.Page,
+		PerPage:    filter.PerPage,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+
+	return results, meta, nil
+}
+
+func normalizeSearchTypes(types []string) ([]string, error) {
+	if len(types) == 0 {
+		return repository.SearchTypes, nil
+	}
+	seen := map[string]bool{}
+	for _, t := range types {
+		seen[t] = true
+	}
+	normalized := make([]string, 0, len(seen))
+	for _, t := range repository.SearchTypes {
+		if seen[t] {
+			normalized = append(normalized, t)
+			delete(seen, t)
+		}
+	}
+	if len(seen) > 0 {
+		return nil, ErrInvalidSearchType
+	}
+	return normalized, nil
+}
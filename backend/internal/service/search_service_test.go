@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+func TestNormalizeSearchTypes(t *testing.T) {
+	cases := []struct {
+		name    string
+		types   []string
+		want    []string
+		wantErr error
+	}{
+		{name: "none searches everything", want: repository.SearchTypes},
+		{name: "single type", types: []string{"ticket"}, want: []string{"ticket"}},
+		{name: "result order follows SearchTypes", types: []string{"customer", "message"}, want: []string{"message", "customer"}},
+		{name: "duplicates collapse", types: []string{"ticket", "ticket"}, want: []string{"ticket"}},
+		{name: "unknown type", types: []string{"message", "invoice"}, wantErr: ErrInvalidSearchType},
+		{name: "empty type", types: []string{""}, wantErr: ErrInvalidSearchType},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := normalizeSearchTypes(tc.types)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tc.wantErr)
+			}
+			if tc.wantErr == nil && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("types = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSearchRequiresQuery(t *testing.T) {
+	s := NewSearchService(nil)
+	if _, _, err := s.Search(context.Background(), "tenant-a", model.SearchFilter{Query: "  "}); !errors.Is(err, ErrSearchQueryRequired) {
+		t.Errorf("blank query: got %v, want ErrSearchQueryRequired", err)
+	}
+	if _, _, err := s.Search(context.Background(), "tenant-a", model.SearchFilter{Query: "refund", Types: []string{"invoice"}}); !errors.Is(err, ErrInvalidSearchType) {
+		t.Errorf("unknown type: got %v, want ErrInvalidSearchType", err)
+	}
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"backend/internal/logging"
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+// SLAService manages SLA policies, stamps deadlines on conversations and tickets
+// and runs the background checker that publishes sla.warning / sla.breached events
+type SLAService struct {
+	slaRepo   *repository.SLARepository
+	eventRepo *repository.EventRepository
+	store     *repository.Store
+}
+
+func NewSLAService(slaRepo *repository.SLARepository, eventRepo *repository.EventRepository, store *repository.Store) *SLAService {
+	return &SLAService{
+		slaRepo:   slaRepo,
+		eventRepo: eventRepo,
+		store:     store,
+	}
+}
+
+func (s *SLAService) ListPolicies(ctx context.Context, tenantID string) ([]model.SLAPolicy, error) {
+	return s.slaRepo.ListPolicies(ctx, tenantID)
+}
+
+func (s *SLAService) CreatePolicy(ctx context.Context, tenantID, userID string, req model.SLAPolicyRequest) (*model.SLAPolicy, error) {
+	p := &model.SLAPolicy{TenantID: tenantID}
+	applySLAPolicyRequest(p, req)
+
+	err := s.slaRepo.CreatePolicy(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logEvent(ctx, tenantID, "sla_policy.created", "sla_policy", p.ID, userID, p)
+	return p, nil
+}
+
+func (s *SLAService) UpdatePolicy(ctx context.Context, id, tenantID, userID string, req model.SLAPolicyRequest) (*model.SLAPolicy, error) {
+	p, err := s.slaRepo.GetPolicy(ctx, id, tenantID)
+	if err != nil {
+		return nil, errors.New("sla policy not found")
+	}
+	applySLAPolicyRequest(p, req)
+
+	err = s.slaRepo.UpdatePolicy(ctx, tenantID, p)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logEvent(ctx, tenantID, "sla_policy.updated", "sla_policy", p.ID, userID, p)
+	return p, nil
+}
+
+func (s *SLAService) DeletePolicy(ctx context.Context, id, tenantID, userID string) error {
+	_, err := s.slaRepo.GetPolicy(ctx, id, tenantID)
+	if err != nil {
+		return errors.New("sla policy not found")
+	}
+	err = s.slaRepo.DeletePolicy(ctx, id, tenantID)
+	if err != nil {
+		return err
+	}
+	s.logEvent(ctx, tenantID, "sla_policy.deleted", "sla_policy", id, userID, nil)
+	return nil
+}
+
+func applySLAPolicyRequest(p *model.SLAPolicy, req model.SLAPolicyRequest) {
+	p.Name = req.Name
+	p.TargetType = req.TargetType
+	p.MatchValue = req.MatchValue
+	p.FirstResponseMinutes = req.FirstResponseMinutes
+	p.ResolutionMinutes = req.ResolutionMinutes
+	p.WarningPercent = req.WarningPercent
+	if p.WarningPercent == 0 {
+		p.WarningPercent = 80
+	}
+}
+
+// StartConversationClock applies the policy matching the conversation channel
+func (s *SLAService) StartConversationClock(ctx context.Context, conv *model.Conversation) {
+	s.startClock(ctx, repository.SLATableConversations, "conversation", conv.TenantID, conv.ID, conv.Channel, conv.CreatedAt,
+		func(p *model.SLAPolicy, firstDue, resolutionDue sql.NullTime, state model.SLAState) {
+			conv.SLAPolicyID = sql.NullString{String: p.ID, Valid: true}
+			conv.FirstResponseDueAt = firstDue
+			conv.ResolutionDueAt = resolutionDue
+			conv.SLAStatus = state.Status
+			conv.FirstResponseSLAStatus = state.FirstResponse
+			conv.ResolutionSLAStatus = state.Resolution
+		})
+}
+
+// StartTicketClock applies the policy matching the ticket priority; it is also used to
+// recompute deadlines when the priority changes
+func (s *SLAService) StartTicketClock(ctx context.Context, ticket *model.Ticket) {
+	s.startClock(ctx, repository.SLATableTickets, "ticket", ticket.TenantID, ticket.ID, ticket.Priority, ticket.CreatedAt,
+		func(p *model.SLAPolicy, firstDue, resolutionDue sql.NullTime, state model.SLAState) {
+			ticket.SLAPolicyID = sql.NullString{String: p.ID, Valid: true}
+			ticket.FirstResponseDueAt = firstDue
+			ticket.ResolutionDueAt = resolutionDue
+			ticket.SLAStatus = state.Status
+			ticket.FirstResponseSLAStatus = state.FirstResponse
+			ticket.ResolutionSLAStatus = state.Resolution
+		})
+}
+
+func (s *SLAService) startClock(ctx context.Context, table, targetType, tenantID, id, matchValue string, from time.Time, apply func(*model.SLAPolicy, sql.NullTime, sql.NullTime, model.SLAState)) {
+	p, err := s.slaRepo.FindPolicy(ctx, tenantID, targetType, matchValue)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to find SLA policy", "target_type", targetType, "target_id", id, logging.Err(err))
+		return
+	}
+	state, err := s.slaRepo.SetDeadlines(ctx, table, id, tenantID, p, from)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to set SLA deadlines", "target_type", targetType, "target_id", id, logging.Err(err))
+		return
+	}
+	if p == nil {
+		return
+	}
+	apply(p,
+		sql.NullTime{Time: from.Add(time.Duration(p.FirstResponseMinutes) * time.Minute), Valid: true},
+		sql.NullTime{Time: from.Add(time.Duration(p.ResolutionMinutes) * time.Minute), Valid: true},
+		state)
+}
+
+// RecordFirstResponse stops the first-response timer of a conversation or ticket
+func (s *SLAService) RecordFirstResponse(ctx context.Context, table, id, tenantID string) {
+	if err := s.slaRepo.MarkFirstResponse(ctx, table, id, tenantID); err != nil {
+		slog.ErrorContext(ctx, "failed to record SLA first response", "target_id", id, logging.Err(err))
+	}
+}
+
+// RecordResolution stops (or, when reopened, restarts) the resolution timer
+func (s *SLAService) RecordResolution(ctx context.Context, table, id, tenantID string, resolved bool) {
+	if err := s.slaRepo.SetResolved(ctx, table, id, tenantID, resolved); err != nil {
+		slog.ErrorContext(ctx, "failed to record SLA resolution", "target_id", id, logging.Err(err))
+	}
+}
+
+// Run checks SLA deadlines every interval until ctx is cancelled
+func (s *SLAService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check moves due conversations and tickets to warning/breached and records an event for each
+// deadline that changed state; status changes, events rows and outbox records commit together
+// per table and alert level
+func (s *SLAService) Check(ctx context.Context) {
+	targets := []struct {
+		table      string
+		entityType string
+		exchange   string
+	}{
+		{repository.SLATableConversations, "conversation", "conversation.events"},
+		{repository.SLATableTickets, "ticket", "ticket.events"},
+	}
+
+	now := time.Now()
+	for _, t := range targets {
+		err := s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+			for _, deadline := range repository.SLADeadlines {
+				breached, err := tx.SLA.MarkBreached(ctx, t.table, deadline, now)
+				if err != nil {
+					return err
+				}
+				if err := s.notify(ctx, tx, t.entityType, t.exchange, "sla.breached", breached); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "SLA check failed", "table", t.table, logging.Err(err))
+			continue
+		}
+
+		err = s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+			for _, deadline := range repository.SLADeadlines {
+				warned, err := tx.SLA.MarkWarning(ctx, t.table, deadline, now)
+				if err != nil {
+					return err
+				}
+				if err := s.notify(ctx, tx, t.entityType, t.exchange, "sla.warning", warned); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "SLA check failed", "table", t.table, logging.Err(err))
+		}
+	}
+}
+
+func (s *SLAService) notify(ctx context.Context, tx *repository.Tx, entityType, exchange, eventType string, alerts []model.SLAAlert) error {
+	for _, a := range alerts {
+		payload := map[string]string{
+			"tenant_id":        a.TenantID,
+			entityType + "_id": a.ID,
+			"deadline":         a.Deadline,
+		}
+		if err := tx.Events.LogEvent(ctx, a.TenantID, eventType, entityType, a.ID, "", payload); err != nil {
+			return err
+		}
+		if err := tx.Outbox.Enqueue(ctx, a.TenantID, exchange, eventType, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SLAService) logEvent(ctx context.Context, tenantID, eventType, entityType, entityID, userID string, data interface{}) {
+	err := s.eventRepo.LogEvent(ctx, tenantID, eventType, entityType, entityID, userID, data)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to log event", "event_type", eventType, logging.Err(err))
+	}
+}
@@ -0,0 +1,167 @@
+//go:build integration
+
+package service
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"backend/internal/model"
+	"backend/internal/repository"
+
+	"github.com/google/uuid"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+)
+
+// slaFixture seeds a tenant with a user and "low"/"high" ticket policies; it runs against a
+// database with migrations/postgres_init.sql applied:
+//
+//	TEST_DATABASE_URL=postgres://... go test -tags integration ./internal/service/
+type slaFixture struct {
+	db         *sqlx.DB
+	tenantID   string
+	user       *model.User
+	high       *model.SLAPolicy
+	ticketRepo *repository.TicketRepository
+	sla        *SLAService
+	tickets    *TicketService
+}
+
+func newSLAFixture(t *testing.T) *slaFixture {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sqlx.Connect("pgx", dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	ctx := context.Background()
+
+	tenantID := uuid.New().String()
+	t.Cleanup(func() {
+		for _, table := range []string{"events", "outbox", "tickets", "sla_policies", "users"} {
+			db.Exec(`DELETE FROM `+table+` WHERE tenant_id = $1`, tenantID)
+		}
+		db.Close()
+	})
+
+	user := &model.User{TenantID: tenantID, Email: "sla-" + tenantID[:8] + "@example.test", Password: "x", Name: "SLA", Role: "admin"}
+	if err := repository.NewUserRepository(db).Create(ctx, user); err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	slaRepo := repository.NewSLARepository(db)
+	high := &model.SLAPolicy{TenantID: tenantID, Name: "high", TargetType: "ticket", MatchValue: "high", FirstResponseMinutes: 30, ResolutionMinutes: 120, WarningPercent: 80}
+	for _, p := range []*model.SLAPolicy{
+		{TenantID: tenantID, Name: "low", TargetType: "ticket", MatchValue: "low", FirstResponseMinutes: 60, ResolutionMinutes: 240, WarningPercent: 80},
+		high,
+	} {
+		if err := slaRepo.CreatePolicy(ctx, p); err != nil {
+			t.Fatalf("seed policy: %v", err)
+		}
+	}
+
+	store := repository.NewStore(db)
+	ticketRepo := repository.NewTicketRepository(db)
+	sla := NewSLAService(slaRepo, repository.NewEventRepository(db), store)
+	return &slaFixture{
+		db:         db,
+		tenantID:   tenantID,
+		user:       user,
+		high:       high,
+		ticketRepo: ticketRepo,
+		sla:        sla,
+		tickets:    NewTicketService(ticketRepo, repository.NewConversationRepository(db), store, sla),
+	}
+}
+
+func TestPriorityChangeKeepsBreach(t *testing.T) {
+	f := newSLAFixture(t)
+	ctx := context.Background()
+	db, tenantID, user, high, tickets, ticketRepo := f.db, f.tenantID, f.user, f.high, f.tickets, f.ticketRepo
+
+	ticket, err := tickets.Create(ctx, tenantID, user.ID, model.Ticket{Title: "sla", Description: "sla", Priority: "low"})
+	if err != nil {
+		t.Fatalf("create ticket: %v", err)
+	}
+	if ticket.SLAStatus != "ok" {
+		t.Fatalf("new ticket sla_status = %q, want ok", ticket.SLAStatus)
+	}
+	if _, err := db.Exec(`UPDATE tickets SET sla_status = 'breached' WHERE id = $1`, ticket.ID); err != nil {
+		t.Fatalf("breach ticket: %v", err)
+	}
+
+	updated, err := tickets.Update(ctx, ticket.ID, tenantID, user.ID, model.Ticket{Priority: "high"})
+	if err != nil {
+		t.Fatalf("update priority: %v", err)
+	}
+	if updated.SLAStatus != "breached" {
+		t.Errorf("returned sla_status = %q, want breached", updated.SLAStatus)
+	}
+
+	stored, err := ticketRepo.GetByID(ctx, ticket.ID, tenantID)
+	if err != nil {
+		t.Fatalf("reload ticket: %v", err)
+	}
+	if stored.SLAStatus != "breached" {
+		t.Errorf("stored sla_status = %q, want breached", stored.SLAStatus)
+	}
+	if stored.SLAPolicyID.String != high.ID {
+		t.Errorf("sla_policy_id = %q, want the high priority policy", stored.SLAPolicyID.String)
+	}
+	wantDue := stored.CreatedAt.Add(120 * time.Minute)
+	if !stored.ResolutionDueAt.Valid || stored.ResolutionDueAt.Time.Sub(wantDue).Abs() > time.Second {
+		t.Errorf("resolution_due_at = %v, want %v", stored.ResolutionDueAt.Time, wantDue)
+	}
+}
+
+func TestEachDeadlineAlertsOnce(t *testing.T) {
+	f := newSLAFixture(t)
+	ctx := context.Background()
+
+	ticket, err := f.tickets.Create(ctx, f.tenantID, f.user.ID, model.Ticket{Title: "sla", Description: "sla", Priority: "low"})
+	if err != nil {
+		t.Fatalf("create ticket: %v", err)
+	}
+	breaches := func() []string {
+		var deadlines []string
+		err := f.db.Select(&deadlines, `SELECT data->>'deadline' FROM events
+			WHERE tenant_id = $1 AND entity_id = $2 AND event_type = 'sla.breached' ORDER BY created_at`, f.tenantID, ticket.ID)
+		if err != nil {
+			t.Fatalf("list breaches: %v", err)
+		}
+		return deadlines
+	}
+
+	// the first response is overdue, the resolution is not
+	if _, err := f.db.Exec(`UPDATE tickets SET first_response_due_at = now() - interval '1 minute' WHERE id = $1`, ticket.ID); err != nil {
+		t.Fatalf("expire first response: %v", err)
+	}
+	f.sla.Check(ctx)
+	f.sla.Check(ctx)
+	if got := breaches(); len(got) != 1 || got[0] != repository.SLADeadlineFirstResponse {
+		t.Fatalf("breaches after first response deadline = %v, want [first_response]", got)
+	}
+
+	// answered late; the resolution deadline passes afterwards and must still alert
+	if _, err := f.db.Exec(`UPDATE tickets SET first_response_at = now(), resolution_due_at = now() - interval '1 minute' WHERE id = $1`, ticket.ID); err != nil {
+		t.Fatalf("expire resolution: %v", err)
+	}
+	f.sla.Check(ctx)
+	f.sla.Check(ctx)
+	if got := breaches(); len(got) != 2 || got[1] != repository.SLADeadlineResolution {
+		t.Fatalf("breaches after resolution deadline = %v, want [first_response resolution]", got)
+	}
+
+	stored, err := f.ticketRepo.GetByID(ctx, ticket.ID, f.tenantID)
+	if err != nil {
+		t.Fatalf("reload ticket: %v", err)
+	}
+	if stored.SLAStatus != "breached" || stored.FirstResponseSLAStatus != "breached" || stored.ResolutionSLAStatus != "breached" {
+		t.Errorf("sla state = %s/%s/%s, want breached everywhere", stored.SLAStatus, stored.FirstResponseSLAStatus, stored.ResolutionSLAStatus)
+	}
+}
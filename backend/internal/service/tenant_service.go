@@ -0,0 +1,365 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/internal/logging"
+	"backend/internal/model"
+	"backend/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrTenantNotFound  = errors.New("tenant not found")
+	ErrTenantSuspended = errors.New("tenant is suspended")
+	ErrInvalidInvite   = errors.New("invalid or expired invite")
+)
+
+// inviteTTL is how long an invite can be accepted
+const inviteTTL = 7 * 24 * time.Hour
+
+// tenantCacheTTL bounds how long a tenant's status and plan are served from memory
+const tenantCacheTTL = 30 * time.Second
+
+type cachedTenant struct {
+	status    string // empty when the tenant does not exist
+	plan      string
+	expiresAt time.Time
+}
+
+// TenantService onboards tenants, manages their plan, settings and status, and handles
+// invite-based registration
+type TenantService struct {
+	tenantRepo *repository.TenantRepository
+	userRepo   *repository.UserRepository
+	inviteRepo *repository.InviteRepository
+	eventRepo  *repository.EventRepository
+	store      *repository.Store
+	tokens     *TokenService
+	roles      *RoleService
+
+	mu    sync.Mutex
+	cache map[string]cachedTenant
+}
+
+func NewTenantService(tenantRepo *repository.TenantRepository, userRepo *repository.UserRepository, inviteRepo *repository.InviteRepository, eventRepo *repository.EventRepository, store *repository.Store, tokens *TokenService, roles *RoleService) *TenantService {
+	return &TenantService{
+		tenantRepo: tenantRepo,
+		userRepo:   userRepo,
+		inviteRepo: inviteRepo,
+		eventRepo:  eventRepo,
+		store:      store,
+		tokens:     tokens,
+		roles:      roles,
+		cache:      make(map[string]cachedTenant),
+	}
+}
+
+// Onboard creates a tenant and its first admin in one transaction
+func (s *TenantService) Onboard(ctx context.Context, req model.OnboardTenantRequest) (*model.TenantOnboarding, error) {
+	existingUser, _ := s.userRepo.GetByEmail(ctx, req.AdminEmail)
+	if existingUser != nil {
+		return nil, errors.New("user with this email already exists")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.AdminPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, errors.New("failed to hash password")
+	}
+
+	plan := req.Plan
+	if plan == "" {
+		plan = "free"
+	}
+	tenant := &model.Tenant{Name: req.TenantName, Plan: plan}
+	admin := &model.User{
+		Email:    req.AdminEmail,
+		Password: string(hashedPassword),
+		Name:     req.AdminName,
+		Role:     RoleAdmin,
+	}
+
+	err = s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Tenants.Create(ctx, tenant); err != nil {
+			return err
+		}
+		admin.TenantID = tenant.ID
+		if err := tx.Users.Create(ctx, admin); err != nil {
+			return err
+		}
+		return tx.Events.LogEvent(ctx, tenant.ID, "tenant.created", "tenant", tenant.ID, admin.ID, map[string]string{
+			"name":        tenant.Name,
+			"plan":        tenant.Plan,
+			"admin_email": admin.Email,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.TenantOnboarding{Tenant: *tenant, Admin: *admin}, nil
+}
+
+func (s *TenantService) Get(ctx context.Context, id string) (*model.Tenant, error) {
+	t, err := s.tenantRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrTenantNotFound
+	}
+	return t, nil
+}
+
+func (s *TenantService) List(ctx context.Context) ([]model.Tenant, error) {
+	return s.tenantRepo.List(ctx)
+}
+
+// Update changes the tenant name and settings; plan and status are platform-managed
+func (s *TenantService) Update(ctx context.Context, id, userID string, req model.UpdateTenantRequest) (*model.Tenant, error) {
+	t, err := s.tenantRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrTenantNotFound
+	}
+	if req.Name != "" {
+		t.Name = req.Name
+	}
+	if req.Settings != nil {
+		t.Settings = req.Settings
+	}
+	if err := s.tenantRepo.Update(ctx, t); err != nil {
+		return nil, err
+	}
+	s.logEvent(ctx, id, "tenant.updated", userID, t)
+	return t, nil
+}
+
+func (s *TenantService) SetPlan(ctx context.Context, id, plan string) (*model.Tenant, error) {
+	t, err := s.tenantRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrTenantNotFound
+	}
+	previous := t.Plan
+	t.Plan = plan
+	if err := s.tenantRepo.Update(ctx, t); err != nil {
+		return nil, err
+	}
+	s.invalidate(id)
+	s.logEvent(ctx, id, "tenant.plan_changed", "", map[string]string{"from": previous, "to": plan})
+	return t, nil
+}
+
+// Suspend blocks logins and inbound webhooks of the tenant and revokes every open session
+func (s *TenantService) Suspend(ctx context.Context, id string) (*model.Tenant, error) {
+	t, err := s.setStatus(ctx, id, repository.TenantStatusSuspended, "tenant.suspended")
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs, err := s.userRepo.ListIDsByTenant(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to list users of suspended tenant", "target_tenant_id", id, logging.Err(err))
+	}
+	for _, userID := range userIDs {
+		if err := s.tokens.RevokeUser(ctx, userID); err != nil {
+			slog.ErrorContext(ctx, "failed to revoke sessions", "target_user_id", userID, logging.Err(err))
+		}
+	}
+	return t, nil
+}
+
+func (s *TenantService) Reactivate(ctx context.Context, id string) (*model.Tenant, error) {
+	return s.setStatus(ctx, id, repository.TenantStatusActive, "tenant.reactivated")
+}
+
+func (s *TenantService) setStatus(ctx context.Context, id, status, eventType string) (*model.Tenant, error) {
+	if _, err := s.tenantRepo.GetByID(ctx, id); err != nil {
+		return nil, ErrTenantNotFound
+	}
+	if err := s.tenantRepo.SetStatus(ctx, id, status); err != nil {
+		return nil, err
+	}
+	s.invalidate(id)
+
+	s.logEvent(ctx, id, eventType, "", nil)
+	return s.tenantRepo.GetByID(ctx, id)
+}
+
+// CheckActive returns ErrTenantSuspended or ErrTenantNotFound unless the tenant is active.
+// Statuses are cached briefly since this runs on every login and webhook.
+func (s *TenantService) CheckActive(ctx context.Context, tenantID string) error {
+	entry, err := s.cached(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	switch entry.status {
+	case repository.TenantStatusActive:
+		return nil
+	case "":
+		return ErrTenantNotFound
+	default:
+		return ErrTenantSuspended
+	}
+}
+
+// Plan returns the tenant's plan, served from the same short-lived cache as CheckActive
+func (s *TenantService) Plan(ctx context.Context, tenantID string) (string, error) {
+	entry, err := s.cached(ctx, tenantID)
+	if err != nil {
+		return "", err
+	}
+	if entry.status == "" {
+		return "", ErrTenantNotFound
+	}
+	return entry.plan, nil
+}
+
+func (s *TenantService) cached(ctx context.Context, tenantID string) (cachedTenant, error) {
+	s.mu.Lock()
+	entry, ok := s.cache[tenantID]
+	s.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry, nil
+	}
+
+	entry = cachedTenant{expiresAt: time.Now().Add(tenantCacheTTL)}
+	t, err := s.tenantRepo.GetByID(ctx, tenantID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return entry, err
+	}
+	if t != nil {
+		entry.status = t.Status
+		entry.plan = t.Plan
+	}
+	s.mu.Lock()
+	s.cache[tenantID] = entry
+	s.mu.Unlock()
+	return entry, nil
+}
+
+func (s *TenantService) invalidate(tenantID string) {
+	s.mu.Lock()
+	delete(s.cache, tenantID)
+	s.mu.Unlock()
+}
+
+// CreateInvite issues an invite for email with a role the actor may grant; a pending invite for
+// the same email is replaced. The returned invite carries the token, which is not stored.
+func (s *TenantService) CreateInvite(ctx context.Context, tenantID, userID, actorRole string, req model.CreateInviteRequest) (*model.Invite, error) {
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	existingUser, _ := s.userRepo.GetByEmail(ctx, email)
+	if existingUser != nil {
+		return nil, errors.New("user with this email already exists")
+	}
+	if err := s.roles.CheckGrant(ctx, tenantID, actorRole, req.Role); err != nil {
+		return nil, err
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	inv := &model.Invite{
+		TenantID:  tenantID,
+		Email:     email,
+		Role:      req.Role,
+		TokenHash: hashToken(token),
+		InvitedBy: userID,
+		ExpiresAt: time.Now().Add(inviteTTL),
+	}
+
+	err = s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Invites.DeletePending(ctx, tenantID, email); err != nil {
+			return err
+		}
+		if err := tx.Invites.Create(ctx, inv); err != nil {
+			return err
+		}
+		return tx.Events.LogEvent(ctx, tenantID, "invite.created", "invite", inv.ID, userID, map[string]string{
+			"email": inv.Email,
+			"role":  inv.Role,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	inv.Token = token
+	return inv, nil
+}
+
+func (s *TenantService) ListInvites(ctx context.Context, tenantID string) ([]model.Invite, error) {
+	return s.inviteRepo.ListPending(ctx, tenantID)
+}
+
+func (s *TenantService) RevokeInvite(ctx context.Context, id, tenantID, userID string) error {
+	ok, err := s.inviteRepo.Delete(ctx, id, tenantID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("invite not found")
+	}
+	s.logEvent(ctx, tenantID, "invite.revoked", userID, map[string]string{"invite_id": id})
+	return nil
+}
+
+// AcceptInvite registers a user from an invite token; tenant, email and role come from the invite
+func (s *TenantService) AcceptInvite(ctx context.Context, req model.RegisterRequest) (*model.User, error) {
+	inv, err := s.inviteRepo.GetByTokenHash(ctx, hashToken(req.InviteToken))
+	if err != nil || inv.AcceptedAt != nil || time.Now().After(inv.ExpiresAt) {
+		return nil, ErrInvalidInvite
+	}
+	if err := s.CheckActive(ctx, inv.TenantID); err != nil {
+		return nil, err
+	}
+	existingUser, _ := s.userRepo.GetByEmail(ctx, inv.Email)
+	if existingUser != nil {
+		return nil, errors.New("user already exists")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, errors.New("failed to hash password")
+	}
+	user := &model.User{
+		TenantID: inv.TenantID,
+		Email:    inv.Email,
+		Password: string(hashedPassword),
+		Name:     req.Name,
+		Role:     inv.Role,
+	}
+
+	err = s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		// Consume the invite first so two concurrent registrations cannot both succeed
+		ok, err := tx.Invites.MarkAccepted(ctx, inv.ID, inv.TenantID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidInvite
+		}
+		if err := tx.Users.Create(ctx, user); err != nil {
+			return err
+		}
+		return tx.Events.LogEvent(ctx, inv.TenantID, "invite.accepted", "invite", inv.ID, user.ID, map[string]string{
+			"email": user.Email,
+			"role":  user.Role,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *TenantService) logEvent(ctx context.Context, tenantID, eventType, userID string, data interface{}) {
+	err := s.eventRepo.LogEvent(ctx, tenantID, eventType, "tenant", tenantID, userID, data)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to log event", "event_type", eventType, logging.Err(err))
+	}
+}
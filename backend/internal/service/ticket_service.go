@@ -3,35 +3,31 @@ package service
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
-	"log"
 
 	"backend/internal/model"
 
 	"backend/internal/repository"
-
-	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 type TicketService struct {
 	ticketRepo *repository.TicketRepository
 	convRepo   *repository.ConversationRepository
-	eventRepo  *repository.EventRepository
-	rabbitCh   *amqp.Channel
+	store      *repository.Store
+	slaSvc     *SLAService
 }
 
 func NewTicketService(
 	ticketRepo *repository.TicketRepository,
 	convRepo *repository.ConversationRepository,
-	eventRepo *repository.EventRepository,
-	rabbitCh *amqp.Channel,
+	store *repository.Store,
+	slaSvc *SLAService,
 ) *TicketService {
 	return &TicketService{
 		ticketRepo: ticketRepo,
 		convRepo:   convRepo,
-		eventRepo:  eventRepo,
-		rabbitCh:   rabbitCh,
+		store:      store,
+		slaSvc:     slaSvc,
 	}
 }
 
@@ -39,30 +35,30 @@ func (s *TicketService) Escalate(ctx context.Context, conversationID, tenantID,
 	// Verify conversation exists
 	conv, err := s.convRepo.GetByID(ctx, conversationID, tenantID)
 	if err != nil {
-		return nil, errors.New("conversation not found")
+		return nil, ErrConversationNotFound
 	}
 
 	// If TicketCode provided, attach existing ticket to conversation
 	if req.TicketCode != "" {
 		ticket, err := s.ticketRepo.GetByCode(ctx, req.TicketCode, tenantID)
 		if err != nil {
-			return nil, errors.New("ticket not found")
+			return nil, ErrTicketNotFound
 		}
 
-		// add mapping (many-to-many)
-		if err := s.ticketRepo.AddConversationMapping(ctx, ticket.ID, conversationID); err != nil {
+		err = s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+			// add mapping (many-to-many)
+			if err := tx.Tickets.AddConversationMapping(ctx, tenantID, ticket.ID, conversationID); err != nil {
+				return err
+			}
+			if err := tx.Events.LogEvent(ctx, tenantID, "ticket.linked", "ticket", ticket.ID, userID, map[string]string{"conversation_id": conversationID}); err != nil {
+				return err
+			}
+			return s.recordEscalation(ctx, tx, tenantID, conversationID, userID, ticket.ID)
+		})
+		if err != nil {
 			return nil, err
 		}
 
-		// Log event and publish
-		s.logEvent(ctx, tenantID, "ticket.linked", "ticket", ticket.ID, userID, map[string]string{"conversation_id": conversationID})
-		s.logEvent(ctx, tenantID, "conversation.escalated", "conversation", conversationID, userID, map[string]string{"ticket_id": ticket.ID})
-
-		s.publishEvent(ctx, "conversation.events", "conversation.escalated", map[string]interface{}{
-			"conversation_id": conversationID,
-			"ticket_id":       ticket.ID,
-		})
-
 		return ticket, nil
 	}
 
@@ -74,33 +70,26 @@ func (s *TicketService) Escalate(ctx context.Context, conversationID, tenantID,
 		Description:     req.Description,
 		Priority:        req.Priority,
 		AssignedAgentID: conv.AssignedAgentID,
-		CreatedByID:     userID,
+		CreatedByID:     sql.NullString{String: userID, Valid: true},
 	}
 
-	err = s.ticketRepo.Create(ctx, ticket)
+	err = s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Tickets.Create(ctx, ticket); err != nil {
+			return err
+		}
+		// create mapping
+		if err := tx.Tickets.AddConversationMapping(ctx, tenantID, ticket.ID, conversationID); err != nil {
+			return err
+		}
+		if err := s.recordTicketCreated(ctx, tx, tenantID, userID, ticket, conversationID); err != nil {
+			return err
+		}
+		return s.recordEscalation(ctx, tx, tenantID, conversationID, userID, ticket.ID)
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	// create mapping
-	if err := s.ticketRepo.AddConversationMapping(ctx, ticket.ID, conversationID); err != nil {
-		return nil, err
-	}
-
-	// Log event and publish to queue
-	s.logEvent(ctx, tenantID, "ticket.created", "ticket", ticket.ID, userID, ticket)
-	s.logEvent(ctx, tenantID, "conversation.escalated", "conversation", conversationID, userID, map[string]string{"ticket_id": ticket.ID})
-
-	s.publishEvent(ctx, "ticket.events", "ticket.created", map[string]interface{}{
-		"ticket_id":       ticket.ID,
-		"conversation_id": conversationID,
-		"tenant_id":       tenantID,
-	})
-
-	s.publishEvent(ctx, "conversation.events", "conversation.escalated", map[string]interface{}{
-		"conversation_id": conversationID,
-		"ticket_id":       ticket.ID,
-	})
+	s.slaSvc.StartTicketClock(ctx, ticket)
 
 	return ticket, nil
 }
@@ -110,20 +99,27 @@ func (s *TicketService) Create(ctx context.Context, tenantID string, userID stri
 	if payload.ConversationID.Valid && payload.ConversationID.String != "" {
 		_, err := s.convRepo.GetByID(ctx, payload.ConversationID.String, tenantID)
 		if err != nil {
-			return nil, errors.New("conversation not found")
+			return nil, ErrConversationNotFound
 		}
 	}
 
 	payload.TenantID = tenantID
-	payload.CreatedByID = userID
+	payload.CreatedByID = sql.NullString{String: userID, Valid: true}
 
-	err := s.ticketRepo.Create(ctx, &payload)
+	err := s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Tickets.Create(ctx, &payload); err != nil {
+			return err
+		}
+		return s.recordTicketCreated(ctx, tx, tenantID, userID, &payload, payload.ConversationID.String)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// the assigned agent is not a user of the tenant
+		return nil, ErrAgentNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
-
-	s.logEvent(ctx, tenantID, "ticket.created", "ticket", payload.ID, userID, payload)
-	s.publishEvent(ctx, "ticket.events", "ticket.created", map[string]interface{}{"ticket_id": payload.ID, "conversation_id": (func() string { if payload.ConversationID.Valid { return payload.ConversationID.String } ; return ""})(), "tenant_id": tenantID})
+	s.slaSvc.StartTicketClock(ctx, &payload)
 
 	return &payload, nil
 }
@@ -131,9 +127,11 @@ func (s *TicketService) Create(ctx context.Context, tenantID string, userID stri
 func (s *TicketService) Update(ctx context.Context, id, tenantID, userID string, req model.Ticket) (*model.Ticket, error) {
 	t, err := s.ticketRepo.GetByID(ctx, id, tenantID)
 	if err != nil {
-		return nil, errors.New("ticket not found")
+		return nil, ErrTicketNotFound
 	}
 
+	before := *t
+
 	// apply updates
 	if req.Title != "" {
 		t.Title = req.Title
@@ -152,29 +150,80 @@ func (s *TicketService) Update(ctx context.Context, id, tenantID, userID string,
 		t.Code = req.Code
 	}
 
-	err = s.ticketRepo.Update(ctx, t)
+	err = s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Tickets.Update(ctx, tenantID, t); err != nil {
+			return err
+		}
+		if err := tx.Events.LogChange(ctx, tenantID, "ticket.updated", "ticket", t.ID, userID, t, &before, t); err != nil {
+			return err
+		}
+		return tx.Outbox.Enqueue(ctx, tenantID, "ticket.events", "ticket.updated", ticketPayload(tenantID, t))
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// the assigned agent is not a user of the tenant
+		return nil, ErrAgentNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
+	if t.Priority != before.Priority {
+		s.slaSvc.StartTicketClock(ctx, t)
+	}
 
-	s.logEvent(ctx, tenantID, "ticket.updated", "ticket", t.ID, userID, t)
 	return t, nil
 }
 
 func (s *TicketService) Delete(ctx context.Context, id, tenantID, userID string) error {
-	_, err := s.ticketRepo.GetByID(ctx, id, tenantID)
+	ticket, err := s.ticketRepo.GetByID(ctx, id, tenantID)
 	if err != nil {
-		return errors.New("ticket not found")
+		return ErrTicketNotFound
 	}
-	err = s.ticketRepo.Delete(ctx, id, tenantID)
+	return s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Tickets.Delete(ctx, id, tenantID, userID); err != nil {
+			return orNotFound(err, ErrTicketNotFound)
+		}
+		if err := tx.Events.LogChange(ctx, tenantID, "ticket.deleted", "ticket", id, userID, nil, ticket, nil); err != nil {
+			return err
+		}
+		return tx.Outbox.Enqueue(ctx, tenantID, "ticket.events", "ticket.deleted", ticketPayload(tenantID, ticket))
+	})
+}
+
+// Restore undoes a soft delete of a ticket that has not been purged yet
+func (s *TicketService) Restore(ctx context.Context, id, tenantID, userID string) (*model.Ticket, error) {
+	deleted, err := s.ticketRepo.GetDeleted(ctx, id, tenantID)
 	if err != nil {
-		return err
+		return nil, ErrTicketNotFound
 	}
-	s.logEvent(ctx, tenantID, "ticket.deleted", "ticket", id, userID, nil)
-	return nil
+	after := *deleted
+	after.DeletedAt, after.DeletedBy = sql.NullTime{}, sql.NullString{}
+
+	err = s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Tickets.Restore(ctx, id, tenantID); err != nil {
+			return orNotFound(err, ErrTicketNotFound)
+		}
+		if err := tx.Events.LogChange(ctx, tenantID, "ticket.restored", "ticket", id, userID, nil, deleted, &after); err != nil {
+			return err
+		}
+		return tx.Outbox.Enqueue(ctx, tenantID, "ticket.events", "ticket.restored", ticketPayload(tenantID, &after))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.ticketRepo.GetByID(ctx, id, tenantID)
 }
 
-func (s *TicketService) List(ctx context.Context, tenantID string, filter model.TicketFilter) ([]model.Ticket, *model.PaginationMeta, error) {
+// List returns one page of tickets. Meta is a *model.CursorMeta when filter.CursorMode is set
+// and a *model.PaginationMeta (offset mode) otherwise.
+func (s *TicketService) List(ctx context.Context, tenantID string, filter model.TicketFilter) ([]model.Ticket, interface{}, error) {
+	if filter.CursorMode {
+		tickets, next, err := s.ticketRepo.ListAfter(ctx, tenantID, filter)
+		if err != nil {
+			return nil, nil, err
+		}
+		return tickets, &model.CursorMeta{PerPage: filter.PerPage, NextCursor: next, HasMore: next != ""}, nil
+	}
+
 	tickets, total, err := s.ticketRepo.List(ctx, tenantID, filter)
 	if err != nil {
 		return nil, nil, err
@@ -194,7 +243,7 @@ func (s *TicketService) List(ctx context.Context, tenantID string, filter model.
 func (s *TicketService) GetByID(ctx context.Context, id, tenantID string) (*model.Ticket, error) {
 	ticket, err := s.ticketRepo.GetByID(ctx, id, tenantID)
 	if err != nil {
-		return nil, errors.New("ticket not found")
+		return nil, ErrTicketNotFound
 	}
 	return ticket, nil
 }
@@ -202,7 +251,7 @@ func (s *TicketService) GetByID(ctx context.Context, id, tenantID string) (*mode
 func (s *TicketService) UpdateStatus(ctx context.Context, id, tenantID, userID, status string) error {
 	ticket, err := s.ticketRepo.GetByID(ctx, id, tenantID)
 	if err != nil {
-		return errors.New("ticket not found")
+		return ErrTicketNotFound
 	}
 
 	// Validate status transition
@@ -211,43 +260,67 @@ func (s *TicketService) UpdateStatus(ctx context.Context, id, tenantID, userID,
 		return errors.New("invalid status")
 	}
 
-	err = s.ticketRepo.UpdateStatus(ctx, id, status)
+	err = s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Tickets.UpdateStatus(ctx, id, tenantID, status); err != nil {
+			return err
+		}
+		after := *ticket
+		after.Status = status
+		if err := tx.Events.LogChange(ctx, tenantID, "ticket.status_updated", "ticket", id, userID, map[string]string{
+			"old_status": ticket.Status,
+			"new_status": status,
+		}, ticket, &after); err != nil {
+			return err
+		}
+		payload := ticketPayload(tenantID, ticket)
+		payload["old_status"] = ticket.Status
+		payload["status"] = status
+		return tx.Outbox.Enqueue(ctx, tenantID, "ticket.events", "ticket.status_updated", payload)
+	})
 	if err != nil {
 		return err
 	}
 
-	// Log event
-	s.logEvent(ctx, tenantID, "ticket.status_updated", "ticket", id, userID, map[string]string{
-		"old_status": ticket.Status,
-		"new_status": status,
-	})
+	// Moving off "open" counts as the first response; resolved/closed stops the resolution timer
+	if ticket.Status == "open" && status != "open" {
+		s.slaSvc.RecordFirstResponse(ctx, repository.SLATableTickets, id, tenantID)
+	}
+	s.slaSvc.RecordResolution(ctx, repository.SLATableTickets, id, tenantID, status == "resolved" || status == "closed")
 
 	return nil
 }
 
-func (s *TicketService) logEvent(ctx context.Context, tenantID, eventType, entityType, entityID, userID string, data interface{}) {
-	err := s.eventRepo.LogEvent(ctx, tenantID, eventType, entityType, entityID, userID, data)
-	if err != nil {
-		log.Printf("Failed to log event: %v", err)
+// recordTicketCreated writes the ticket.created event and outbox record in tx
+func (s *TicketService) recordTicketCreated(ctx context.Context, tx *repository.Tx, tenantID, userID string, ticket *model.Ticket, conversationID string) error {
+	if err := tx.Events.LogChange(ctx, tenantID, "ticket.created", "ticket", ticket.ID, userID, ticket, nil, ticket); err != nil {
+		return err
 	}
+	payload := ticketPayload(tenantID, ticket)
+	payload["conversation_id"] = conversationID
+	return tx.Outbox.Enqueue(ctx, tenantID, "ticket.events", "ticket.created", payload)
 }
 
-func (s *TicketService) publishEvent(ctx context.Context, exchange, routingKey string, data interface{}) {
-	if s.rabbitCh == nil {
-		return
+// ticketPayload is the ticket.events body; assigned_agent_id routes it to the agent's websocket feed
+func ticketPayload(tenantID string, t *model.Ticket) map[string]interface{} {
+	return map[string]interface{}{
+		"tenant_id":         tenantID,
+		"ticket_id":         t.ID,
+		"conversation_id":   t.ConversationID.String,
+		"assigned_agent_id": t.AssignedAgentID.String,
+		"title":             t.Title,
+		"status":            t.Status,
+		"priority":          t.Priority,
 	}
+}
 
-	body, err := json.Marshal(data)
-	if err != nil {
-		log.Printf("Failed to marshal event data: %v", err)
-		return
+// recordEscalation writes the conversation.escalated event and outbox record in tx
+func (s *TicketService) recordEscalation(ctx context.Context, tx *repository.Tx, tenantID, conversationID, userID, ticketID string) error {
+	if err := tx.Events.LogEvent(ctx, tenantID, "conversation.escalated", "conversation", conversationID, userID, map[string]string{"ticket_id": ticketID}); err != nil {
+		return err
 	}
-
-	err = s.rabbitCh.PublishWithContext(ctx, exchange, routingKey, false, false, amqp.Publishing{
-		ContentType: "application/json",
-		Body:        body,
+	return tx.Outbox.Enqueue(ctx, tenantID, "conversation.events", "conversation.escalated", map[string]interface{}{
+		"tenant_id":       tenantID,
+		"conversation_id": conversationID,
+		"ticket_id":       ticketID,
 	})
-	if err != nil {
-		log.Printf("Failed to publish event: %v", err)
-	}
 }
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+var (
+	ErrConversationNotFound = errors.New("conversation not found")
+	ErrTicketNotFound       = errors.New("ticket not found")
+)
+
+type TimelineService struct {
+	timelineRepo *repository.TimelineRepository
+	convRepo     *repository.ConversationRepository
+	ticketRepo   *repository.TicketRepository
+}
+
+func NewTimelineService(timelineRepo *repository.TimelineRepository, convRepo *repository.ConversationRepository, ticketRepo *repository.TicketRepository) *TimelineService {
+	return &TimelineService{timelineRepo: timelineRepo, convRepo: convRepo, ticketRepo: ticketRepo}
+}
+
+// ConversationTimeline returns one page of a conversation's messages and activity, oldest first
+// unless params.Sort is "-created_at"
+func (s *TimelineService) ConversationTimeline(ctx context.Context, conversationID, tenantID string, page model.PaginationParams, params model.ListParams) ([]model.TimelineEntry, *model.CursorMeta, error) {
+	if _, err := s.convRepo.GetByID(ctx, conversationID, tenantID); err != nil {
+		return nil, nil, ErrConversationNotFound
+	}
+	entries, next, err := s.timelineRepo.ListConversation(ctx, tenantID, conversationID, params, page.PerPage)
+	if err != nil {
+		return nil, nil, err
+	}
+	return entries, &model.CursorMeta{PerPage: page.PerPage, NextCursor: next, HasMore: next != ""}, nil
+}
+
+// TicketTimeline returns one page of a ticket's activity, including the escalation that created it
+func (s *TimelineService) TicketTimeline(ctx context.Context, ticketID, tenantID string, page model.PaginationParams, params model.ListParams) ([]model.TimelineEntry, *model.CursorMeta, error) {
+	if _, err := s.ticketRepo.GetByID(ctx, ticketID, tenantID); err != nil {
+		return nil, nil, ErrTicketNotFound
+	}
+	entries, next, err := s.timelineRepo.ListTicket(ctx, tenantID, ticketID, params, page.PerPage)
+	if err != nil {
+		return nil, nil, err
+	}
+	return entries, &model.CursorMeta{PerPage: page.PerPage, NextCursor: next, HasMore: next != ""}, nil
+}
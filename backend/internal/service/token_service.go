@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"backend/internal/logging"
+	"backend/internal/model"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+// Redis keys used for server-side token state
+const (
+	refreshTokenKey  = "auth:refresh:"         // + sha256(token) -> refreshSession
+	userRefreshKey   = "auth:user_refresh:"    // + user id -> set of refresh token hashes
+	revokedJTIKey    = "auth:revoked:"         // + jti, kept until the access token expires
+	userRevokedAtKey = "auth:user_revoked_at:" // + user id -> unix milliseconds; older access tokens are rejected
+)
+
+type refreshSession struct {
+	UserID   string `json:"user_id"`
+	TenantID string `json:"tenant_id"`
+}
+
+// TokenService issues short-lived access JWTs (with a jti) and opaque refresh tokens stored in
+// Redis, and keeps the revocation state checked by the auth middleware and the websocket
+type TokenService struct {
+	redis      *redis.Client
+	jwtSecret  string
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewTokenService(redis *redis.Client, jwtSecret string, accessTTL, refreshTTL time.Duration) *TokenService {
+	return &TokenService{
+		redis:      redis,
+		jwtSecret:  jwtSecret,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+	}
+}
+
+// Issue creates an access token and a refresh token for user
+func (s *TokenService) Issue(ctx context.Context, user *model.User) (*model.LoginResponse, error) {
+	access, err := s.accessToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	session, _ := json.Marshal(refreshSession{UserID: user.ID, TenantID: user.TenantID})
+	hash := hashToken(refresh)
+
+	pipe := s.redis.TxPipeline()
+	pipe.Set(ctx, refreshTokenKey+hash, session, s.refreshTTL)
+	pipe.SAdd(ctx, userRefreshKey+user.ID, hash)
+	pipe.Expire(ctx, userRefreshKey+user.ID, s.refreshTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	return &model.LoginResponse{
+		Token:        access,
+		RefreshToken: refresh,
+		ExpiresIn:    int(s.accessTTL.Seconds()),
+		User:         *user,
+	}, nil
+}
+
+// Consume validates a refresh token and deletes it so it can only be used once; it returns
+// the user the token was issued to
+func (s *TokenService) Consume(ctx context.Context, refreshToken string) (string, error) {
+	hash := hashToken(refreshToken)
+	raw, err := s.redis.GetDel(ctx, refreshTokenKey+hash).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var session refreshSession
+	if err := json.Unmarshal([]byte(raw), &session); err != nil {
+		return "", ErrInvalidRefreshToken
+	}
+	s.redis.SRem(ctx, userRefreshKey+session.UserID, hash)
+	return session.UserID, nil
+}
+
+// RevokeAccess blocks an access token until it would have expired anyway
+func (s *TokenService) RevokeAccess(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if jti == "" || ttl <= 0 {
+		return nil
+	}
+	return s.redis.Set(ctx, revokedJTIKey+jti, 1, ttl).Err()
+}
+
+// RevokeRefresh deletes a refresh token; unknown tokens are ignored
+func (s *TokenService) RevokeRefresh(ctx context.Context, refreshToken string) error {
+	_, err := s.Consume(ctx, refreshToken)
+	if errors.Is(err, ErrInvalidRefreshToken) {
+		return nil
+	}
+	return err
+}
+
+// RevokeUser invalidates every access token issued to the user so far and all their refresh tokens
+func (s *TokenService) RevokeUser(ctx context.Context, userID string) error {
+	hashes, err := s.redis.SMembers(ctx, userRefreshKey+userID).Result()
+	if err != nil {
+		return err
+	}
+
+	pipe := s.redis.TxPipeline()
+	pipe.Set(ctx, userRevokedAtKey+userID, time.Now().UnixMilli(), s.accessTTL)
+	for _, h := range hashes {
+		pipe.Del(ctx, refreshTokenKey+h)
+	}
+	pipe.Del(ctx, userRefreshKey+userID)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// IsRevoked reports whether an access token was revoked individually or by a user-wide
+// revocation. A user-wide revocation rejects tokens issued in an earlier millisecond, so a
+// login right after a role change is not rejected. Redis errors are logged and treated as
+// not revoked.
+func (s *TokenService) IsRevoked(ctx context.Context, jti, userID string, issuedAt time.Time) bool {
+	if jti == "" {
+		return true
+	}
+
+	pipe := s.redis.Pipeline()
+	revoked := pipe.Exists(ctx, revokedJTIKey+jti)
+	revokedAt := pipe.Get(ctx, userRevokedAtKey+userID)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		slog.WarnContext(ctx, "auth: revocation check failed", logging.Err(err))
+		return false
+	}
+
+	if revoked.Val() > 0 {
+		return true
+	}
+	if ts, err := strconv.ParseInt(revokedAt.Val(), 10, 64); err == nil && issuedAt.UnixMilli() < ts {
+		return true
+	}
+	return false
+}
+
+func (s *TokenService) accessToken(user *model.User) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"jti":       uuid.New().String(),
+		"user_id":   user.ID,
+		"tenant_id": user.TenantID,
+		"email":     user.Email,
+		"role":      user.Role,
+		"exp":       now.Add(s.accessTTL).Unix(),
+		"iat":       now.Unix(),
+		"iat_ms":    now.UnixMilli(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(s.jwtSecret))
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
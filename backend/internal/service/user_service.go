@@ -3,31 +3,42 @@ package service
 import (
 	"context"
 	"errors"
+	"log/slog"
 
+	"backend/internal/logging"
 	"backend/internal/model"
 	"backend/internal/repository"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+var ErrUserNotFound = errors.New("user not found")
+
 type UserService struct {
 	userRepo *repository.UserRepository
+	store    *repository.Store
+	tokens   *TokenService
+	roles    *RoleService
 }
 
-func NewUserService(userRepo *repository.UserRepository) *UserService {
-	return &UserService{userRepo: userRepo}
+func NewUserService(userRepo *repository.UserRepository, store *repository.Store, tokens *TokenService, roles *RoleService) *UserService {
+	return &UserService{userRepo: userRepo, store: store, tokens: tokens, roles: roles}
 }
 
 func (s *UserService) List(ctx context.Context, tenantID string) ([]model.User, error) {
 	return s.userRepo.GetByTenantID(ctx, tenantID)
 }
 
-func (s *UserService) Create(ctx context.Context, tenantID string, req model.CreateUserRequest) (*model.User, error) {
+// Create adds a user with a role the actor may grant (see RoleService.CheckGrant)
+func (s *UserService) Create(ctx context.Context, tenantID, actorID, actorRole string, req model.CreateUserRequest) (*model.User, error) {
 	// Check if user exists
 	existingUser, _ := s.userRepo.GetByEmail(ctx, req.Email)
 	if existingUser != nil {
 		return nil, errors.New("user with this email already exists")
 	}
+	if err := s.roles.CheckGrant(ctx, tenantID, actorRole, req.Role); err != nil {
+		return nil, err
+	}
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
@@ -43,23 +54,26 @@ func (s *UserService) Create(ctx context.Context, tenantID string, req model.Cre
 		Role:     req.Role,
 	}
 
-	err = s.userRepo.Create(ctx, user)
+	err = s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Users.Create(ctx, user); err != nil {
+			return err
+		}
+		return logUserChange(ctx, tx, tenantID, "user.created", user.ID, actorID, nil, user)
+	})
 	if err != nil {
 		return nil, err
 	}
-
 	return user, nil
 }
 
-func (s *UserService) Update(ctx context.Context, id, tenantID string, req model.UpdateUserRequest) (*model.User, error) {
-	user, err := s.userRepo.GetByID(ctx, id)
+// Update changes a user's details. A role change needs the actor to hold every permission of
+// both the user's current and new role, so nobody can promote or demote past their own access.
+func (s *UserService) Update(ctx context.Context, id, tenantID, actorID, actorRole string, req model.UpdateUserRequest) (*model.User, error) {
+	user, err := s.userRepo.GetByID(ctx, id, tenantID)
 	if err != nil {
-		return nil, errors.New("user not found")
-	}
-
-	if user.TenantID != tenantID {
-		return nil, errors.New("user not found")
+		return nil, ErrUserNotFound
 	}
+	before := *user
 
 	if req.Email != "" {
 		user.Email = req.Email
@@ -67,27 +81,66 @@ func (s *UserService) Update(ctx context.Context, id, tenantID string, req model
 	if req.Name != "" {
 		user.Name = req.Name
 	}
+	roleChanged := req.Role != "" && req.Role != user.Role
+	if roleChanged {
+		for _, role := range []string{user.Role, req.Role} {
+			if err := s.roles.CheckGrant(ctx, tenantID, actorRole, role); err != nil {
+				return nil, err
+			}
+		}
+	}
 	if req.Role != "" {
 		user.Role = req.Role
 	}
 
-	err = s.userRepo.Update(ctx, user)
+	eventType := "user.updated"
+	if roleChanged {
+		eventType = "user.role_changed"
+	}
+	err = s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Users.Update(ctx, tenantID, user); err != nil {
+			return orNotFound(err, ErrUserNotFound)
+		}
+		return logUserChange(ctx, tx, tenantID, eventType, user.ID, actorID, &before, user)
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	if roleChanged {
+		// Tokens carry the role, so existing sessions must re-authenticate
+		s.revokeSessions(ctx, user.ID)
+	}
 	return user, nil
 }
 
-func (s *UserService) Delete(ctx context.Context, id, tenantID string) error {
-	user, err := s.userRepo.GetByID(ctx, id)
+func (s *UserService) Delete(ctx context.Context, id, tenantID, actorID string) error {
+	user, err := s.userRepo.GetByID(ctx, id, tenantID)
 	if err != nil {
-		return errors.New("user not found")
+		return ErrUserNotFound
 	}
 
-	if user.TenantID != tenantID {
-		return errors.New("user not found")
+	err = s.store.WithinTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.Users.Delete(ctx, id, tenantID); err != nil {
+			return orNotFound(err, ErrUserNotFound)
+		}
+		return logUserChange(ctx, tx, tenantID, "user.deleted", id, actorID, user, nil)
+	})
+	if err != nil {
+		return err
 	}
 
-	return s.userRepo.Delete(ctx, id)
+	s.revokeSessions(ctx, id)
+	return nil
+}
+
+func (s *UserService) revokeSessions(ctx context.Context, userID string) {
+	if err := s.tokens.RevokeUser(ctx, userID); err != nil {
+		slog.ErrorContext(ctx, "failed to revoke sessions", "target_user_id", userID, logging.Err(err))
+	}
+}
+
+// logUserChange records the audit event of a change in its transaction
+func logUserChange(ctx context.Context, tx *repository.Tx, tenantID, eventType, userID, actorID string, before, after *model.User) error {
+	return tx.Events.LogChange(ctx, tenantID, eventType, "user", userID, actorID, nil, before, after)
 }
@@ -82,7 +82,7 @@ CREATE TABLE IF NOT EXISTS tickets (
   status VARCHAR(20) NOT NULL DEFAULT 'open',
   priority VARCHAR(20) NOT NULL DEFAULT 'medium',
   assigned_agent_id VARCHAR(36) NULL REFERENCES users(id) ON DELETE SET NULL,
-  created_by_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_by_id VARCHAR(36) NULL REFERENCES users(id) ON DELETE SET NULL,
   created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
   updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
 );
@@ -123,3 +123,231 @@ WHERE NOT EXISTS (SELECT 1 FROM users WHERE email='localadmin@sociomile.com');
 INSERT INTO users (id, tenant_id, email, password, name, role)
 SELECT 'user_local_agent', 'tenant_001', 'localagent@sociomile.com', crypt('agent123', gen_salt('bf', 10)), 'Local Agent', 'agent'
 WHERE NOT EXISTS (SELECT 1 FROM users WHERE email='localagent@sociomile.com');
+
+-- Per-tenant auto-assignment settings
+CREATE TABLE IF NOT EXISTS assignment_settings (
+  tenant_id VARCHAR(36) PRIMARY KEY,
+  enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  strategy VARCHAR(30) NOT NULL DEFAULT 'round_robin',
+  max_open_per_agent INT NOT NULL DEFAULT 0,
+  last_assigned_agent_id VARCHAR(36) NULL REFERENCES users(id) ON DELETE SET NULL,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Channels an agent is skilled for (used by the channel_match strategy)
+CREATE TABLE IF NOT EXISTS agent_channels (
+  user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  channel VARCHAR(50) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (user_id, channel)
+);
+CREATE INDEX IF NOT EXISTS idx_conversations_assigned_agent_id ON conversations(assigned_agent_id);
+
+-- SLA policies: first-response and resolution targets per ticket priority / conversation channel.
+-- An empty match_value is the tenant default for that target type.
+CREATE TABLE IF NOT EXISTS sla_policies (
+  id VARCHAR(36) PRIMARY KEY,
+  tenant_id VARCHAR(36) NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  target_type VARCHAR(20) NOT NULL,
+  match_value VARCHAR(50) NOT NULL DEFAULT '',
+  first_response_minutes INT NOT NULL,
+  resolution_minutes INT NOT NULL,
+  warning_percent INT NOT NULL DEFAULT 80,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (tenant_id, target_type, match_value)
+);
+
+-- SLA tracking columns on conversations and tickets
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS sla_policy_id VARCHAR(36) NULL REFERENCES sla_policies(id) ON DELETE SET NULL;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS first_response_due_at TIMESTAMPTZ NULL;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS resolution_due_at TIMESTAMPTZ NULL;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS first_response_at TIMESTAMPTZ NULL;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS resolved_at TIMESTAMPTZ NULL;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS sla_status VARCHAR(20) NOT NULL DEFAULT 'none';
+CREATE INDEX IF NOT EXISTS idx_conversations_sla_status ON conversations(sla_status);
+
+ALTER TABLE tickets ADD COLUMN IF NOT EXISTS sla_policy_id VARCHAR(36) NULL REFERENCES sla_policies(id) ON DELETE SET NULL;
+ALTER TABLE tickets ADD COLUMN IF NOT EXISTS first_response_due_at TIMESTAMPTZ NULL;
+ALTER TABLE tickets ADD COLUMN IF NOT EXISTS resolution_due_at TIMESTAMPTZ NULL;
+ALTER TABLE tickets ADD COLUMN IF NOT EXISTS first_response_at TIMESTAMPTZ NULL;
+ALTER TABLE tickets ADD COLUMN IF NOT EXISTS resolved_at TIMESTAMPTZ NULL;
+ALTER TABLE tickets ADD COLUMN IF NOT EXISTS sla_status VARCHAR(20) NOT NULL DEFAULT 'none';
+CREATE INDEX IF NOT EXISTS idx_tickets_sla_status ON tickets(sla_status);
+
+-- Warning/breach state per deadline, so each deadline raises its own sla.warning and
+-- sla.breached. Existing rows take their overall state for the first response; the resolution
+-- deadline only keeps a breach when it is already overdue.
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS first_response_sla_status VARCHAR(20) NOT NULL DEFAULT 'none';
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS resolution_sla_status VARCHAR(20) NOT NULL DEFAULT 'none';
+UPDATE conversations SET first_response_sla_status = sla_status,
+  resolution_sla_status = CASE WHEN sla_status = 'breached' AND resolution_due_at <= now() THEN 'breached' ELSE 'ok' END
+  WHERE sla_status <> 'none' AND first_response_sla_status = 'none' AND resolution_sla_status = 'none';
+ALTER TABLE tickets ADD COLUMN IF NOT EXISTS first_response_sla_status VARCHAR(20) NOT NULL DEFAULT 'none';
+ALTER TABLE tickets ADD COLUMN IF NOT EXISTS resolution_sla_status VARCHAR(20) NOT NULL DEFAULT 'none';
+UPDATE tickets SET first_response_sla_status = sla_status,
+  resolution_sla_status = CASE WHEN sla_status = 'breached' AND resolution_due_at <= now() THEN 'breached' ELSE 'ok' END
+  WHERE sla_status <> 'none' AND first_response_sla_status = 'none' AND resolution_sla_status = 'none';
+
+-- Outbound delivery of agent replies
+ALTER TABLE channels ADD COLUMN IF NOT EXISTS outbound_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS delivery_status VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS delivery_attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS delivery_error TEXT NOT NULL DEFAULT '';
+
+-- Per-channel secret for HMAC-signed inbound webhooks
+ALTER TABLE channels ADD COLUMN IF NOT EXISTS webhook_secret VARCHAR(64) NOT NULL DEFAULT '';
+
+-- Idempotency: provider message IDs on inbound webhooks and Idempotency-Key on agent sends.
+-- A row is reserved before processing and completed with the resulting ids.
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS external_message_id VARCHAR(255) NOT NULL DEFAULT '';
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  tenant_id VARCHAR(36) NOT NULL,
+  scope VARCHAR(300) NOT NULL,
+  key VARCHAR(255) NOT NULL,
+  conversation_id VARCHAR(36) NULL,
+  message_id VARCHAR(36) NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (tenant_id, scope, key)
+);
+-- Scopes embed a channel slug (up to 255 characters); keys expire after IDEMPOTENCY_KEY_TTL_HOURS
+ALTER TABLE idempotency_keys ALTER COLUMN scope TYPE VARCHAR(300);
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created ON idempotency_keys(created_at);
+-- Messages carry the tenant and channel of their conversation so a provider message id is
+-- unique per tenant and channel even after its idempotency key expired
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(36) NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS channel VARCHAR(50) NOT NULL DEFAULT '';
+UPDATE messages m SET tenant_id = c.tenant_id, channel = c.channel
+  FROM conversations c WHERE c.id = m.conversation_id AND m.tenant_id = '';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_external_id ON messages(tenant_id, channel, external_message_id)
+  WHERE external_message_id <> '';
+
+-- Transactional outbox: written in the same transaction as the change and its events row,
+-- then published to RabbitMQ by the relay
+CREATE TABLE IF NOT EXISTS outbox (
+  id VARCHAR(36) PRIMARY KEY,
+  tenant_id VARCHAR(36) NOT NULL,
+  exchange VARCHAR(100) NOT NULL,
+  routing_key VARCHAR(100) NOT NULL,
+  payload JSONB NOT NULL,
+  status VARCHAR(20) NOT NULL DEFAULT 'pending',
+  attempts INT NOT NULL DEFAULT 0,
+  last_error TEXT NOT NULL DEFAULT '',
+  available_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  sent_at TIMESTAMPTZ NULL
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(created_at) WHERE status = 'pending';
+-- The relay leases claimed records as 'publishing' (available_at = lease end) and publishes
+-- them outside any transaction; records of a relay that died are claimed again after the lease
+DROP INDEX IF EXISTS idx_outbox_pending;
+CREATE INDEX IF NOT EXISTS idx_outbox_unsent ON outbox(created_at) WHERE status IN ('pending', 'publishing');
+
+-- Full-text search: expression GIN indexes; queries must use the exact same expressions
+CREATE INDEX IF NOT EXISTS idx_messages_search ON messages USING GIN (to_tsvector('simple', message));
+CREATE INDEX IF NOT EXISTS idx_tickets_search ON tickets USING GIN (to_tsvector('simple', title || ' ' || description || ' ' || COALESCE(code, '')));
+CREATE INDEX IF NOT EXISTS idx_customers_search ON customers USING GIN (to_tsvector('simple', name || ' ' || external_id));
+
+-- Keyset pagination of conversation and ticket lists (default sorts)
+CREATE INDEX IF NOT EXISTS idx_conversations_tenant_updated ON conversations(tenant_id, updated_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_tickets_tenant_created ON tickets(tenant_id, created_at DESC, id DESC);
+
+-- Paged message history
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_created ON messages(conversation_id, created_at, id);
+
+-- Custom per-tenant roles; built-in roles (admin, supervisor, agent, viewer) live in code
+CREATE TABLE IF NOT EXISTS roles (
+  id VARCHAR(36) PRIMARY KEY,
+  tenant_id VARCHAR(36) NOT NULL,
+  name VARCHAR(50) NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  permissions JSONB NOT NULL DEFAULT '[]',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (tenant_id, name)
+);
+ALTER TABLE users ALTER COLUMN role TYPE VARCHAR(50);
+
+-- Tenants; existing tenant ids are backfilled as active tenants named after their id
+CREATE TABLE IF NOT EXISTS tenants (
+  id VARCHAR(36) PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  plan VARCHAR(20) NOT NULL DEFAULT 'free',
+  settings JSONB NOT NULL DEFAULT '{}',
+  status VARCHAR(20) NOT NULL DEFAULT 'active',
+  suspended_at TIMESTAMPTZ NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+INSERT INTO tenants (id, name)
+SELECT tenant_id, tenant_id FROM (
+  SELECT tenant_id FROM users
+  UNION SELECT tenant_id FROM channels
+  UNION SELECT tenant_id FROM conversations
+) t
+ON CONFLICT (id) DO NOTHING;
+
+-- Invites to join a tenant; the token itself is only shown once, its sha256 is stored
+CREATE TABLE IF NOT EXISTS invites (
+  id VARCHAR(36) PRIMARY KEY,
+  tenant_id VARCHAR(36) NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+  email VARCHAR(255) NOT NULL,
+  role VARCHAR(50) NOT NULL,
+  token_hash VARCHAR(64) NOT NULL UNIQUE,
+  invited_by VARCHAR(36) NOT NULL DEFAULT '',
+  expires_at TIMESTAMPTZ NOT NULL,
+  accepted_at TIMESTAMPTZ NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_invites_tenant ON invites(tenant_id, created_at DESC);
+
+-- Per-agent read markers; a conversation's unread count is its customer messages after last_read_at
+CREATE TABLE IF NOT EXISTS conversation_reads (
+  conversation_id VARCHAR(36) NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+  user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  tenant_id VARCHAR(36) NOT NULL,
+  last_read_message_id VARCHAR(36) NOT NULL DEFAULT '',
+  last_read_at TIMESTAMPTZ NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (conversation_id, user_id)
+);
+
+-- Agent capacity (0 = tenant default) and presence-aware auto-assignment
+ALTER TABLE users ADD COLUMN IF NOT EXISTS max_conversations INT NOT NULL DEFAULT 0;
+ALTER TABLE assignment_settings ADD COLUMN IF NOT EXISTS require_online BOOLEAN NOT NULL DEFAULT false;
+
+-- Conversation and ticket timelines read events per entity in time order
+CREATE INDEX IF NOT EXISTS idx_events_entity ON events(tenant_id, entity_type, entity_id, created_at);
+
+-- Audit snapshots of the entity before and after a change (NULL on creation / deletion)
+ALTER TABLE events ADD COLUMN IF NOT EXISTS before_data JSONB NULL;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS after_data JSONB NULL;
+CREATE INDEX IF NOT EXISTS idx_events_tenant_created ON events(tenant_id, created_at DESC, id DESC);
+
+-- Soft deletion: rows stay restorable until the retention job purges them
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ NULL;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS deleted_by VARCHAR(36) NULL;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ NULL;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_by VARCHAR(36) NULL;
+ALTER TABLE tickets ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ NULL;
+ALTER TABLE tickets ADD COLUMN IF NOT EXISTS deleted_by VARCHAR(36) NULL;
+CREATE INDEX IF NOT EXISTS idx_conversations_deleted ON conversations(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_messages_deleted ON messages(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_tickets_deleted ON tickets(deleted_at) WHERE deleted_at IS NOT NULL;
+-- Deleting a user keeps the tickets they created instead of cascading past soft deletion
+ALTER TABLE tickets ALTER COLUMN created_by_id DROP NOT NULL;
+ALTER TABLE tickets DROP CONSTRAINT IF EXISTS tickets_created_by_id_fkey;
+ALTER TABLE tickets ADD CONSTRAINT tickets_created_by_id_fkey FOREIGN KEY (created_by_id) REFERENCES users(id) ON DELETE SET NULL;
+
+-- Message edits: the current text stays in messages, each previous version is kept here
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS edited_at TIMESTAMPTZ NULL;
+CREATE TABLE IF NOT EXISTS message_edits (
+  id VARCHAR(36) PRIMARY KEY,
+  tenant_id VARCHAR(36) NOT NULL,
+  message_id VARCHAR(36) NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+  previous_message TEXT NOT NULL,
+  edited_by VARCHAR(36) NOT NULL,
+  edited_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_message_edits_message ON message_edits(message_id, edited_at);
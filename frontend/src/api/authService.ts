@@ -1,7 +1,8 @@
 import client from './client';
+import { useAuthStore } from '../store/authStore';
 
 export type LoginPayload = { email: string; password: string };
-export type RegisterPayload = { email: string; password: string; name?: string };
+export type RegisterPayload = { invite_token: string; password: string; name: string };
 
 export const login = async (payload: LoginPayload) => {
   const res = await client.post('/auth/login', payload);
@@ -13,7 +14,9 @@ export const register = async (payload: RegisterPayload) => {
   return res.data;
 };
 
+// Revokes the access and refresh tokens server-side
 export const logout = async () => {
-  // implement if backend supports logout endpoint
+  const refreshToken = useAuthStore.getState().refreshToken;
+  await client.post('/auth/logout', { refresh_token: refreshToken ?? '' });
   return true;
 };
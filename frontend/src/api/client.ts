@@ -24,9 +24,40 @@ apiClient.interceptors.request.use((config) => {
   return config;
 });
 
+// A single refresh is shared by all requests that fail with 401 at the same time
+let refreshing: Promise<string | null> | null = null;
+
+const refreshAccessToken = async (): Promise<string | null> => {
+  const refreshToken = useAuthStore.getState().refreshToken;
+  if (!refreshToken) return null;
+  try {
+    // plain axios so the refresh call itself never goes through these interceptors
+    const res = await axios.post(`${API_BASE_URL}/auth/refresh`, { refresh_token: refreshToken });
+    const data = res.data?.data;
+    if (!data?.token || !data?.refresh_token) return null;
+    useAuthStore.getState().setTokens(data.token, data.refresh_token);
+    return data.token;
+  } catch {
+    return null;
+  }
+};
+
 apiClient.interceptors.response.use(
   (response) => response,
-  (error) => {
+  async (error) => {
+    const original = error.config;
+    if (error.response?.status === 401 && original && !original._retried && !original.url?.startsWith('/auth/')) {
+      original._retried = true;
+      refreshing = refreshing ?? refreshAccessToken().finally(() => {
+        refreshing = null;
+      });
+      const token = await refreshing;
+      if (token) {
+        original.headers = original.headers || {};
+        original.headers.Authorization = `Bearer ${token}`;
+        return apiClient(original);
+      }
+    }
     if (error.response?.status === 401) {
       useAuthStore.getState().logout();
       window.location.href = '/login';
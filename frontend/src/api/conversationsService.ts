@@ -46,3 +46,8 @@ export const closeConversation = async (conversationId: string) => {
   const res = await client.post(`/conversations/${conversationId}/close`);
   return res.data;
 };
+
+export const markConversationRead = async (conversationId: string, messageId?: string) => {
+  const res = await client.post(`/conversations/${conversationId}/read`, messageId ? { message_id: messageId } : {});
+  return res.data;
+};
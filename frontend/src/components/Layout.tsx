@@ -10,6 +10,7 @@ import {
   Menu
 } from 'lucide-react';
 import { useState } from 'react';
+import { logout as apiLogout } from '../api/authService';
 
 export function Layout() {
   const { user, logout } = useAuthStore();
@@ -17,8 +18,13 @@ export function Layout() {
   const [sidebarOpen, setSidebarOpen] = useState(false);
 
   const handleLogout = () => {
-    logout();
-    navigate('/login');
+    // revoke server-side tokens; log out locally even if the call fails
+    apiLogout()
+      .catch(() => undefined)
+      .finally(() => {
+        logout();
+        navigate('/login');
+      });
   };
 
   const navItems = [
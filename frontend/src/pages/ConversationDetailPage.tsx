@@ -3,7 +3,7 @@ import { useParams, useNavigate } from 'react-router-dom';
 import { ArrowLeft, Send, User, AlertTriangle, UserPlus, CheckCircle, Ticket } from 'lucide-react';
 import { useAuthStore } from '../store/authStore';
 import realtime from '../utils/realtime';
-import { getConversation, listTicketsForConversation, setSelectedTicket, assignConversation, closeConversation } from '../api/conversationsService';
+import { getConversation, listTicketsForConversation, setSelectedTicket, assignConversation, closeConversation, markConversationRead } from '../api/conversationsService';
 import { escalateTicket, listTickets } from '../api/ticketsService';
 import { createMessage } from '../api/messagesService';
 
@@ -170,6 +170,7 @@ export function ConversationDetailPage() {
         setConversation(conv);
         const msgs = Array.isArray(data?.messages) ? data.messages : [];
         setMessages(msgs);
+        markConversationRead(_id as string).catch(() => {});
         // load tickets for this conversation (may be empty)
         try {
           const t = await listTicketsForConversation(_id as string);
@@ -185,6 +186,7 @@ export function ConversationDetailPage() {
 
     realtime.connect();
     wsConnected.current = true;
+    const unsubscribe = realtime.subscribe('conversation', String(_id));
 
     const unsub = realtime.on('*', (msg) => {
       try {
@@ -202,6 +204,7 @@ export function ConversationDetailPage() {
     return () => {
       mounted = false;
       try { unsub(); } catch (e) {}
+      unsubscribe();
       if (wsConnected.current) realtime.disconnect();
     };
   }, [_id]);
@@ -121,6 +121,11 @@ export function ConversationsPage() {
                 </div>
                 <div className="text-right">
                   <p className="text-sm text-gray-400">{formatTimeSafe(conv.last_message_at)}</p>
+                  {!!conv.unread_count && (
+                    <span className="inline-block mt-1 px-2 py-0.5 text-xs font-semibold text-white bg-blue-600 rounded-full">
+                      {conv.unread_count}
+                    </span>
+                  )}
                   {conv.assigned_agent_name && (
                     <p className="text-xs text-gray-500 mt-1">
                       Assigned: {conv.assigned_agent_name}
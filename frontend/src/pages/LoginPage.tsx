@@ -29,11 +29,12 @@ export function LoginPage() {
       setLoading(true);
       // Call backend login
       const resp = await apiLogin({ email, password });
-      // resp expected: { success: true, data: { token, user } }
+      // resp expected: { success: true, data: { token, refresh_token, user } }
       const token = resp?.data?.token ?? resp?.token;
+      const refreshToken = resp?.data?.refresh_token ?? resp?.refresh_token;
       const user = resp?.data?.user ?? resp?.user;
       if (token && user) {
-        login(user, token);
+        login(user, token, refreshToken);
         navigate('/dashboard');
       } else {
         setError('Login failed');
@@ -36,7 +36,7 @@ export function UsersPage() {
     if (editingUser) {
       // update via API if possible, fallback to local
       {
-        const roleValue = formData.role as 'admin' | 'agent';
+        const roleValue = formData.role;
         apiUpdateUser(editingUser.id, { name: formData.name, email: formData.email, role: roleValue })
           .then((res) => {
             const updated = res?.data ?? res;
@@ -48,7 +48,7 @@ export function UsersPage() {
       }
     } else {
       // create via API; if not authorized, fall back to local optimistic
-      const roleValue = formData.role as 'admin' | 'agent';
+      const roleValue = formData.role;
       createUser({ name: formData.name, email: formData.email, password: formData.password, role: roleValue })
         .then((res) => {
           const created = res?.data ?? res;
@@ -59,7 +59,7 @@ export function UsersPage() {
             id: `user_${Date.now()}`,
             name: formData.name,
             email: formData.email,
-            role: formData.role as 'admin' | 'agent',
+            role: formData.role,
             created_at: new Date().toISOString(),
             tenant_id: '',
             updated_at: new Date().toISOString(),
@@ -218,6 +218,8 @@ export function UsersPage() {
                   className="w-full px-4 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-indigo-500 outline-none"
                 >
                   <option value="agent">Agent</option>
+                  <option value="supervisor">Supervisor</option>
+                  <option value="viewer">Viewer</option>
                   <option value="admin">Admin</option>
                 </select>
               </div>
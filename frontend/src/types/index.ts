@@ -2,7 +2,7 @@ export interface User {
   id: string;
   email: string;
   name: string;
-  role: 'admin' | 'agent';
+  role: string; // admin, supervisor, agent, viewer or a custom role
   tenant_id: string;
   created_at: string;
   updated_at: string;
@@ -20,6 +20,7 @@ export interface Conversation {
   assigned_agent_name?: string;
   last_message?: string;
   last_message_at?: string;
+  unread_count?: number;
   created_at: string;
   updated_at: string;
 }
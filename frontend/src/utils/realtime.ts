@@ -8,6 +8,8 @@ class Realtime {
   private maxReconnectMs = 30000;
   private listeners: Map<string, Set<(payload: any) => void>> = new Map();
   private wildcardListeners: Set<(msg: MessagePayload) => void> = new Set();
+  // topic subscriptions, re-sent after every reconnect
+  private subscriptions: Map<string, { topic: string; id?: string }> = new Map();
 
   private getUrl() {
     const proto = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
@@ -24,6 +26,7 @@ class Realtime {
 
     this.ws.onopen = () => {
       this.reconnectMs = 1000;
+      this.subscriptions.forEach((sub) => this.send('subscribe', sub));
     };
 
     this.ws.onmessage = (ev) => {
@@ -69,6 +72,19 @@ class Realtime {
     return true;
   }
 
+  // subscribe follows a topic: 'conversation' or 'ticket' with an id, 'tickets' or 'assignments'
+  subscribe(topic: string, id?: string) {
+    const sub = id ? { topic, id } : { topic };
+    this.subscriptions.set(id ? `${topic}:${id}` : topic, sub);
+    this.send('subscribe', sub);
+    return () => this.unsubscribe(topic, id);
+  }
+
+  unsubscribe(topic: string, id?: string) {
+    this.subscriptions.delete(id ? `${topic}:${id}` : topic);
+    this.send('unsubscribe', id ? { topic, id } : { topic });
+  }
+
   on(type: string, cb: (payload: any) => void) {
     if (type === '*') {
       this.wildcardListeners.add(cb as any);